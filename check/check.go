@@ -1,13 +1,22 @@
 package check
 
+import "context"
+
 type Check interface {
 	Name() string
 	PassedMessage() string
 	FailedMessage() string
-	Run() error
+	Run(ctx context.Context) error
 	Passed() bool
 	IsRunnable() bool
 	UUID() string
 	Status() string
 	RequiresRoot() bool
 }
+
+// RunnableContext is implemented by checks that run commands to find out
+// whether they can run, so that the commands are killed when ctx is done.
+// Evaluate uses it instead of IsRunnable.
+type RunnableContext interface {
+	IsRunnableContext(ctx context.Context) bool
+}
@@ -1,6 +1,7 @@
 package check
 
 import (
+	"context"
 	"testing"
 )
 
@@ -10,15 +11,15 @@ type MockCheck struct {
 	isRunnable bool
 }
 
-func (m *MockCheck) Name() string          { return "MockCheck" }
-func (m *MockCheck) PassedMessage() string { return "Passed" }
-func (m *MockCheck) FailedMessage() string { return "Failed" }
-func (m *MockCheck) Run() error            { return nil }
-func (m *MockCheck) Passed() bool          { return m.passed }
-func (m *MockCheck) IsRunnable() bool      { return m.isRunnable }
-func (m *MockCheck) UUID() string          { return m.uuid }
-func (m *MockCheck) Status() string        { return "Status" }
-func (m *MockCheck) RequiresRoot() bool    { return false }
+func (m *MockCheck) Name() string                  { return "MockCheck" }
+func (m *MockCheck) PassedMessage() string         { return "Passed" }
+func (m *MockCheck) FailedMessage() string         { return "Failed" }
+func (m *MockCheck) Run(ctx context.Context) error { return nil }
+func (m *MockCheck) Passed() bool                  { return m.passed }
+func (m *MockCheck) IsRunnable() bool              { return m.isRunnable }
+func (m *MockCheck) UUID() string                  { return m.uuid }
+func (m *MockCheck) Status() string                { return "Status" }
+func (m *MockCheck) RequiresRoot() bool            { return false }
 
 func TestMockCheck_Name(t *testing.T) {
 	mockCheck := &MockCheck{}
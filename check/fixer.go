@@ -0,0 +1,16 @@
+package check
+
+import "context"
+
+// Fixer is implemented by checks that can fix the problem they found.
+// Plan and Apply act on what the check found during its last run, so the
+// check has to be run before either is called.
+type Fixer interface {
+	// Plan describes the changes Apply would make, one step per entry.
+	// An empty plan means the check cannot fix the problem on its own.
+	Plan() []string
+	// Apply makes the changes described by Plan.
+	Apply(ctx context.Context) error
+	// FixRequiresRoot returns whether Apply has to run as root.
+	FixRequiresRoot() bool
+}
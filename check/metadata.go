@@ -0,0 +1,73 @@
+package check
+
+// Severity ranks how important it is to fix a failing check.
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+)
+
+// Rank returns a number that orders severities, higher is more severe.
+// Unknown severities rank as zero.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityCritical:
+		return 4
+	case SeverityHigh:
+		return 3
+	case SeverityMedium:
+		return 2
+	case SeverityLow:
+		return 1
+	}
+	return 0
+}
+
+// Families of operating systems that remediation text can be written for.
+const (
+	FamilyDefault = "default"
+	FamilyDebian  = "debian"
+	FamilyFedora  = "fedora"
+	FamilyArch    = "arch"
+	FamilySUSE    = "suse"
+	FamilyNixOS   = "nixos"
+	FamilyMacOS   = "macos"
+	FamilyWindows = "windows"
+)
+
+// Metadata describes a check beyond its passed and failed messages.
+type Metadata struct {
+	Severity    Severity          `json:"severity"`
+	Category    string            `json:"category"`
+	Remediation map[string]string `json:"remediation,omitempty"`
+}
+
+// RemediationFor returns the remediation text for the given OS family,
+// falling back to the default text if there is none specific to it.
+func (m Metadata) RemediationFor(family string) string {
+	if text, ok := m.Remediation[family]; ok {
+		return text
+	}
+	return m.Remediation[FamilyDefault]
+}
+
+// Describer is implemented by checks that provide Metadata.
+type Describer interface {
+	Metadata() Metadata
+}
+
+// MetadataOf returns the Metadata of chk. Checks that do not describe
+// themselves are treated as medium severity with no remediation.
+func MetadataOf(chk Check) Metadata {
+	if d, ok := chk.(Describer); ok {
+		meta := d.Metadata()
+		if meta.Severity == "" {
+			meta.Severity = SeverityMedium
+		}
+		return meta
+	}
+	return Metadata{Severity: SeverityMedium}
+}
@@ -0,0 +1,52 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type describedCheck struct {
+	MockCheck
+	meta Metadata
+}
+
+func (d *describedCheck) Metadata() Metadata { return d.meta }
+
+func TestSeverity_Rank(t *testing.T) {
+	assert.Greater(t, SeverityCritical.Rank(), SeverityHigh.Rank())
+	assert.Greater(t, SeverityHigh.Rank(), SeverityMedium.Rank())
+	assert.Greater(t, SeverityMedium.Rank(), SeverityLow.Rank())
+	assert.Greater(t, SeverityLow.Rank(), Severity("").Rank())
+}
+
+func TestMetadata_RemediationFor(t *testing.T) {
+	meta := Metadata{
+		Remediation: map[string]string{
+			FamilyDefault: "default fix",
+			FamilyDebian:  "apt fix",
+		},
+	}
+	assert.Equal(t, "apt fix", meta.RemediationFor(FamilyDebian))
+	assert.Equal(t, "default fix", meta.RemediationFor(FamilyFedora))
+	assert.Equal(t, "", Metadata{}.RemediationFor(FamilyDebian))
+}
+
+func TestMetadataOf(t *testing.T) {
+	t.Run("check without metadata", func(t *testing.T) {
+		meta := MetadataOf(&MockCheck{})
+		assert.Equal(t, SeverityMedium, meta.Severity)
+		assert.Empty(t, meta.Remediation)
+	})
+
+	t.Run("check with metadata", func(t *testing.T) {
+		meta := MetadataOf(&describedCheck{meta: Metadata{Severity: SeverityCritical, Category: "network"}})
+		assert.Equal(t, SeverityCritical, meta.Severity)
+		assert.Equal(t, "network", meta.Category)
+	})
+
+	t.Run("check with empty severity", func(t *testing.T) {
+		meta := MetadataOf(&describedCheck{meta: Metadata{Category: "network"}})
+		assert.Equal(t, SeverityMedium, meta.Severity)
+	})
+}
@@ -0,0 +1,158 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Factory returns a fresh instance of a check.
+type Factory func() Check
+
+// Registration describes a check known to a Registry.
+type Registration struct {
+	UUID  string
+	Claim string
+	// Platforms lists the runtime.GOOS values the check runs on. An empty
+	// list means every platform.
+	Platforms []string
+	Tags      []string
+	// DependsOn lists the UUIDs of checks that must pass before this check
+	// is run.
+	DependsOn []string
+	// CacheTTL is how long the root helper may answer with a cached result
+	// of the check. Zero disables caching.
+	CacheTTL time.Duration
+	// Watch lists glob patterns of the files whose changes invalidate a
+	// cached result of the check.
+	Watch []string
+	New   Factory
+}
+
+// SupportsPlatform returns true if the check runs on platform.
+func (r Registration) SupportsPlatform(platform string) bool {
+	return len(r.Platforms) == 0 || slices.Contains(r.Platforms, platform)
+}
+
+// HasTag returns true if the check is tagged with tag.
+func (r Registration) HasTag(tag string) bool {
+	return slices.Contains(r.Tags, tag)
+}
+
+// Query selects registrations. Empty fields match every registration.
+type Query struct {
+	UUID     string
+	Claim    string
+	Tag      string
+	Platform string
+}
+
+// Matches returns true if reg is selected by the query.
+func (q Query) Matches(reg Registration) bool {
+	return (q.UUID == "" || reg.UUID == q.UUID) &&
+		(q.Claim == "" || reg.Claim == q.Claim) &&
+		(q.Tag == "" || reg.HasTag(q.Tag)) &&
+		(q.Platform == "" || reg.SupportsPlatform(q.Platform))
+}
+
+// Registry holds the registrations of checks in the order they were
+// registered. It is safe for concurrent use.
+type Registry struct {
+	mu            sync.RWMutex
+	registrations []Registration
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds reg to the registry. If reg has no UUID, the UUID of a
+// check returned by its factory is used. A UUID may be registered more than
+// once only for different platforms, and dependencies may not form a cycle.
+func (r *Registry) Register(reg Registration) error {
+	if reg.New == nil {
+		return errors.New("registration has no factory")
+	}
+	if reg.UUID == "" {
+		reg.UUID = reg.New().UUID()
+	}
+	if reg.Claim == "" {
+		return fmt.Errorf("check %s has no claim", reg.UUID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.registrations {
+		if existing.UUID == reg.UUID && platformsOverlap(existing, reg) {
+			return fmt.Errorf("check %s is already registered", reg.UUID)
+		}
+	}
+	if r.dependsOn(reg.DependsOn, reg.UUID, map[string]bool{}) {
+		return fmt.Errorf("check %s has a dependency cycle", reg.UUID)
+	}
+	r.registrations = append(r.registrations, reg)
+	return nil
+}
+
+// MustRegister is like Register but panics if the registration fails.
+func (r *Registry) MustRegister(reg Registration) {
+	if err := r.Register(reg); err != nil {
+		panic(err)
+	}
+}
+
+// dependsOn returns true if target is reachable from the dependencies deps.
+func (r *Registry) dependsOn(deps []string, target string, visited map[string]bool) bool {
+	for _, dep := range deps {
+		if dep == target {
+			return true
+		}
+		if visited[dep] {
+			continue
+		}
+		visited[dep] = true
+		for _, reg := range r.registrations {
+			if reg.UUID == dep && r.dependsOn(reg.DependsOn, target, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func platformsOverlap(a, b Registration) bool {
+	if len(a.Platforms) == 0 || len(b.Platforms) == 0 {
+		return true
+	}
+	for _, platform := range a.Platforms {
+		if b.SupportsPlatform(platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the registrations selected by q, in registration order.
+func (r *Registry) Query(q Query) []Registration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	matches := []Registration{}
+	for _, reg := range r.registrations {
+		if q.Matches(reg) {
+			matches = append(matches, reg)
+		}
+	}
+	return matches
+}
+
+// Lookup returns the registration of the check with uuid on platform.
+func (r *Registry) Lookup(uuid string, platform string) (Registration, bool) {
+	matches := r.Query(Query{UUID: uuid, Platform: platform})
+	if len(matches) == 0 {
+		return Registration{}, false
+	}
+	return matches[0], true
+}
@@ -0,0 +1,62 @@
+package check
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockFactory(uuid string) Factory {
+	return func() Check { return &MockCheck{uuid: uuid} }
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	assert.NoError(t, r.Register(Registration{Claim: "Access", Platforms: []string{"linux"}, New: mockFactory("a")}))
+	assert.NoError(t, r.Register(Registration{Claim: "Access", Platforms: []string{"darwin"}, New: mockFactory("a")}))
+
+	assert.ErrorContains(t, r.Register(Registration{Claim: "Access", New: mockFactory("a")}), "already registered")
+	assert.ErrorContains(t, r.Register(Registration{Claim: "Access"}), "no factory")
+	assert.ErrorContains(t, r.Register(Registration{New: mockFactory("b")}), "no claim")
+
+	reg, found := r.Lookup("a", "darwin")
+	assert.True(t, found)
+	assert.Equal(t, "a", reg.UUID)
+	_, found = r.Lookup("a", "windows")
+	assert.False(t, found)
+}
+
+func TestRegistry_DependencyCycle(t *testing.T) {
+	r := NewRegistry()
+	assert.ErrorContains(t, r.Register(Registration{Claim: "Access", DependsOn: []string{"a"}, New: mockFactory("a")}), "cycle")
+	assert.NoError(t, r.Register(Registration{Claim: "Access", DependsOn: []string{"b"}, New: mockFactory("a")}))
+	assert.NoError(t, r.Register(Registration{Claim: "Access", DependsOn: []string{"c"}, New: mockFactory("b")}))
+	assert.ErrorContains(t, r.Register(Registration{Claim: "Access", DependsOn: []string{"a"}, New: mockFactory("c")}), "cycle")
+	assert.NoError(t, r.Register(Registration{Claim: "Access", New: mockFactory("c")}))
+}
+
+func TestRegistry_Query(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(Registration{Claim: "Access", Platforms: []string{"linux"}, Tags: []string{"ssh"}, New: mockFactory("a")})
+	r.MustRegister(Registration{Claim: "Updates", Tags: []string{"updates"}, New: mockFactory("b")})
+	r.MustRegister(Registration{Claim: "Access", Platforms: []string{"darwin"}, New: mockFactory("c")})
+
+	uuids := func(regs []Registration) []string {
+		result := []string{}
+		for _, reg := range regs {
+			result = append(result, reg.UUID)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, uuids(r.Query(Query{})))
+	assert.Equal(t, []string{"a", "b"}, uuids(r.Query(Query{Platform: "linux"})))
+	assert.Equal(t, []string{"a", "c"}, uuids(r.Query(Query{Claim: "Access"})))
+	assert.Equal(t, []string{"a"}, uuids(r.Query(Query{Tag: "ssh"})))
+	assert.Equal(t, []string{"b"}, uuids(r.Query(Query{UUID: "b", Platform: "windows"})))
+}
+
+func TestRegistration_NewReturnsFreshInstances(t *testing.T) {
+	reg := Registration{New: mockFactory("a")}
+	first, second := reg.New(), reg.New()
+	assert.NotSame(t, first, second)
+}
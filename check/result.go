@@ -0,0 +1,163 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Outcome describes how a check run ended.
+type Outcome string
+
+const (
+	// OutcomePass means the check ran and the system is compliant.
+	OutcomePass Outcome = "pass"
+	// OutcomeFail means the check ran and found a problem.
+	OutcomeFail Outcome = "fail"
+	// OutcomeError means the check could not complete its run.
+	OutcomeError Outcome = "error"
+	// OutcomeSkipped means the check was deliberately not run.
+	OutcomeSkipped Outcome = "skipped"
+	// OutcomeNotApplicable means the check cannot run on this system.
+	OutcomeNotApplicable Outcome = "not-applicable"
+)
+
+// Result is the structured outcome of running a check.
+type Result struct {
+	Outcome  Outcome           `json:"outcome"`
+	Reason   string            `json:"reason"`
+	Evidence map[string]string `json:"evidence,omitempty"`
+}
+
+// Passed returns true if the check ran and passed.
+func (r Result) Passed() bool {
+	return r.Outcome == OutcomePass
+}
+
+// Failed returns true if the check ran and failed, or errored out.
+func (r Result) Failed() bool {
+	return r.Outcome == OutcomeFail || r.Outcome == OutcomeError
+}
+
+// Evidencer is implemented by checks that expose machine-readable evidence
+// of what they found during their last run, such as open ports or the
+// offending key files.
+type Evidencer interface {
+	Evidence() map[string]string
+}
+
+// ResultOf returns the Result reflecting the current state of chk,
+// without running it.
+func ResultOf(chk Check) Result {
+	res := Result{
+		Outcome: OutcomeFail,
+		Reason:  chk.Status(),
+	}
+	if chk.Passed() {
+		res.Outcome = OutcomePass
+	}
+	if ev, ok := chk.(Evidencer); ok {
+		res.Evidence = ev.Evidence()
+	}
+	return res
+}
+
+var (
+	abandonedMutex sync.Mutex
+	// abandoned holds the UUIDs of the checks whose run outlived its context
+	// and is still changing their state. They are keyed by UUID, as checks
+	// need not be comparable and there is one instance of each check.
+	abandoned = make(map[string]bool)
+)
+
+// Evaluate runs chk if it is runnable and returns the Result of the run.
+// If ctx is done before the check finishes, the check is abandoned and the
+// Result has the error outcome. A check with the UUID of an abandoned check
+// is not run or read again until that run finishes, so that the state of the
+// check is only touched by one run.
+func Evaluate(ctx context.Context, chk Check) Result {
+	abandonedMutex.Lock()
+	if abandoned[chk.UUID()] {
+		abandonedMutex.Unlock()
+		return Result{
+			Outcome: OutcomeError,
+			Reason:  "Previous run of the check has not finished",
+		}
+	}
+	abandonedMutex.Unlock()
+
+	done := make(chan Result, 1)
+	go func() {
+		res := evaluate(ctx, chk)
+		abandonedMutex.Lock()
+		delete(abandoned, chk.UUID())
+		done <- res
+		abandonedMutex.Unlock()
+	}()
+
+	select {
+	case res := <-done:
+		return finishedResult(ctx, res)
+	case <-ctx.Done():
+	}
+
+	abandonedMutex.Lock()
+	defer abandonedMutex.Unlock()
+	select {
+	case res := <-done:
+		// The run finished while the context was being cancelled
+		return finishedResult(ctx, res)
+	default:
+	}
+	abandoned[chk.UUID()] = true
+	return cancelledResult(ctx)
+}
+
+// finishedResult returns res, unless the run failed because ctx was done,
+// such as when its commands were killed at the deadline.
+func finishedResult(ctx context.Context, res Result) Result {
+	if res.Outcome == OutcomeError && ctx.Err() != nil {
+		return cancelledResult(ctx)
+	}
+	return res
+}
+
+// cancelledResult returns the Result of a run that did not finish before
+// ctx was done.
+func cancelledResult(ctx context.Context) Result {
+	reason := "Check was cancelled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = "Check timed out"
+	}
+	return Result{
+		Outcome: OutcomeError,
+		Reason:  reason,
+	}
+}
+
+// isRunnable returns whether chk can run, stopping the commands it runs to
+// find out when ctx is done if it implements RunnableContext.
+func isRunnable(ctx context.Context, chk Check) bool {
+	if runnable, ok := chk.(RunnableContext); ok {
+		return runnable.IsRunnableContext(ctx)
+	}
+	return chk.IsRunnable()
+}
+
+func evaluate(ctx context.Context, chk Check) Result {
+	if !isRunnable(ctx, chk) {
+		return Result{
+			Outcome: OutcomeNotApplicable,
+			Reason:  chk.Status(),
+		}
+	}
+
+	if err := chk.Run(ctx); err != nil {
+		res := ResultOf(chk)
+		res.Outcome = OutcomeError
+		res.Reason = err.Error()
+		return res
+	}
+
+	return ResultOf(chk)
+}
@@ -0,0 +1,151 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type evidenceCheck struct {
+	MockCheck
+	runErr error
+}
+
+func (e *evidenceCheck) Run(ctx context.Context) error { return e.runErr }
+func (e *evidenceCheck) Evidence() map[string]string {
+	return map[string]string{"tcp/22": "SSH"}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name            string
+		chk             Check
+		expectedOutcome Outcome
+		expectedReason  string
+	}{
+		{
+			name:            "passing check",
+			chk:             &MockCheck{passed: true, isRunnable: true},
+			expectedOutcome: OutcomePass,
+			expectedReason:  "Status",
+		},
+		{
+			name:            "failing check",
+			chk:             &MockCheck{passed: false, isRunnable: true},
+			expectedOutcome: OutcomeFail,
+			expectedReason:  "Status",
+		},
+		{
+			name:            "not runnable check",
+			chk:             &MockCheck{isRunnable: false},
+			expectedOutcome: OutcomeNotApplicable,
+			expectedReason:  "Status",
+		},
+		{
+			name:            "check returning an error",
+			chk:             &evidenceCheck{MockCheck: MockCheck{isRunnable: true}, runErr: errors.New("boom")},
+			expectedOutcome: OutcomeError,
+			expectedReason:  "boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Evaluate(context.Background(), tt.chk)
+			assert.Equal(t, tt.expectedOutcome, res.Outcome)
+			assert.Equal(t, tt.expectedReason, res.Reason)
+		})
+	}
+}
+
+type contextRunnableCheck struct {
+	MockCheck
+	ctx context.Context
+}
+
+func (c *contextRunnableCheck) IsRunnableContext(ctx context.Context) bool {
+	c.ctx = ctx
+	return false
+}
+
+func TestEvaluate_RunnableContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chk := &contextRunnableCheck{MockCheck: MockCheck{isRunnable: true}}
+
+	res := Evaluate(ctx, chk)
+	assert.Equal(t, OutcomeNotApplicable, res.Outcome)
+	assert.Equal(t, ctx, chk.ctx, "IsRunnableContext is called with the context of the run")
+}
+
+type blockingCheck struct {
+	MockCheck
+}
+
+func (b *blockingCheck) Run(ctx context.Context) error {
+	<-ctx.Done()
+	time.Sleep(time.Second)
+	return ctx.Err()
+}
+
+func TestEvaluate_Timeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	res := Evaluate(ctx, &blockingCheck{MockCheck: MockCheck{uuid: "timed-out", isRunnable: true}})
+	assert.Equal(t, OutcomeError, res.Outcome)
+	assert.Equal(t, "Check timed out", res.Reason)
+	assert.Less(t, time.Since(started), time.Second)
+}
+
+type releasedCheck struct {
+	MockCheck
+	release chan struct{}
+}
+
+func (r *releasedCheck) Run(ctx context.Context) error {
+	<-r.release
+	r.passed = true
+	return nil
+}
+
+func TestEvaluate_AbandonedCheck(t *testing.T) {
+	chk := &releasedCheck{MockCheck: MockCheck{uuid: "abandoned", isRunnable: true}, release: make(chan struct{})}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	res := Evaluate(ctx, chk)
+	assert.Equal(t, "Check timed out", res.Reason)
+
+	// The abandoned run still owns the check
+	res = Evaluate(context.Background(), chk)
+	assert.Equal(t, OutcomeError, res.Outcome)
+	assert.Equal(t, "Previous run of the check has not finished", res.Reason)
+
+	close(chk.release)
+	assert.Eventually(t, func() bool {
+		return Evaluate(context.Background(), chk).Outcome == OutcomePass
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestEvaluate_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res := Evaluate(ctx, &blockingCheck{MockCheck: MockCheck{uuid: "cancelled", isRunnable: true}})
+	assert.Equal(t, OutcomeError, res.Outcome)
+	assert.Equal(t, "Check was cancelled", res.Reason)
+}
+
+func TestResultOf_Evidence(t *testing.T) {
+	chk := &evidenceCheck{MockCheck: MockCheck{passed: false, isRunnable: true}}
+	res := ResultOf(chk)
+	assert.Equal(t, OutcomeFail, res.Outcome)
+	assert.Equal(t, map[string]string{"tcp/22": "SSH"}, res.Evidence)
+	assert.True(t, res.Failed())
+	assert.False(t, res.Passed())
+}
@@ -3,14 +3,23 @@ package checks
 import (
 	"testing"
 
+	chk "github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
 	"github.com/samber/lo"
 )
 
 func TestClaims(t *testing.T) {
+	for _, platform := range []string{"linux", "darwin", "windows"} {
+		t.Run(platform, func(t *testing.T) {
+			testClaims(t, claims.Select(chk.Query{Platform: platform}))
+		})
+	}
+}
+
+func testClaims(t *testing.T, all []claims.Claim) {
 	uuids := []string{}
 
-	for _, claim := range claims.All {
+	for _, claim := range all {
 		for _, check := range claim.Checks {
 			if lo.Contains(uuids, check.UUID()) {
 				t.Errorf("Duplicate check UUID %s", check.UUID())
@@ -27,6 +36,17 @@ func TestClaims(t *testing.T) {
 			check.Name()
 			check.PassedMessage()
 			check.FailedMessage()
+
+			if _, ok := check.(chk.Describer); !ok {
+				t.Errorf("Check %s does not provide metadata", check.UUID())
+			}
+			meta := chk.MetadataOf(check)
+			if meta.Severity.Rank() == 0 {
+				t.Errorf("Check %s has an unknown severity %q", check.UUID(), meta.Severity)
+			}
+			if meta.RemediationFor(chk.FamilyDefault) == "" {
+				t.Errorf("Check %s has no default remediation", check.UUID())
+			}
 		}
 	}
 }
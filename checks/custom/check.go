@@ -0,0 +1,139 @@
+package custom
+
+import (
+	"context"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
+)
+
+// Check is a check.Check backed by a Definition.
+type Check struct {
+	def      Definition
+	passed   bool
+	status   string
+	evidence map[string]string
+}
+
+// New returns the check for def, which should be valid.
+func New(def Definition) *Check {
+	return &Check{def: def}
+}
+
+// Definition returns the definition of the check
+func (c *Check) Definition() Definition {
+	return c.def
+}
+
+// Claim returns the title of the claim the check belongs to
+func (c *Check) Claim() string {
+	return c.def.Claim
+}
+
+// Name returns the name of the check
+func (c *Check) Name() string {
+	return c.def.Name
+}
+
+// Run executes the check
+func (c *Check) Run(ctx context.Context) error {
+	c.passed = false
+	c.evidence = nil
+
+	if c.RequiresRoot() && !shared.IsRoot() {
+		log.Debug("Running check via root helper")
+		res, err := shared.RunCheckViaHelper(ctx, c.UUID())
+		if err != nil {
+			log.WithError(err).Warn("Failed to run check via root helper")
+			return err
+		}
+		c.passed = res.Passed()
+		c.status = res.Reason
+		c.evidence = res.Evidence
+		return nil
+	}
+
+	matched, evidence, err := evaluate(ctx, c.def)
+	if err != nil {
+		return err
+	}
+	c.evidence = evidence
+	c.passed = matched != c.def.Negate
+	return nil
+}
+
+// Passed returns the status of the check
+func (c *Check) Passed() bool {
+	return c.passed
+}
+
+// IsRunnable returns whether the check can run
+func (c *Check) IsRunnable() bool {
+	if c.RequiresRoot() && !shared.IsRoot() {
+		can := shared.IsSocketServicePresent()
+		if !can {
+			c.status = "Root helper is not available, check cannot run. See https://paretosecurity.com/docs/linux/root-helper for more information."
+		}
+		return can
+	}
+	can, reason := runnable(c.def)
+	if !can {
+		c.status = reason
+	}
+	return can
+}
+
+// UUID returns the UUID of the check
+func (c *Check) UUID() string {
+	return c.def.UUID
+}
+
+// PassedMessage returns the message to return if the check passed
+func (c *Check) PassedMessage() string {
+	return c.def.PassedMessage
+}
+
+// FailedMessage returns the message to return if the check failed
+func (c *Check) FailedMessage() string {
+	return c.def.FailedMessage
+}
+
+// RequiresRoot returns whether the check requires root access
+func (c *Check) RequiresRoot() bool {
+	return c.def.RequiresRoot
+}
+
+// Status returns the status of the check
+func (c *Check) Status() string {
+	if c.Passed() {
+		return c.PassedMessage()
+	}
+	if c.status != "" {
+		return c.status
+	}
+	return c.FailedMessage()
+}
+
+// Evidence returns what the rule observed during the last run
+func (c *Check) Evidence() map[string]string {
+	return c.evidence
+}
+
+// Metadata returns the severity, category and remediation of the check
+func (c *Check) Metadata() check.Metadata {
+	meta := check.Metadata{
+		Severity: check.Severity(c.def.Severity),
+		Category: c.def.Category,
+	}
+	if meta.Severity == "" {
+		meta.Severity = check.SeverityMedium
+	}
+	if meta.Category == "" {
+		meta.Category = "custom"
+	}
+	if c.def.Remediation != "" {
+		meta.Remediation = map[string]string{check.FamilyDefault: c.def.Remediation}
+	}
+	return meta
+}
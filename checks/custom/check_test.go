@@ -0,0 +1,209 @@
+package custom
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeFileInfo struct {
+	mode fs.FileMode
+}
+
+func (f fakeFileInfo) Name() string       { return "file" }
+func (f fakeFileInfo) Size() int64        { return 0 }
+func (f fakeFileInfo) Mode() fs.FileMode  { return f.mode }
+func (f fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeFileInfo) IsDir() bool        { return false }
+func (f fakeFileInfo) Sys() any           { return nil }
+
+func TestCheck_Run(t *testing.T) {
+	defer func() {
+		shared.ReadFileMocks = nil
+		shared.RunCommandMocks = nil
+		lookPathMock = nil
+		osStatMock = nil
+		filepathGlobMock = nil
+		fileOwnerMock = nil
+	}()
+	filepathGlobMock = func(pattern string) ([]string, error) {
+		if pattern == "/etc/sysctl.d/*.conf" {
+			return []string{"/etc/sysctl.d/10-base.conf", "/etc/sysctl.d/50-hardening.conf"}, nil
+		}
+		return []string{pattern}, nil
+	}
+	lookPathMock = func(file string) (string, error) {
+		if file == "dpkg-query" || file == "systemctl" || file == "uname" {
+			return "/usr/bin/" + file, nil
+		}
+		return "", errors.New("not found")
+	}
+	osStatMock = func(file string) (os.FileInfo, error) {
+		if file == "/etc/shadow" {
+			return fakeFileInfo{mode: 0640}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	fileOwnerMock = func(info os.FileInfo) (string, string, error) {
+		return "root", "shadow", nil
+	}
+	shared.ReadFileMocks = map[string]string{
+		"/etc/sysctl.d/10-base.conf":      "net.ipv4.ip_forward = 0\n",
+		"/etc/sysctl.d/50-hardening.conf": "kernel.kptr_restrict = 2\n",
+		"/proc/sys/kernel/kptr_restrict":  "1\n",
+		"/etc/ssh/sshd_config":            "PermitRootLogin yes\n",
+	}
+	shared.RunCommandMocks = []shared.RunCommandMock{
+		{Command: "dpkg-query", Args: []string{"-W", "-f=${Status}", "auditd"}, Out: "install ok installed"},
+		{Command: "dpkg-query", Args: []string{"-W", "-f=${Status}", "telnetd"}, Out: "", Err: errors.New("exit status 1")},
+		{Command: "systemctl", Args: []string{"is-active", "auditd.service"}, Out: "active\n"},
+		{Command: "systemctl", Args: []string{"is-enabled", "auditd.service"}, Out: "disabled\n", Err: errors.New("exit status 1")},
+		{Command: "uname", Args: []string{"-r"}, Out: "6.8.0-generic\n"},
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(d *Definition)
+		passed   bool
+		evidence map[string]string
+	}{
+		{
+			name: "file contains in a glob",
+			modify: func(d *Definition) {
+				d.Type, d.Path, d.Pattern = TypeFileContains, "/etc/sysctl.d/*.conf", "kernel.kptr_restrict = 2"
+			},
+			passed:   true,
+			evidence: map[string]string{"/etc/sysctl.d/50-hardening.conf": "matches"},
+		},
+		{
+			name: "negated file regex",
+			modify: func(d *Definition) {
+				d.Type, d.Path, d.Pattern, d.Negate = TypeFileRegex, "/etc/ssh/sshd_config", `(?m)^PermitRootLogin\s+yes`, true
+			},
+			passed:   false,
+			evidence: map[string]string{"/etc/ssh/sshd_config": "matches"},
+		},
+		{
+			name: "command output",
+			modify: func(d *Definition) {
+				d.Type, d.Command, d.Pattern = TypeCommand, []string{"uname", "-r"}, `^6\.`
+			},
+			passed: true,
+		},
+		{
+			name:     "sysctl",
+			modify:   func(d *Definition) {},
+			passed:   false,
+			evidence: map[string]string{"kernel.kptr_restrict": "1"},
+		},
+		{
+			name: "package installed",
+			modify: func(d *Definition) {
+				d.Type, d.Package = TypePackage, "auditd"
+			},
+			passed:   true,
+			evidence: map[string]string{"auditd": "installed via dpkg-query"},
+		},
+		{
+			name: "package not installed",
+			modify: func(d *Definition) {
+				d.Type, d.Package = TypePackage, "telnetd"
+			},
+			passed:   false,
+			evidence: map[string]string{"telnetd": "not installed"},
+		},
+		{
+			name: "systemd active",
+			modify: func(d *Definition) {
+				d.Type, d.Unit, d.State = TypeSystemd, "auditd.service", "active"
+			},
+			passed:   true,
+			evidence: map[string]string{"auditd.service": "active"},
+		},
+		{
+			name: "systemd enabled",
+			modify: func(d *Definition) {
+				d.Type, d.Unit, d.State = TypeSystemd, "auditd.service", "enabled"
+			},
+			passed:   false,
+			evidence: map[string]string{"auditd.service": "disabled"},
+		},
+		{
+			name: "file mode and owner",
+			modify: func(d *Definition) {
+				d.Type, d.Path, d.Mode, d.Owner, d.Group = TypeFileMode, "/etc/shadow", "0640", "root", "shadow"
+			},
+			passed:   true,
+			evidence: map[string]string{"mode": "0640", "owner": "root", "group": "shadow"},
+		},
+		{
+			name: "file mode too open",
+			modify: func(d *Definition) {
+				d.Type, d.Path, d.Mode = TypeFileMode, "/etc/shadow", "0600"
+			},
+			passed:   false,
+			evidence: map[string]string{"mode": "0640"},
+		},
+		{
+			name: "file mode of a missing file",
+			modify: func(d *Definition) {
+				d.Type, d.Path, d.Mode = TypeFileMode, "/etc/gshadow", "0600"
+			},
+			passed:   false,
+			evidence: map[string]string{"/etc/gshadow": "missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := validDefinition()
+			tt.modify(&def)
+			assert.NoError(t, def.Validate())
+
+			chk := New(def)
+			assert.True(t, chk.IsRunnable())
+			assert.NoError(t, chk.Run(context.Background()))
+			assert.Equal(t, tt.passed, chk.Passed())
+			assert.Equal(t, tt.evidence, chk.Evidence())
+			if tt.passed {
+				assert.Equal(t, def.PassedMessage, chk.Status())
+			} else {
+				assert.Equal(t, def.FailedMessage, chk.Status())
+			}
+		})
+	}
+}
+
+func TestCheck_IsRunnable(t *testing.T) {
+	lookPathMock = func(file string) (string, error) {
+		return "", errors.New("not found")
+	}
+	defer func() { lookPathMock = nil }()
+
+	def := validDefinition()
+	def.Type, def.Command = TypeCommand, []string{"auditctl", "-s"}
+	chk := New(def)
+	assert.False(t, chk.IsRunnable())
+	assert.Equal(t, "auditctl is not installed, check cannot run", chk.Status())
+}
+
+func TestCheck_Metadata(t *testing.T) {
+	def := validDefinition()
+	meta := New(def).Metadata()
+	assert.Equal(t, check.SeverityMedium, meta.Severity)
+	assert.Equal(t, "custom", meta.Category)
+	assert.Empty(t, meta.RemediationFor(check.FamilyDefault))
+
+	def.Severity, def.Category, def.Remediation = "high", "kernel", "Set kernel.kptr_restrict = 2 in /etc/sysctl.d."
+	meta = New(def).Metadata()
+	assert.Equal(t, check.SeverityHigh, meta.Severity)
+	assert.Equal(t, "kernel", meta.Category)
+	assert.Equal(t, def.Remediation, meta.RemediationFor(check.FamilyDebian))
+}
@@ -0,0 +1,322 @@
+// Package custom implements declarative checks that are defined in TOML or
+// YAML policy files instead of being compiled into the agent.
+//
+// A policy file defines one or more checks, for example:
+//
+//	[[checks]]
+//	uuid = "5c1a3f36-4a57-4b44-9d3b-6a4f1d2c9e10"
+//	name = "Kernel pointers are hidden"
+//	claim = "System Integrity"
+//	passed_message = "Kernel pointers are hidden"
+//	failed_message = "Kernel pointers are exposed"
+//	severity = "medium"
+//	type = "sysctl"
+//	key = "kernel.kptr_restrict"
+//	value = "2"
+//
+// Checks with requires_root = true are run by the root helper and are only
+// loaded from SystemDir, from files owned by root and not writable by other
+// users.
+package custom
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/caarlos0/log"
+	"github.com/google/uuid"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule types supported by custom checks.
+const (
+	// TypeFileContains passes if a file matching Path contains Pattern.
+	TypeFileContains = "file-contains"
+	// TypeFileRegex passes if a file matching Path matches the regular
+	// expression Pattern.
+	TypeFileRegex = "file-regex"
+	// TypeCommand passes if Command exits successfully and its output
+	// matches the regular expression Pattern, if one is given.
+	TypeCommand = "command"
+	// TypeSysctl passes if the kernel parameter Key has the value Value.
+	TypeSysctl = "sysctl"
+	// TypePackage passes if Package is installed.
+	TypePackage = "package"
+	// TypeSystemd passes if the systemd Unit is in State, such as active
+	// or enabled.
+	TypeSystemd = "systemd"
+	// TypeFileMode passes if the file at Path has no permissions beyond
+	// Mode and is owned by Owner and Group, if they are given.
+	TypeFileMode = "file-mode"
+)
+
+// Types lists the supported rule types.
+var Types = []string{TypeFileContains, TypeFileRegex, TypeCommand, TypeSysctl, TypePackage, TypeSystemd, TypeFileMode}
+
+// SystemDir holds the policy files managed by the administrator. Only
+// checks defined here may require root, as the root helper runs them, and
+// only if the directory and their file are owned by root and not writable by
+// other users.
+var SystemDir = "/etc/paretosecurity/checks.d"
+
+// UserDir returns the directory with the policy files of the current user.
+func UserDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "paretosecurity", "checks.d")
+}
+
+// Definition describes a custom check and the rule it evaluates.
+type Definition struct {
+	UUID          string   `toml:"uuid" yaml:"uuid"`
+	Name          string   `toml:"name" yaml:"name"`
+	Claim         string   `toml:"claim" yaml:"claim"`
+	PassedMessage string   `toml:"passed_message" yaml:"passed_message"`
+	FailedMessage string   `toml:"failed_message" yaml:"failed_message"`
+	RequiresRoot  bool     `toml:"requires_root" yaml:"requires_root"`
+	Severity      string   `toml:"severity" yaml:"severity"`
+	Category      string   `toml:"category" yaml:"category"`
+	Remediation   string   `toml:"remediation" yaml:"remediation"`
+	Tags          []string `toml:"tags" yaml:"tags"`
+	// DependsOn lists the UUIDs of checks that must pass before this
+	// check is run.
+	DependsOn []string `toml:"depends_on" yaml:"depends_on"`
+
+	Type string `toml:"type" yaml:"type"`
+	// Negate inverts the result of the rule, e.g. to require that a file
+	// does not contain a setting.
+	Negate  bool     `toml:"negate" yaml:"negate"`
+	Path    string   `toml:"path" yaml:"path"`
+	Pattern string   `toml:"pattern" yaml:"pattern"`
+	Command []string `toml:"command" yaml:"command"`
+	Key     string   `toml:"key" yaml:"key"`
+	Value   string   `toml:"value" yaml:"value"`
+	Package string   `toml:"package" yaml:"package"`
+	Unit    string   `toml:"unit" yaml:"unit"`
+	State   string   `toml:"state" yaml:"state"`
+	Mode    string   `toml:"mode" yaml:"mode"`
+	Owner   string   `toml:"owner" yaml:"owner"`
+	Group   string   `toml:"group" yaml:"group"`
+}
+
+// policyFile is the layout of a policy file, which may define several checks.
+type policyFile struct {
+	Checks []Definition `toml:"checks" yaml:"checks"`
+}
+
+// Validate returns an error if the definition is incomplete or its rule
+// cannot be evaluated.
+func (d Definition) Validate() error {
+	if _, err := uuid.Parse(d.UUID); err != nil {
+		return fmt.Errorf("invalid uuid %q", d.UUID)
+	}
+	for field, value := range map[string]string{
+		"name":           d.Name,
+		"claim":          d.Claim,
+		"passed_message": d.PassedMessage,
+		"failed_message": d.FailedMessage,
+	} {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("check %s: %s is required", d.UUID, field)
+		}
+	}
+	if d.Severity != "" && check.Severity(d.Severity).Rank() == 0 {
+		return fmt.Errorf("check %s: unknown severity %q", d.UUID, d.Severity)
+	}
+
+	required := map[string]string{}
+	switch d.Type {
+	case TypeFileContains:
+		required = map[string]string{"path": d.Path, "pattern": d.Pattern}
+	case TypeFileRegex:
+		required = map[string]string{"path": d.Path, "pattern": d.Pattern}
+		if _, err := regexp.Compile(d.Pattern); err != nil {
+			return fmt.Errorf("check %s: invalid pattern: %w", d.UUID, err)
+		}
+	case TypeCommand:
+		if len(d.Command) == 0 {
+			return fmt.Errorf("check %s: command is required", d.UUID)
+		}
+		if _, err := regexp.Compile(d.Pattern); err != nil {
+			return fmt.Errorf("check %s: invalid pattern: %w", d.UUID, err)
+		}
+	case TypeSysctl:
+		required = map[string]string{"key": d.Key, "value": d.Value}
+	case TypePackage:
+		required = map[string]string{"package": d.Package}
+	case TypeSystemd:
+		required = map[string]string{"unit": d.Unit, "state": d.State}
+	case TypeFileMode:
+		required = map[string]string{"path": d.Path}
+		if d.Mode == "" && d.Owner == "" && d.Group == "" {
+			return fmt.Errorf("check %s: one of mode, owner or group is required", d.UUID)
+		}
+		if d.Mode != "" {
+			if _, err := strconv.ParseUint(d.Mode, 8, 32); err != nil {
+				return fmt.Errorf("check %s: invalid mode %q, use octal such as 0644", d.UUID, d.Mode)
+			}
+		}
+	default:
+		return fmt.Errorf("check %s: unknown type %q, use one of: %s", d.UUID, d.Type, strings.Join(Types, ", "))
+	}
+	for field, value := range required {
+		if value == "" {
+			return fmt.Errorf("check %s: %s is required for type %s", d.UUID, field, d.Type)
+		}
+	}
+	return nil
+}
+
+// ParseFile parses the check definitions of a policy file. The format is
+// picked by the extension: .toml, .yaml or .yml.
+func ParseFile(name string, content []byte) ([]Definition, error) {
+	var policy policyFile
+	switch filepath.Ext(name) {
+	case ".toml":
+		if err := toml.Unmarshal(content, &policy); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &policy); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported policy file %s", name)
+	}
+	return policy.Checks, nil
+}
+
+// LoadDir loads the checks defined in the policy files of dir. Invalid files
+// and definitions are skipped and returned as errors. If allowRoot is false,
+// definitions that require root are rejected. They are also rejected if dir
+// or their file is not owned by root or is writable by other users, as the
+// root helper runs them.
+func LoadDir(dir string, allowRoot bool) ([]*Check, []error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, []error{err}
+	}
+
+	checks := []*Check{}
+	errs := []error{}
+	var untrusted error
+	if allowRoot {
+		untrusted = trustedPath(dir)
+	}
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".toml", ".yaml", ".yml":
+		default:
+			continue
+		}
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		content, info, err := readPolicy(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fileUntrusted := untrusted
+		if allowRoot && fileUntrusted == nil {
+			fileUntrusted = policyOwner(info)
+		}
+		definitions, err := ParseFile(path, content)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		for _, def := range definitions {
+			if err := def.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+			if def.RequiresRoot && !allowRoot {
+				errs = append(errs, fmt.Errorf("%s: check %s requires root, which is only allowed in %s", path, def.UUID, SystemDir))
+				continue
+			}
+			if def.RequiresRoot && fileUntrusted != nil {
+				errs = append(errs, fmt.Errorf("%s: check %s requires root, but the policy is not trusted: %w", path, def.UUID, fileUntrusted))
+				continue
+			}
+			checks = append(checks, New(def))
+		}
+	}
+	return checks, errs
+}
+
+// trustedPath returns an error unless the file at path is trusted to define
+// checks that require root.
+func trustedPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return policyOwner(info)
+}
+
+// readPolicy reads the policy file at path and returns its content and the
+// FileInfo of the file that was read, so that the file cannot be swapped
+// between checking its owner and reading it.
+func readPolicy(path string) ([]byte, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, info, nil
+}
+
+// Load loads the checks from SystemDir and UserDir. Problems with the policy
+// files are logged, and a UUID that is defined more than once is only
+// loaded the first time, so the system policy wins.
+func Load() []*Check {
+	checks := []*Check{}
+	seen := map[string]bool{}
+	dirs := []struct {
+		path      string
+		allowRoot bool
+	}{
+		{SystemDir, true},
+		{UserDir(), false},
+	}
+	for _, dir := range dirs {
+		if dir.path == "" {
+			continue
+		}
+		loaded, errs := LoadDir(dir.path, dir.allowRoot)
+		for _, err := range errs {
+			log.WithError(err).Warn("invalid custom check")
+		}
+		for _, chk := range loaded {
+			if seen[chk.UUID()] {
+				log.WithField("uuid", chk.UUID()).Warn("custom check is defined more than once, ignoring it")
+				continue
+			}
+			seen[chk.UUID()] = true
+			checks = append(checks, chk)
+		}
+	}
+	return checks
+}
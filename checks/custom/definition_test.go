@@ -0,0 +1,182 @@
+package custom
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testUUID = "5c1a3f36-4a57-4b44-9d3b-6a4f1d2c9e10"
+
+func validDefinition() Definition {
+	return Definition{
+		UUID:          testUUID,
+		Name:          "Kernel pointers are hidden",
+		Claim:         "System Integrity",
+		PassedMessage: "Kernel pointers are hidden",
+		FailedMessage: "Kernel pointers are exposed",
+		Type:          TypeSysctl,
+		Key:           "kernel.kptr_restrict",
+		Value:         "2",
+	}
+}
+
+func TestDefinition_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(d *Definition)
+		wantErr string
+	}{
+		{name: "valid", modify: func(d *Definition) {}},
+		{name: "invalid uuid", modify: func(d *Definition) { d.UUID = "nope" }, wantErr: "invalid uuid"},
+		{name: "missing claim", modify: func(d *Definition) { d.Claim = "" }, wantErr: "claim is required"},
+		{name: "unknown severity", modify: func(d *Definition) { d.Severity = "urgent" }, wantErr: "unknown severity"},
+		{name: "unknown type", modify: func(d *Definition) { d.Type = "registry" }, wantErr: "unknown type"},
+		{name: "missing value", modify: func(d *Definition) { d.Value = "" }, wantErr: "value is required"},
+		{name: "invalid regex", modify: func(d *Definition) {
+			d.Type, d.Path, d.Pattern = TypeFileRegex, "/etc/hosts", "("
+		}, wantErr: "invalid pattern"},
+		{name: "missing command", modify: func(d *Definition) { d.Type = TypeCommand }, wantErr: "command is required"},
+		{name: "invalid mode", modify: func(d *Definition) {
+			d.Type, d.Path, d.Mode = TypeFileMode, "/etc/shadow", "rw-"
+		}, wantErr: "invalid mode"},
+		{name: "file mode without expectations", modify: func(d *Definition) {
+			d.Type, d.Path = TypeFileMode, "/etc/shadow"
+		}, wantErr: "one of mode, owner or group"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := validDefinition()
+			tt.modify(&def)
+			err := def.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	tomlPolicy := `
+[[checks]]
+uuid = "5c1a3f36-4a57-4b44-9d3b-6a4f1d2c9e10"
+name = "auditd is installed"
+claim = "System Integrity"
+passed_message = "auditd is installed"
+failed_message = "auditd is not installed"
+requires_root = true
+type = "package"
+package = "auditd"
+`
+	yamlPolicy := `
+checks:
+  - uuid: 5c1a3f36-4a57-4b44-9d3b-6a4f1d2c9e10
+    name: auditd is installed
+    claim: System Integrity
+    passed_message: auditd is installed
+    failed_message: auditd is not installed
+    requires_root: true
+    type: package
+    package: auditd
+`
+	for name, content := range map[string]string{"policy.toml": tomlPolicy, "policy.yaml": yamlPolicy} {
+		t.Run(name, func(t *testing.T) {
+			defs, err := ParseFile(name, []byte(content))
+			assert.NoError(t, err)
+			assert.Len(t, defs, 1)
+			assert.Equal(t, TypePackage, defs[0].Type)
+			assert.Equal(t, "auditd", defs[0].Package)
+			assert.True(t, defs[0].RequiresRoot)
+			assert.NoError(t, defs[0].Validate())
+		})
+	}
+
+	_, err := ParseFile("policy.json", []byte("{}"))
+	assert.Error(t, err)
+}
+
+// trustAllPolicies makes LoadDir trust policy files that are not owned by
+// root, such as the temporary files of the tests.
+func trustAllPolicies(t *testing.T) {
+	t.Helper()
+	original := policyOwner
+	policyOwner = func(os.FileInfo) error { return nil }
+	t.Cleanup(func() { policyOwner = original })
+}
+
+const rootPolicy = `
+[[checks]]
+uuid = "5c1a3f36-4a57-4b44-9d3b-6a4f1d2c9e10"
+name = "Kernel pointers are hidden"
+claim = "System Integrity"
+passed_message = "Kernel pointers are hidden"
+failed_message = "Kernel pointers are exposed"
+type = "sysctl"
+key = "kernel.kptr_restrict"
+value = "2"
+
+[[checks]]
+uuid = "0e6c1a43-2f4b-4c8e-a3a7-3d0f5f1b7c21"
+name = "auditd is running"
+claim = "System Integrity"
+passed_message = "auditd is running"
+failed_message = "auditd is not running"
+requires_root = true
+type = "systemd"
+unit = "auditd.service"
+state = "active"
+`
+
+func TestLoadDir(t *testing.T) {
+	trustAllPolicies(t)
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "kernel.toml"), []byte(rootPolicy), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("checks: [\n"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0600))
+
+	checks, errs := LoadDir(dir, true)
+	assert.Len(t, checks, 2)
+	assert.Len(t, errs, 1)
+
+	checks, errs = LoadDir(dir, false)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, testUUID, checks[0].UUID())
+	assert.Len(t, errs, 2)
+
+	checks, errs = LoadDir(filepath.Join(dir, "missing"), true)
+	assert.Empty(t, checks)
+	assert.Empty(t, errs)
+}
+
+func TestLoadDir_UntrustedPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("policy files are not checked on Windows")
+	}
+
+	// A policy writable by other users only loads the checks that do not
+	// require root
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kernel.toml")
+	assert.NoError(t, os.WriteFile(path, []byte(rootPolicy), 0600))
+	assert.NoError(t, os.Chmod(path, 0666))
+	checks, errs := LoadDir(dir, true)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, testUUID, checks[0].UUID())
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "policy is not trusted")
+
+	// So does a trusted policy in a directory writable by other users
+	dir = t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "kernel.toml"), []byte(rootPolicy), 0600))
+	assert.NoError(t, os.Chmod(dir, 0777))
+	checks, errs = LoadDir(dir, true)
+	assert.Len(t, checks, 1)
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "policy is not trusted")
+}
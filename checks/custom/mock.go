@@ -0,0 +1,52 @@
+package custom
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var lookPathMock func(file string) (string, error)
+
+// lookPath searches for an executable in the directories of PATH.
+// During testing, it uses a mock implementation via lookPathMock.
+func lookPath(file string) (string, error) {
+	if testing.Testing() && lookPathMock != nil {
+		return lookPathMock(file)
+	}
+	return exec.LookPath(file)
+}
+
+var osStatMock func(file string) (os.FileInfo, error)
+
+// osStat returns the file info of the file.
+// During testing, it uses a mock implementation via osStatMock.
+func osStat(file string) (os.FileInfo, error) {
+	if testing.Testing() && osStatMock != nil {
+		return osStatMock(file)
+	}
+	return os.Stat(file)
+}
+
+var filepathGlobMock func(pattern string) ([]string, error)
+
+// filepathGlob retrieves file paths that match the provided glob pattern.
+// During testing, it uses a mock implementation via filepathGlobMock.
+func filepathGlob(pattern string) ([]string, error) {
+	if testing.Testing() && filepathGlobMock != nil {
+		return filepathGlobMock(pattern)
+	}
+	return filepath.Glob(pattern)
+}
+
+var fileOwnerMock func(info os.FileInfo) (string, string, error)
+
+// fileOwner returns the names of the user and group owning the file.
+// During testing, it uses a mock implementation via fileOwnerMock.
+func fileOwner(info os.FileInfo) (string, string, error) {
+	if testing.Testing() && fileOwnerMock != nil {
+		return fileOwnerMock(info)
+	}
+	return lookupOwner(info)
+}
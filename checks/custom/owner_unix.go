@@ -0,0 +1,53 @@
+//go:build !windows
+// +build !windows
+
+package custom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// lookupOwner resolves the user and group owning the file to their names,
+// falling back to the numeric IDs.
+func lookupOwner(info os.FileInfo) (string, string, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", errors.New("file owner is not available")
+	}
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	gid := strconv.FormatUint(uint64(stat.Gid), 10)
+	owner, group := uid, gid
+	if u, err := user.LookupId(uid); err == nil {
+		owner = u.Username
+	}
+	if g, err := user.LookupGroupId(gid); err == nil {
+		group = g.Name
+	}
+	return owner, group, nil
+}
+
+// policyOwner returns an error unless the policy file is owned by root and
+// cannot be modified by other users, as required for the checks the root
+// helper runs.
+var policyOwner = isTrusted
+
+// isTrusted returns an error unless the file is owned by root and cannot be
+// modified by other users.
+func isTrusted(info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("file owner is not available")
+	}
+	if stat.Uid != 0 {
+		return fmt.Errorf("%s is not owned by root", info.Name())
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is writable by other users", info.Name())
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package custom
+
+import (
+	"errors"
+	"os"
+)
+
+// lookupOwner is not supported on Windows, where files have ACLs instead.
+func lookupOwner(info os.FileInfo) (string, string, error) {
+	return "", "", errors.New("file owner is not supported on Windows")
+}
+
+// policyOwner always succeeds on Windows, as there is no root helper.
+var policyOwner = func(info os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,204 @@
+package custom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/samber/lo"
+)
+
+// packageManagers lists the commands used to query installed packages.
+var packageManagers = []string{"dpkg-query", "rpm", "pacman"}
+
+// activeStates are the states reported by `systemctl is-active`, while all
+// other states are checked with `systemctl is-enabled`.
+var activeStates = []string{"active", "inactive", "failed", "activating", "deactivating", "reloading"}
+
+// runnable returns whether the rule of def can be evaluated on this system,
+// and the reason if not.
+func runnable(def Definition) (bool, string) {
+	switch def.Type {
+	case TypeCommand:
+		if _, err := lookPath(def.Command[0]); err != nil {
+			return false, fmt.Sprintf("%s is not installed, check cannot run", def.Command[0])
+		}
+	case TypeSystemd:
+		if _, err := lookPath("systemctl"); err != nil {
+			return false, "systemd is not available, check cannot run"
+		}
+	case TypePackage:
+		if !lo.ContainsBy(packageManagers, func(manager string) bool {
+			_, err := lookPath(manager)
+			return err == nil
+		}) {
+			return false, "No supported package manager found, check cannot run"
+		}
+	case TypeSysctl:
+		if runtime.GOOS != "linux" {
+			return false, "Kernel parameters are only available on Linux, check cannot run"
+		}
+	}
+	return true, ""
+}
+
+// evaluate evaluates the rule of def and returns whether it matched, along
+// with what it observed.
+func evaluate(ctx context.Context, def Definition) (bool, map[string]string, error) {
+	switch def.Type {
+	case TypeFileContains, TypeFileRegex:
+		return evaluateFile(def)
+	case TypeCommand:
+		return evaluateCommand(ctx, def)
+	case TypeSysctl:
+		return evaluateSysctl(def)
+	case TypePackage:
+		return evaluatePackage(ctx, def)
+	case TypeSystemd:
+		return evaluateSystemd(ctx, def)
+	case TypeFileMode:
+		return evaluateFileMode(def)
+	}
+	return false, nil, fmt.Errorf("unknown type %q", def.Type)
+}
+
+func evaluateFile(def Definition) (bool, map[string]string, error) {
+	paths, err := filepathGlob(def.Path)
+	if err != nil {
+		return false, nil, err
+	}
+	match := func(content string) bool {
+		return strings.Contains(content, def.Pattern)
+	}
+	if def.Type == TypeFileRegex {
+		re, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			return false, nil, err
+		}
+		match = re.MatchString
+	}
+
+	for _, path := range paths {
+		content, err := shared.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if match(string(content)) {
+			return true, map[string]string{path: "matches"}, nil
+		}
+	}
+	return false, nil, nil
+}
+
+func evaluateCommand(ctx context.Context, def Definition) (bool, map[string]string, error) {
+	output, err := shared.RunCommandContext(ctx, def.Command[0], def.Command[1:]...)
+	if ctx.Err() != nil {
+		return false, nil, ctx.Err()
+	}
+	if err != nil {
+		return false, map[string]string{"exit": err.Error()}, nil
+	}
+	if def.Pattern == "" {
+		return true, nil, nil
+	}
+	re, err := regexp.Compile(def.Pattern)
+	if err != nil {
+		return false, nil, err
+	}
+	return re.MatchString(output), nil, nil
+}
+
+func evaluateSysctl(def Definition) (bool, map[string]string, error) {
+	content, err := shared.ReadFile("/proc/sys/" + strings.ReplaceAll(def.Key, ".", "/"))
+	if err != nil {
+		return false, nil, fmt.Errorf("cannot read kernel parameter %s: %w", def.Key, err)
+	}
+	value := strings.Join(strings.Fields(string(content)), " ")
+	return value == strings.Join(strings.Fields(def.Value), " "), map[string]string{def.Key: value}, nil
+}
+
+func evaluatePackage(ctx context.Context, def Definition) (bool, map[string]string, error) {
+	for _, manager := range packageManagers {
+		if _, err := lookPath(manager); err != nil {
+			continue
+		}
+		var installed bool
+		switch manager {
+		case "dpkg-query":
+			output, err := shared.RunCommandContext(ctx, manager, "-W", "-f=${Status}", def.Package)
+			installed = err == nil && strings.Contains(output, "install ok installed")
+		case "rpm":
+			_, err := shared.RunCommandContext(ctx, manager, "-q", def.Package)
+			installed = err == nil
+		case "pacman":
+			_, err := shared.RunCommandContext(ctx, manager, "-Q", def.Package)
+			installed = err == nil
+		}
+		if ctx.Err() != nil {
+			return false, nil, ctx.Err()
+		}
+		if installed {
+			return true, map[string]string{def.Package: "installed via " + manager}, nil
+		}
+	}
+	return false, map[string]string{def.Package: "not installed"}, nil
+}
+
+func evaluateSystemd(ctx context.Context, def Definition) (bool, map[string]string, error) {
+	verb := "is-enabled"
+	if lo.Contains(activeStates, def.State) {
+		verb = "is-active"
+	}
+	// systemctl exits with an error for states other than active and
+	// enabled, so only the output is relevant.
+	output, _ := shared.RunCommandContext(ctx, "systemctl", verb, def.Unit)
+	if ctx.Err() != nil {
+		return false, nil, ctx.Err()
+	}
+	state := strings.TrimSpace(output)
+	if state == "" {
+		return false, nil, fmt.Errorf("cannot determine the state of %s", def.Unit)
+	}
+	return state == def.State, map[string]string{def.Unit: state}, nil
+}
+
+func evaluateFileMode(def Definition) (bool, map[string]string, error) {
+	info, err := osStat(def.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, map[string]string{def.Path: "missing"}, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+
+	matched := true
+	evidence := map[string]string{"mode": fmt.Sprintf("%04o", info.Mode().Perm())}
+	if def.Mode != "" {
+		allowed, err := strconv.ParseUint(def.Mode, 8, 32)
+		if err != nil {
+			return false, nil, err
+		}
+		matched = uint64(info.Mode().Perm())&^allowed == 0
+	}
+	if def.Owner != "" || def.Group != "" {
+		owner, group, err := fileOwner(info)
+		if err != nil {
+			return false, nil, err
+		}
+		evidence["owner"] = owner
+		evidence["group"] = group
+		if def.Owner != "" && owner != def.Owner {
+			matched = false
+		}
+		if def.Group != "" && group != def.Group {
+			matched = false
+		}
+	}
+	return matched, evidence, nil
+}
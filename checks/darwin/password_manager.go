@@ -1,10 +1,12 @@
 package checks
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/samber/lo"
 )
 
@@ -16,7 +18,7 @@ func (pmc *PasswordManagerCheck) Name() string {
 	return "Password Manager Presence"
 }
 
-func (pmc *PasswordManagerCheck) Run() error {
+func (pmc *PasswordManagerCheck) Run(ctx context.Context) error {
 	appNames := []string{
 		"1Password.app",
 		"1Password 8.app",
@@ -111,6 +113,16 @@ func (pmc *PasswordManagerCheck) FailedMessage() string {
 	return "No password manager found"
 }
 
+func (pmc *PasswordManagerCheck) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityLow,
+		Category: "credentials",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Install a password manager such as 1Password, Bitwarden or KeePassXC.",
+		},
+	}
+}
+
 func (pmc *PasswordManagerCheck) RequiresRoot() bool {
 	return false
 }
@@ -1,9 +1,11 @@
 package checks
 
 import (
+	"context"
 	"os/exec"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 	"github.com/samber/lo"
@@ -19,12 +21,12 @@ func (f *ApplicationUpdates) Name() string {
 	return "Apps are up to date"
 }
 
-func (f *ApplicationUpdates) checkUpdates() (bool, string) {
+func (f *ApplicationUpdates) checkUpdates(ctx context.Context) (bool, string) {
 	updates := []string{}
 
 	// Check flatpak
 	if _, err := lookPath("flatpak"); err == nil {
-		output, err := shared.RunCommand("flatpak", "remote-ls", "--updates")
+		output, err := shared.RunCommandContext(ctx, "flatpak", "remote-ls", "--updates")
 		log.WithField("output", string(output)).Debug("Flatpak updates")
 		if err == nil && len(output) > 0 {
 			updates = append(updates, "Flatpak")
@@ -33,7 +35,7 @@ func (f *ApplicationUpdates) checkUpdates() (bool, string) {
 
 	// Check apt
 	if _, err := lookPath("apt"); err == nil {
-		output, err := shared.RunCommand("apt", "list", "--upgradable")
+		output, err := shared.RunCommandContext(ctx, "apt", "list", "--upgradable")
 		log.WithField("output", string(output)).Debug("APT updates")
 		if err == nil && len(output) > 0 && strings.Contains(string(output), "upgradable") {
 			updates = append(updates, "APT")
@@ -42,7 +44,7 @@ func (f *ApplicationUpdates) checkUpdates() (bool, string) {
 
 	// Check dnf
 	if _, err := lookPath("dnf"); err == nil {
-		if _, err := shared.RunCommand("dnf", "check-update", "--quiet"); err != nil {
+		if _, err := shared.RunCommandContext(ctx, "dnf", "check-update", "--quiet"); err != nil {
 			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 100 {
 				updates = append(updates, "DNF")
 			}
@@ -51,7 +53,7 @@ func (f *ApplicationUpdates) checkUpdates() (bool, string) {
 
 	// Check pacman
 	if _, err := lookPath("pacman"); err == nil {
-		output, err := shared.RunCommand("pacman", "-Qu")
+		output, err := shared.RunCommandContext(ctx, "pacman", "-Qu")
 		log.WithField("output", string(output)).Debug("Pacman updates")
 		if err == nil && len(output) > 0 {
 			updates = append(updates, "Pacman")
@@ -60,7 +62,7 @@ func (f *ApplicationUpdates) checkUpdates() (bool, string) {
 
 	// Check snap
 	if _, err := lookPath("snap"); err == nil {
-		output, err := shared.RunCommand("snap", "refresh", "--list")
+		output, err := shared.RunCommandContext(ctx, "snap", "refresh", "--list")
 		log.WithField("output", string(output)).Debug("Snap updates")
 		if err == nil && len(output) > 0 && !strings.Contains(string(output), "All snaps up to date.") {
 			updates = append(updates, "Snap")
@@ -75,9 +77,9 @@ func (f *ApplicationUpdates) checkUpdates() (bool, string) {
 }
 
 // Run executes the check
-func (f *ApplicationUpdates) Run() error {
+func (f *ApplicationUpdates) Run(ctx context.Context) error {
 	var ok bool
-	ok, f.details = f.checkUpdates()
+	ok, f.details = f.checkUpdates(ctx)
 	f.passed = ok
 	return nil
 }
@@ -107,6 +109,22 @@ func (f *ApplicationUpdates) FailedMessage() string {
 	return "Some apps are out of date"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *ApplicationUpdates) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "updates",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Install pending updates with your package manager, including Flatpak and Snap apps.",
+			check.FamilyDebian:  "Run `sudo apt update && sudo apt upgrade`, then update Flatpak and Snap apps if you use them.",
+			check.FamilyFedora:  "Run `sudo dnf upgrade`, then update Flatpak and Snap apps if you use them.",
+			check.FamilyArch:    "Run `sudo pacman -Syu`, then update Flatpak and Snap apps if you use them.",
+			check.FamilySUSE:    "Run `sudo zypper update`, then update Flatpak and Snap apps if you use them.",
+			check.FamilyNixOS:   "Run `sudo nixos-rebuild switch --upgrade`.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *ApplicationUpdates) RequiresRoot() bool {
 	return false
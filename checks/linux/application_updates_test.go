@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"testing"
 
 	"github.com/ParetoSecurity/agent/shared"
@@ -48,7 +49,7 @@ func TestCheckUpdates(t *testing.T) {
 				return file, nil
 			}
 			su := &ApplicationUpdates{}
-			passed, detail := su.checkUpdates()
+			passed, detail := su.checkUpdates(context.Background())
 			assert.Equal(t, tt.expectedPassed, passed)
 			assert.Equal(t, tt.expectedDetail, detail)
 			assert.NotEmpty(t, su.UUID())
@@ -97,7 +98,7 @@ func TestApplicationUpdates_Run(t *testing.T) {
 				return file, nil
 			}
 			su := &ApplicationUpdates{}
-			err := su.Run()
+			err := su.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, su.Passed())
 			assert.Equal(t, tt.expectedDetail, su.Status())
@@ -2,9 +2,11 @@
 package checks
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 )
 
@@ -12,16 +14,21 @@ import (
 type Autologin struct {
 	passed bool
 	status string
+	source string
 }
 
+// autologinDconfKey is the dconf key that turns on automatic login in GNOME.
+const autologinDconfKey = "/org/gnome/login-screen/enable-automatic-login"
+
 // Name returns the name of the check
 func (f *Autologin) Name() string {
 	return "Automatic login is disabled"
 }
 
 // Run executes the check
-func (f *Autologin) Run() error {
+func (f *Autologin) Run(ctx context.Context) error {
 	f.passed = true
+	f.source = ""
 
 	// Check KDE (SDDM) autologin
 	sddmFiles, _ := filepath.Glob("/etc/sddm.conf.d/*.conf")
@@ -31,6 +38,7 @@ func (f *Autologin) Run() error {
 			if strings.Contains(string(content), "Autologin=true") {
 				f.passed = false
 				f.status = "Autologin=true in SDDM is enabled"
+				f.source = file
 				return nil
 			}
 		}
@@ -41,6 +49,7 @@ func (f *Autologin) Run() error {
 		if strings.Contains(string(content), "Autologin=true") {
 			f.passed = false
 			f.status = "Autologin=true in SDDM is enabled"
+			f.source = "/etc/sddm.conf"
 			return nil
 		}
 	}
@@ -52,16 +61,18 @@ func (f *Autologin) Run() error {
 			if strings.Contains(string(content), "AutomaticLoginEnable=true") {
 				f.passed = false
 				f.status = "AutomaticLoginEnable=true in GDM is enabled"
+				f.source = path
 				return nil
 			}
 		}
 	}
 
 	// Check GNOME (GDM) autologin using dconf
-	output, err := shared.RunCommand("dconf", "read", "/org/gnome/login-screen/enable-automatic-login")
+	output, err := shared.RunCommandContext(ctx, "dconf", "read", autologinDconfKey)
 	if err == nil && strings.TrimSpace(string(output)) == "true" {
 		f.passed = false
 		f.status = "Automatic login is enabled in GNOME"
+		f.source = "dconf"
 		return nil
 	}
 
@@ -93,6 +104,20 @@ func (f *Autologin) FailedMessage() string {
 	return "Automatic login is on"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *Autologin) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "authentication",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Disable automatic login in the settings of your display manager.",
+			check.FamilyDebian:  "Set AutomaticLoginEnable=false in /etc/gdm3/custom.conf, or Autologin=false in /etc/sddm.conf, and restart your session.",
+			check.FamilyFedora:  "Set AutomaticLoginEnable=false in /etc/gdm/custom.conf, or Autologin=false in /etc/sddm.conf, and restart your session.",
+			check.FamilyNixOS:   "Set services.displayManager.autoLogin.enable = false in your NixOS configuration and rebuild.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *Autologin) RequiresRoot() bool {
 	return false
@@ -105,3 +130,44 @@ func (f *Autologin) Status() string {
 	}
 	return f.PassedMessage()
 }
+
+// Plan returns the steps Apply takes to turn off automatic login
+func (f *Autologin) Plan() []string {
+	switch f.source {
+	case "":
+		return nil
+	case "dconf":
+		return []string{"Run `dconf write " + autologinDconfKey + " false`"}
+	}
+	return []string{"Replace Autologin=true and AutomaticLoginEnable=true with false in " + f.source}
+}
+
+// Apply turns off automatic login where the last run found it turned on
+func (f *Autologin) Apply(ctx context.Context) error {
+	switch f.source {
+	case "":
+		return nil
+	case "dconf":
+		_, err := shared.RunCommandContext(ctx, "dconf", "write", autologinDconfKey, "false")
+		return err
+	}
+
+	content, err := shared.ReadFile(f.source)
+	if err != nil {
+		return err
+	}
+	info, err := osStat(f.source)
+	if err != nil {
+		return err
+	}
+	fixed := strings.NewReplacer(
+		"Autologin=true", "Autologin=false",
+		"AutomaticLoginEnable=true", "AutomaticLoginEnable=false",
+	).Replace(string(content))
+	return osWriteFile(f.source, []byte(fixed), info.Mode().Perm())
+}
+
+// FixRequiresRoot returns whether Apply has to run as root
+func (f *Autologin) FixRequiresRoot() bool {
+	return f.source != "dconf"
+}
@@ -1,6 +1,8 @@
 package checks
 
 import (
+	"context"
+	"os"
 	"testing"
 
 	"github.com/ParetoSecurity/agent/shared"
@@ -73,7 +75,7 @@ func TestAutologin_Run(t *testing.T) {
 			})
 
 			a := &Autologin{}
-			err := a.Run()
+			err := a.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, a.Passed())
 			assert.Equal(t, tt.expectedStatus, a.Status())
@@ -130,3 +132,37 @@ func TestAutologin_PassedMessage(t *testing.T) {
 		t.Errorf("Expected PassedMessage %s, got %s", expectedPassedMessage, a.PassedMessage())
 	}
 }
+
+func TestAutologin_Apply(t *testing.T) {
+	osStatMock = func(file string) (os.FileInfo, error) { return os.Stat(".") }
+	defer func() { osStatMock = nil; osWriteFileMock = nil }()
+
+	var written string
+	osWriteFileMock = func(file string, data []byte, perm os.FileMode) error {
+		assert.Equal(t, "/etc/gdm3/custom.conf", file)
+		written = string(data)
+		return nil
+	}
+
+	shared.ReadFileMocks = map[string]string{
+		"/etc/gdm3/custom.conf": "[daemon]\nAutomaticLoginEnable=true\nAutomaticLogin=user\n",
+	}
+	shared.RunCommandMocks = nil
+	a := &Autologin{}
+	assert.NoError(t, a.Run(context.Background()))
+	assert.False(t, a.Passed())
+	assert.Equal(t, []string{"Replace Autologin=true and AutomaticLoginEnable=true with false in /etc/gdm3/custom.conf"}, a.Plan())
+	assert.True(t, a.FixRequiresRoot())
+	assert.NoError(t, a.Apply(context.Background()))
+	assert.Equal(t, "[daemon]\nAutomaticLoginEnable=false\nAutomaticLogin=user\n", written)
+
+	shared.ReadFileMocks = map[string]string{}
+	shared.RunCommandMocks = convertCommandMapToMocks(map[string]string{
+		"dconf read /org/gnome/login-screen/enable-automatic-login":        "true",
+		"dconf write /org/gnome/login-screen/enable-automatic-login false": "",
+	})
+	a = &Autologin{}
+	assert.NoError(t, a.Run(context.Background()))
+	assert.False(t, a.FixRequiresRoot())
+	assert.NoError(t, a.Apply(context.Background()))
+}
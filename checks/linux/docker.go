@@ -1,8 +1,10 @@
 package checks
 
 import (
+	"context"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/samber/lo"
 )
@@ -18,8 +20,8 @@ func (f *DockerAccess) Name() string {
 }
 
 // Run executes the check
-func (f *DockerAccess) Run() error {
-	output, err := shared.RunCommand("docker", "info", "--format", "{{.SecurityOptions}}")
+func (f *DockerAccess) Run(ctx context.Context) error {
+	output, err := shared.RunCommandContext(ctx, "docker", "info", "--format", "{{.SecurityOptions}}")
 	if err != nil || lo.IsEmpty(output) {
 		f.passed = false
 		f.status = "Failed to get Docker info"
@@ -44,9 +46,14 @@ func (f *DockerAccess) Passed() bool {
 
 // CanRun returns whether the check can run
 func (f *DockerAccess) IsRunnable() bool {
+	return f.IsRunnableContext(context.Background())
+}
+
+// IsRunnableContext is like IsRunnable, but kills docker when ctx is done
+func (f *DockerAccess) IsRunnableContext(ctx context.Context) bool {
 
 	// Check if Docker is installed
-	out, _ := shared.RunCommand("docker", "version")
+	out, _ := shared.RunCommandContext(ctx, "docker", "version")
 	if !strings.Contains(out, "Version") {
 		f.status = "Docker is not installed"
 		return false
@@ -78,6 +85,18 @@ func (f *DockerAccess) FailedMessage() string {
 	return "Docker is not running in rootless mode"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *DockerAccess) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityMedium,
+		Category: "access",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Switch Docker to rootless mode with `dockerd-rootless-setuptool.sh install` and remove your user from the docker group.",
+			check.FamilyNixOS:   "Set virtualisation.docker.rootless.enable = true in your NixOS configuration and rebuild.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *DockerAccess) RequiresRoot() bool {
 	return false
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"testing"
 
 	"github.com/ParetoSecurity/agent/shared"
@@ -41,7 +42,7 @@ func TestDockerAccess_Run(t *testing.T) {
 				"docker info --format {{.SecurityOptions}}": tt.commandOutput,
 			})
 			dockerAccess := &DockerAccess{}
-			err := dockerAccess.Run()
+			err := dockerAccess.Run(context.Background())
 
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, dockerAccess.passed)
@@ -2,9 +2,12 @@ package checks
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"strconv"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 )
@@ -20,8 +23,8 @@ func (f *Firewall) Name() string {
 	return "Firewall is on"
 }
 
-func (f *Firewall) checkUFW() bool {
-	output, err := shared.RunCommand("ufw", "status")
+func (f *Firewall) checkUFW(ctx context.Context) bool {
+	output, err := shared.RunCommandContext(ctx, "ufw", "status")
 	if err != nil {
 		log.WithError(err).WithField("output", output).Warn("Failed to check UFW status")
 		return false
@@ -30,8 +33,8 @@ func (f *Firewall) checkUFW() bool {
 	return strings.Contains(output, "Status: active")
 }
 
-func (f *Firewall) checkFirewalld() bool {
-	output, err := shared.RunCommand("systemctl", "is-active", "firewalld")
+func (f *Firewall) checkFirewalld(ctx context.Context) bool {
+	output, err := shared.RunCommandContext(ctx, "systemctl", "is-active", "firewalld")
 	if err != nil {
 		log.WithError(err).WithField("output", output).Warn("Failed to check firewalld status")
 		return false
@@ -41,8 +44,8 @@ func (f *Firewall) checkFirewalld() bool {
 }
 
 // checkIptables checks if iptables is active
-func (f *Firewall) checkIptables() bool {
-	output, err := shared.RunCommand("iptables", "-L", "INPUT", "--line-numbers")
+func (f *Firewall) checkIptables(ctx context.Context) bool {
+	output, err := shared.RunCommandContext(ctx, "iptables", "-L", "INPUT", "--line-numbers")
 	if err != nil {
 		log.WithError(err).WithField("output", output).Warn("Failed to check iptables status")
 		return false
@@ -126,7 +129,7 @@ func (f *Firewall) checkIptables() bool {
 }
 
 // Run executes the check
-func (f *Firewall) Run() error {
+func (f *Firewall) Run(ctx context.Context) error {
 	if f.status == "Neither ufw, firewalld nor iptables are present, check cannot run" {
 		f.passed = false
 		return nil
@@ -135,27 +138,28 @@ func (f *Firewall) Run() error {
 	if f.RequiresRoot() && !shared.IsRoot() {
 		log.Debug("Running check via root helper")
 		// Run as root
-		passed, err := shared.RunCheckViaHelper(f.UUID())
+		res, err := shared.RunCheckViaHelper(ctx, f.UUID())
 		if err != nil {
 			log.WithError(err).Warn("Failed to run check via root helper")
 			return err
 		}
-		f.passed = passed
+		f.passed = res.Passed()
+		f.status = res.Reason
 		return nil
 	}
 
 	log.Debug("Running check directly")
 	f.passed = false
 	if !f.passed {
-		f.passed = f.checkUFW()
+		f.passed = f.checkUFW(ctx)
 	}
 
 	if !f.passed {
-		f.passed = f.checkFirewalld()
+		f.passed = f.checkFirewalld(ctx)
 	}
 
 	if !f.passed {
-		f.passed = f.checkIptables()
+		f.passed = f.checkIptables(ctx)
 	}
 
 	if !f.passed {
@@ -213,6 +217,22 @@ func (f *Firewall) FailedMessage() string {
 	return "Firewall is off"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *Firewall) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityCritical,
+		Category: "network",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Enable a firewall such as ufw or firewalld.",
+			check.FamilyDebian:  "Run `sudo apt install ufw && sudo ufw enable`.",
+			check.FamilyFedora:  "Run `sudo systemctl enable --now firewalld`.",
+			check.FamilyArch:    "Run `sudo pacman -S ufw && sudo systemctl enable --now ufw && sudo ufw enable`.",
+			check.FamilySUSE:    "Run `sudo systemctl enable --now firewalld`.",
+			check.FamilyNixOS:   "Set networking.firewall.enable = true in your NixOS configuration and rebuild.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *Firewall) RequiresRoot() bool {
 	return true
@@ -229,3 +249,42 @@ func (f *Firewall) Status() string {
 
 	return f.FailedMessage()
 }
+
+// Plan returns the steps Apply takes to turn on the firewall
+func (f *Firewall) Plan() []string {
+	if _, err := lookPath("ufw"); err == nil {
+		plan := []string{}
+		if (&SSHConfigCheck{}).IsRunnable() {
+			plan = append(plan, "Allow incoming SSH connections by running `ufw allow ssh`")
+		}
+		return append(plan, "Enable ufw by running `ufw --force enable`")
+	}
+	if _, err := lookPath("firewalld"); err == nil {
+		return []string{"Enable and start firewalld by running `systemctl enable --now firewalld`"}
+	}
+	return nil
+}
+
+// Apply turns on ufw or, if it is not installed, firewalld
+func (f *Firewall) Apply(ctx context.Context) error {
+	if _, err := lookPath("ufw"); err == nil {
+		// Keep remote sessions working, ufw denies incoming connections by default
+		if (&SSHConfigCheck{}).IsRunnableContext(ctx) {
+			if _, err := shared.RunCommandContext(ctx, "ufw", "allow", "ssh"); err != nil {
+				return err
+			}
+		}
+		_, err := shared.RunCommandContext(ctx, "ufw", "--force", "enable")
+		return err
+	}
+	if _, err := lookPath("firewalld"); err == nil {
+		_, err := shared.RunCommandContext(ctx, "systemctl", "enable", "--now", "firewalld")
+		return err
+	}
+	return errors.New("neither ufw nor firewalld is installed")
+}
+
+// FixRequiresRoot returns whether Apply has to run as root
+func (f *Firewall) FixRequiresRoot() bool {
+	return true
+}
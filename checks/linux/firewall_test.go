@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"testing"
 
 	"github.com/ParetoSecurity/agent/shared"
@@ -40,7 +41,7 @@ func TestCheckUFW(t *testing.T) {
 				"ufw status": tt.mockOutput,
 			})
 			f := &Firewall{}
-			result := f.checkUFW()
+			result := f.checkUFW(context.Background())
 			assert.Equal(t, tt.expectedResult, result)
 		})
 	}
@@ -80,7 +81,7 @@ func TestCheckFirewalld(t *testing.T) {
 			})
 
 			f := &Firewall{}
-			result := f.checkFirewalld()
+			result := f.checkFirewalld(context.Background())
 			assert.Equal(t, tt.expectedResult, result)
 			assert.NotEmpty(t, f.UUID())
 			assert.True(t, f.RequiresRoot())
@@ -127,7 +128,7 @@ func TestFirewall_Run(t *testing.T) {
 			})
 
 			f := &Firewall{}
-			err := f.Run()
+			err := f.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, f.Passed())
 			assert.Equal(t, tt.expectedStatus, f.Status())
@@ -249,7 +250,7 @@ num  target     prot opt source               destination
 				"iptables -L INPUT --line-numbers": tt.mockOutput,
 			})
 			f := &Firewall{}
-			result := f.checkIptables()
+			result := f.checkIptables(context.Background())
 			assert.Equal(t, tt.expectedResult, result)
 		})
 	}
@@ -331,8 +332,79 @@ func TestFirewall_Run_NoFirewallCommands(t *testing.T) {
 		passed: false,
 	}
 
-	err := f.Run()
+	err := f.Run(context.Background())
 	assert.NoError(t, err)
 	assert.False(t, f.Passed())
 	assert.Equal(t, "Neither ufw, firewalld nor iptables are present, check cannot run", f.status)
 }
+
+func TestFirewall_Apply(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockLookPath func(file string) (string, error)
+		mockCmds     map[string]string
+		expectedPlan []string
+		expectError  bool
+	}{
+		{
+			name: "ufw with SSH running",
+			mockLookPath: func(file string) (string, error) {
+				if file == "ufw" {
+					return "/usr/sbin/ufw", nil
+				}
+				return "", assert.AnError
+			},
+			mockCmds: map[string]string{
+				"systemctl is-active sshd": "active",
+				"ufw allow ssh":            "Rule added",
+				"ufw --force enable":       "Firewall is active and enabled on system startup",
+			},
+			expectedPlan: []string{
+				"Allow incoming SSH connections by running `ufw allow ssh`",
+				"Enable ufw by running `ufw --force enable`",
+			},
+		},
+		{
+			name: "firewalld",
+			mockLookPath: func(file string) (string, error) {
+				if file == "firewalld" {
+					return "/usr/sbin/firewalld", nil
+				}
+				return "", assert.AnError
+			},
+			mockCmds: map[string]string{
+				"systemctl enable --now firewalld": "",
+			},
+			expectedPlan: []string{"Enable and start firewalld by running `systemctl enable --now firewalld`"},
+		},
+		{
+			name: "Only iptables",
+			mockLookPath: func(file string) (string, error) {
+				if file == "iptables" {
+					return "/usr/sbin/iptables", nil
+				}
+				return "", assert.AnError
+			},
+			expectedPlan: nil,
+			expectError:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookPathMock = tt.mockLookPath
+			defer func() { lookPathMock = nil }()
+			shared.RunCommandMocks = convertCommandMapToMocks(tt.mockCmds)
+
+			f := &Firewall{}
+			assert.Equal(t, tt.expectedPlan, f.Plan())
+			err := f.Apply(context.Background())
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.True(t, f.FixRequiresRoot())
+		})
+	}
+}
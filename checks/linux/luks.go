@@ -3,10 +3,12 @@ package checks
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"os"
 	"os/exec"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 )
@@ -50,23 +52,35 @@ func (f *EncryptingFS) FailedMessage() string {
 	return "Block device encryption is disabled"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *EncryptingFS) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityCritical,
+		Category: "system-integrity",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Reinstall the system with full disk encryption (LUKS) enabled, after backing up your data.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *EncryptingFS) RequiresRoot() bool {
 	return true
 }
 
 // Run executes the check
-func (f *EncryptingFS) Run() error {
+func (f *EncryptingFS) Run(ctx context.Context) error {
 
 	if f.RequiresRoot() && !shared.IsRoot() {
 		log.Debug("Running check via root helper")
 		// Run as root
-		passed, err := shared.RunCheckViaHelper(f.UUID())
+		res, err := shared.RunCheckViaHelper(ctx, f.UUID())
 		if err != nil {
 			log.WithError(err).Warn("Failed to run check via root helper")
 			return err
 		}
-		f.passed = passed
+		f.passed = res.Passed()
+		f.status = res.Reason
 		return nil
 	}
 	log.Debug("Running check directly")
@@ -89,7 +103,7 @@ func (f *EncryptingFS) Run() error {
 		crypttab.Close()
 	}
 	log.WithField("encryptedDevices", encryptedDevices).Debug("Found encrypted devices")
-	cmd := exec.Command("blkid")
+	cmd := exec.CommandContext(ctx, "blkid")
 	output, err := cmd.Output()
 	if err != nil {
 		log.WithError(err).Warn("Failed to run blkid")
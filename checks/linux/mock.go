@@ -128,3 +128,26 @@ func convertCommandMapToMocks(commandMap map[string]string) []shared.RunCommandM
 	}
 	return mocks
 }
+
+var osWriteFileMock func(file string, data []byte, perm os.FileMode) error
+
+// osWriteFile writes data to the specified file, creating it if necessary.
+// In testing mode, it delegates to osWriteFileMock so that fixes never touch
+// the system they run on.
+func osWriteFile(file string, data []byte, perm os.FileMode) error {
+	if testing.Testing() && osWriteFileMock != nil {
+		return osWriteFileMock(file, data, perm)
+	}
+	return os.WriteFile(file, data, perm)
+}
+
+var osRemoveMock func(file string) error
+
+// osRemove removes the specified file.
+// In testing mode, it delegates to osRemoveMock for controlled behavior.
+func osRemove(file string) error {
+	if testing.Testing() && osRemoveMock != nil {
+		return osRemoveMock(file)
+	}
+	return os.Remove(file)
+}
@@ -1,10 +1,12 @@
 package checks
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 )
@@ -17,11 +19,11 @@ func (pmc *PasswordManagerCheck) Name() string {
 	return "Password Manager Presence"
 }
 
-func (pmc *PasswordManagerCheck) isManagerInstalled() bool {
+func (pmc *PasswordManagerCheck) isManagerInstalled(ctx context.Context) bool {
 	passwordManagers := []string{"1password", "bitwarden", "dashlane", "keepassx", "keepassxc"}
 
 	for _, pwdManager := range passwordManagers {
-		if isPackageInstalled(pwdManager) {
+		if isPackageInstalled(ctx, pwdManager) {
 			log.Debug("Password manager found: " + pwdManager)
 			return true
 		}
@@ -29,11 +31,11 @@ func (pmc *PasswordManagerCheck) isManagerInstalled() bool {
 	return false
 }
 
-func (pmc *PasswordManagerCheck) Run() error {
+func (pmc *PasswordManagerCheck) Run(ctx context.Context) error {
 
 	// Check for password managers installed via package managers
 
-	if pmc.isManagerInstalled() {
+	if pmc.isManagerInstalled(ctx) {
 		pmc.passed = true
 		return nil
 	}
@@ -76,27 +78,27 @@ func checkForBrowserExtensions() bool {
 	return false
 }
 
-func isPackageInstalled(pkgName string) bool {
+func isPackageInstalled(ctx context.Context, pkgName string) bool {
 	pkgManagers := make(map[string]string)
 
 	// Check which package managers are available
-	if _, err := shared.RunCommand("which", "dpkg"); err == nil {
+	if _, err := shared.RunCommandContext(ctx, "which", "dpkg"); err == nil {
 		pkgManagers["apt"] = "dpkg -l"
 		log.Debug("apt package manager found")
 	}
-	if _, err := shared.RunCommand("which", "snap"); err == nil {
+	if _, err := shared.RunCommandContext(ctx, "which", "snap"); err == nil {
 		pkgManagers["snap"] = "snap list"
 		log.Debug("snap package manager found")
 	}
-	if _, err := shared.RunCommand("which", "yum"); err == nil {
+	if _, err := shared.RunCommandContext(ctx, "which", "yum"); err == nil {
 		pkgManagers["yum"] = "yum list installed"
 		log.Debug("yum package manager found")
 	}
-	if _, err := shared.RunCommand("which", "flatpak"); err == nil {
+	if _, err := shared.RunCommandContext(ctx, "which", "flatpak"); err == nil {
 		pkgManagers["flatpak"] = "flatpak list"
 		log.Debug("flatpak package manager found")
 	}
-	if _, err := shared.RunCommand("which", "pacman"); err == nil {
+	if _, err := shared.RunCommandContext(ctx, "which", "pacman"); err == nil {
 		pkgManagers["pacman"] = "pacman -Q"
 		log.Debug("pacman package manager found")
 	}
@@ -107,7 +109,7 @@ func isPackageInstalled(pkgName string) bool {
 		cached, ok := shared.GetCache(cacheKey)
 		if !ok {
 			var err error
-			cached, err = shared.RunCommand("sh", "-c", baseCmd)
+			cached, err = shared.RunCommandContext(ctx, "sh", "-c", baseCmd)
 			if err != nil {
 				continue
 			}
@@ -142,6 +144,16 @@ func (pmc *PasswordManagerCheck) FailedMessage() string {
 	return "No password manager found"
 }
 
+func (pmc *PasswordManagerCheck) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityLow,
+		Category: "credentials",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Install a password manager such as 1Password, Bitwarden or KeePassXC.",
+		},
+	}
+}
+
 func (pmc *PasswordManagerCheck) RequiresRoot() bool {
 	return false
 }
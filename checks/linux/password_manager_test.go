@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -110,7 +111,7 @@ func TestPasswordManagerCheck_Run_Linux(t *testing.T) {
 			shared.RunCommandMocks = convertCommandMapToMocks(tt.mockCommands)
 
 			pmc := &PasswordManagerCheck{}
-			status := pmc.isManagerInstalled()
+			status := pmc.isManagerInstalled(context.Background())
 			assert.Equal(t, tt.expectedPassed, status)
 		})
 	}
@@ -146,7 +147,7 @@ func TestPasswordManagerCheck_Run_BrowserExtensions(t *testing.T) {
 				}), nil
 			}
 			pmc := &PasswordManagerCheck{}
-			err := pmc.Run()
+			err := pmc.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, pmc.Passed())
 		})
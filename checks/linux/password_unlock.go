@@ -1,8 +1,10 @@
 package checks
 
 import (
+	"context"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 )
@@ -17,8 +19,8 @@ func (f *PasswordToUnlock) Name() string {
 	return "Password is required to unlock the screen"
 }
 
-func (f *PasswordToUnlock) checkGnome() bool {
-	out, err := shared.RunCommand("gsettings", "get", "org.gnome.desktop.screensaver", "lock-enabled")
+func (f *PasswordToUnlock) checkGnome(ctx context.Context) bool {
+	out, err := shared.RunCommandContext(ctx, "gsettings", "get", "org.gnome.desktop.screensaver", "lock-enabled")
 	if err != nil {
 		log.WithError(err).Debug("Failed to check GNOME screensaver settings")
 		return false
@@ -28,8 +30,8 @@ func (f *PasswordToUnlock) checkGnome() bool {
 	return result
 }
 
-func (f *PasswordToUnlock) checkKDE() bool {
-	out, err := shared.RunCommand("kreadconfig5", "--file", "kscreenlockerrc", "--group", "Daemon", "--key", "Autolock")
+func (f *PasswordToUnlock) checkKDE(ctx context.Context) bool {
+	out, err := shared.RunCommandContext(ctx, "kreadconfig5", "--file", "kscreenlockerrc", "--group", "Daemon", "--key", "Autolock")
 	if err != nil {
 		log.WithError(err).Debug("Failed to check KDE screenlocker settings")
 		return false
@@ -40,14 +42,14 @@ func (f *PasswordToUnlock) checkKDE() bool {
 }
 
 // Run executes the check
-func (f *PasswordToUnlock) Run() error {
+func (f *PasswordToUnlock) Run(ctx context.Context) error {
 	anyCheckPerformed := false
 	allChecksPassed := true
 
 	// Check if running GNOME
 	if _, err := lookPath("gsettings"); err == nil {
 		anyCheckPerformed = true
-		allChecksPassed = allChecksPassed && f.checkGnome()
+		allChecksPassed = allChecksPassed && f.checkGnome(ctx)
 	} else {
 		log.Debug("GNOME environment not detected for screensaver lock check")
 	}
@@ -55,7 +57,7 @@ func (f *PasswordToUnlock) Run() error {
 	// Check if running KDE
 	if _, err := lookPath("kreadconfig5"); err == nil {
 		anyCheckPerformed = true
-		allChecksPassed = allChecksPassed && f.checkKDE()
+		allChecksPassed = allChecksPassed && f.checkKDE(ctx)
 	} else {
 		log.Debug("KDE environment not detected for screensaver lock check")
 	}
@@ -90,6 +92,17 @@ func (f *PasswordToUnlock) FailedMessage() string {
 	return "Password after sleep or screensaver is off"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *PasswordToUnlock) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "authentication",
+		Remediation: map[string]string{
+			check.FamilyDefault: "GNOME: run `gsettings set org.gnome.desktop.screensaver lock-enabled true`. KDE: run `kwriteconfig5 --file kscreenlockerrc --group Daemon --key Autolock true`.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *PasswordToUnlock) RequiresRoot() bool {
 	return false
@@ -102,3 +115,35 @@ func (f *PasswordToUnlock) Status() string {
 	}
 	return f.FailedMessage()
 }
+
+// Plan returns the steps Apply takes to require a password to unlock the screen
+func (f *PasswordToUnlock) Plan() []string {
+	var plan []string
+	if _, err := lookPath("gsettings"); err == nil {
+		plan = append(plan, "Run `gsettings set org.gnome.desktop.screensaver lock-enabled true`")
+	}
+	if _, err := lookPath("kwriteconfig5"); err == nil {
+		plan = append(plan, "Run `kwriteconfig5 --file kscreenlockerrc --group Daemon --key Autolock true`")
+	}
+	return plan
+}
+
+// Apply turns on the screen lock of GNOME and KDE, whichever are installed
+func (f *PasswordToUnlock) Apply(ctx context.Context) error {
+	if _, err := lookPath("gsettings"); err == nil {
+		if _, err := shared.RunCommandContext(ctx, "gsettings", "set", "org.gnome.desktop.screensaver", "lock-enabled", "true"); err != nil {
+			return err
+		}
+	}
+	if _, err := lookPath("kwriteconfig5"); err == nil {
+		if _, err := shared.RunCommandContext(ctx, "kwriteconfig5", "--file", "kscreenlockerrc", "--group", "Daemon", "--key", "Autolock", "true"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FixRequiresRoot returns whether Apply has to run as root
+func (f *PasswordToUnlock) FixRequiresRoot() bool {
+	return false
+}
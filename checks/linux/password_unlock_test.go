@@ -1,6 +1,8 @@
 package checks
 
 import (
+	"context"
+	"errors"
 	"os/exec"
 	"slices"
 	"testing"
@@ -48,7 +50,7 @@ func TestCheckKDE(t *testing.T) {
 			}
 
 			f := &PasswordToUnlock{}
-			result := f.checkKDE()
+			result := f.checkKDE(context.Background())
 			assert.Equal(t, tt.expected, result)
 		})
 	}
@@ -93,7 +95,7 @@ func TestCheckGnome(t *testing.T) {
 			}
 
 			f := &PasswordToUnlock{}
-			result := f.checkGnome()
+			result := f.checkGnome(context.Background())
 			assert.Equal(t, tt.expected, result)
 			assert.NotEmpty(t, f.UUID())
 			assert.False(t, f.RequiresRoot())
@@ -168,7 +170,7 @@ func TestPasswordToUnlock_Run(t *testing.T) {
 			}
 
 			f := &PasswordToUnlock{}
-			err := f.Run()
+			err := f.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, f.Passed())
 			assert.Equal(t, tt.expectedStatus, f.Status())
@@ -223,3 +225,21 @@ func TestPasswordToUnlock_PassedMessage(t *testing.T) {
 		t.Errorf("Expected PassedMessage %s, got %s", expectedPassedMessage, f.PassedMessage())
 	}
 }
+
+func TestPasswordToUnlock_Apply(t *testing.T) {
+	lookPathMock = func(file string) (string, error) {
+		if file == "gsettings" {
+			return "/usr/bin/gsettings", nil
+		}
+		return "", errors.New("not found")
+	}
+	defer func() { lookPathMock = nil }()
+	shared.RunCommandMocks = convertCommandMapToMocks(map[string]string{
+		"gsettings set org.gnome.desktop.screensaver lock-enabled true": "",
+	})
+
+	f := &PasswordToUnlock{}
+	assert.Equal(t, []string{"Run `gsettings set org.gnome.desktop.screensaver lock-enabled true`"}, f.Plan())
+	assert.False(t, f.FixRequiresRoot())
+	assert.NoError(t, f.Apply(context.Background()))
+}
@@ -1,8 +1,10 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/ParetoSecurity/agent/check"
 	sharedchecks "github.com/ParetoSecurity/agent/checks/shared"
 	"github.com/caarlos0/log"
 )
@@ -18,7 +20,7 @@ func (f *Printer) Name() string {
 }
 
 // Run executes the check
-func (f *Printer) Run() error {
+func (f *Printer) Run(ctx context.Context) error {
 	f.passed = true
 	f.ports = make(map[int]string)
 
@@ -38,6 +40,15 @@ func (f *Printer) Run() error {
 	return nil
 }
 
+// Evidence returns the open ports found during the last run
+func (f *Printer) Evidence() map[string]string {
+	evidence := make(map[string]string)
+	for port, service := range f.ports {
+		evidence[fmt.Sprintf("tcp/%d", port)] = service
+	}
+	return evidence
+}
+
 // Passed returns the status of the check
 func (f *Printer) Passed() bool {
 	return f.passed
@@ -63,6 +74,18 @@ func (f *Printer) FailedMessage() string {
 	return "Sharing printers is on"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *Printer) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityMedium,
+		Category: "network",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Stop sharing printers with `sudo cupsctl --no-share-printers`.",
+			check.FamilyNixOS:   "Set services.printing.browsing = false and services.printing.defaultShared = false in your NixOS configuration and rebuild.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *Printer) RequiresRoot() bool {
 	return false
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"testing"
 
 	sharedchecks "github.com/ParetoSecurity/agent/checks/shared"
@@ -49,7 +50,7 @@ func TestPrinterRun(t *testing.T) {
 			sharedchecks.CheckPortMock = tt.mockCheckPort
 			printer := &Printer{}
 
-			err := printer.Run()
+			err := printer.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, printer.Passed())
 			assert.Equal(t, tt.expectedPorts, printer.ports)
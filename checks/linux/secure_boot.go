@@ -1,7 +1,10 @@
 package checks
 
 import (
+	"context"
 	"os"
+
+	"github.com/ParetoSecurity/agent/check"
 )
 
 // SecureBoot checks secure boot configuration.
@@ -16,7 +19,7 @@ func (f *SecureBoot) Name() string {
 }
 
 // Run executes the check
-func (f *SecureBoot) Run() error {
+func (f *SecureBoot) Run(ctx context.Context) error {
 
 	// Find and read the SecureBoot EFI variable
 	pattern := "/sys/firmware/efi/efivars/SecureBoot-*"
@@ -75,6 +78,17 @@ func (f *SecureBoot) FailedMessage() string {
 	return "SecureBoot is disabled"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *SecureBoot) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityMedium,
+		Category: "system-integrity",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Enable Secure Boot in the UEFI firmware settings of your computer.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *SecureBoot) RequiresRoot() bool {
 	return false
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -49,7 +50,7 @@ func TestSecureBoot_Run(t *testing.T) {
 				return tt.mockFiles[file], nil
 			}
 			sb := &SecureBoot{}
-			err := sb.Run()
+			err := sb.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, sb.Passed())
 			assert.Equal(t, tt.expectedStatus, sb.Status())
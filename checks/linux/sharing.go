@@ -1,8 +1,10 @@
 package checks
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/ParetoSecurity/agent/check"
 	sharedchecks "github.com/ParetoSecurity/agent/checks/shared"
 	"github.com/caarlos0/log"
 )
@@ -18,7 +20,7 @@ func (f *Sharing) Name() string {
 }
 
 // Run executes the check
-func (f *Sharing) Run() error {
+func (f *Sharing) Run(ctx context.Context) error {
 	f.passed = true
 	f.ports = make(map[int]string)
 
@@ -43,6 +45,15 @@ func (f *Sharing) Run() error {
 	return nil
 }
 
+// Evidence returns the open ports found during the last run
+func (f *Sharing) Evidence() map[string]string {
+	evidence := make(map[string]string)
+	for port, service := range f.ports {
+		evidence[fmt.Sprintf("tcp/%d", port)] = service
+	}
+	return evidence
+}
+
 // Passed returns the status of the check
 func (f *Sharing) Passed() bool {
 	return f.passed
@@ -68,6 +79,18 @@ func (f *Sharing) FailedMessage() string {
 	return "Sharing services found running "
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *Sharing) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "network",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Stop and disable file sharing services, for example `sudo systemctl disable --now smbd nfs-server`.",
+			check.FamilyNixOS:   "Disable services.samba and services.nfs.server in your NixOS configuration and rebuild.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *Sharing) RequiresRoot() bool {
 	return false
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"testing"
 
 	sharedchecks "github.com/ParetoSecurity/agent/checks/shared"
@@ -52,7 +53,7 @@ func TestSharing_Run(t *testing.T) {
 			sharedchecks.CheckPortMock = tt.mockFunc
 
 			sharing := &Sharing{}
-			err := sharing.Run()
+			err := sharing.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expected, sharing.Passed())
 			assert.NotEmpty(t, sharing.UUID())
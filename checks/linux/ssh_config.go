@@ -1,16 +1,36 @@
 package checks
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/caarlos0/log"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 )
 
+// sshdDropInPath is where the fix writes its hardened options. sshd uses the
+// first value it reads for an option, so the name sorts before other drop-ins.
+const sshdDropInPath = "/etc/ssh/sshd_config.d/00-paretosecurity.conf"
+
+// sshdHardening lists the sshd options the fix sets, keyed by the lowercase
+// names that sshd -T reports.
+var sshdHardening = []struct {
+	key    string
+	option string
+}{
+	{"passwordauthentication", "PasswordAuthentication no"},
+	{"permitrootlogin", "PermitRootLogin no"},
+	{"permitemptypasswords", "PermitEmptyPasswords no"},
+}
+
 type SSHConfigCheck struct {
-	passed bool
-	status string
+	passed  bool
+	status  string
+	options map[string]string
 }
 
 func (s *SSHConfigCheck) Name() string {
@@ -25,24 +45,27 @@ func (s *SSHConfigCheck) FailedMessage() string {
 	return "SSH configuration is not secure."
 }
 
-func (s *SSHConfigCheck) Run() error {
+func (s *SSHConfigCheck) Run(ctx context.Context) error {
 	if s.RequiresRoot() && !shared.IsRoot() {
 		log.Debug("Running check via root helper")
 		// Run as root
-		passed, err := shared.RunCheckViaHelper(s.UUID())
+		res, err := shared.RunCheckViaHelper(ctx, s.UUID())
 		if err != nil {
 			log.WithError(err).Warn("Failed to run check via root helper")
 			return err
 		}
-		s.passed = passed
+		s.passed = res.Passed()
+		s.status = res.Reason
+		s.options = res.Evidence
 		return nil
 	}
 	log.Debug("Running check directly")
 
 	s.passed = true
+	s.options = make(map[string]string)
 
 	//run sshd -T to get the sshd config
-	configRaw, err := shared.RunCommand("sshd", "-T")
+	configRaw, err := shared.RunCommandContext(ctx, "sshd", "-T")
 	log.WithField("check", s.Name()).Debugf("sshd -T output: %s", configRaw)
 	config := strings.ToLower(string(configRaw))
 	if err != nil {
@@ -53,45 +76,58 @@ func (s *SSHConfigCheck) Run() error {
 	if strings.Contains(config, "passwordauthentication yes") {
 		s.passed = false
 		s.status = "PasswordAuthentication is enabled"
+		s.options["passwordauthentication"] = "yes"
 	}
 	if strings.Contains(config, "permitrootlogin yes") {
 		s.passed = false
 		s.status = "Root login is enabled"
+		s.options["permitrootlogin"] = "yes"
 	}
 	if strings.Contains(config, "permitemptypasswords yes") {
 		s.passed = false
 		s.status = "Empty passwords are allowed"
+		s.options["permitemptypasswords"] = "yes"
 	}
 
 	return nil
 }
 
+// Evidence returns the insecure sshd options found during the last run
+func (s *SSHConfigCheck) Evidence() map[string]string {
+	return s.options
+}
+
 func (s *SSHConfigCheck) Passed() bool {
 	return s.passed
 }
 
 func (s *SSHConfigCheck) IsRunnable() bool {
+	return s.IsRunnableContext(context.Background())
+}
+
+// IsRunnableContext is like IsRunnable, but kills systemctl when ctx is done
+func (s *SSHConfigCheck) IsRunnableContext(ctx context.Context) bool {
 	s.status = "SSHd is not installed or not running"
 
 	// Check if sshd service is running via systemd
-	sshdStatus, _ := shared.RunCommand("systemctl", "is-active", "sshd")
+	sshdStatus, _ := shared.RunCommandContext(ctx, "systemctl", "is-active", "sshd")
 	if strings.TrimSpace(string(sshdStatus)) != "inactive" {
 		return true
 	}
 
 	// Check if ssh service is running via systemd
-	sshStatus, _ := shared.RunCommand("systemctl", "is-active", "ssh")
+	sshStatus, _ := shared.RunCommandContext(ctx, "systemctl", "is-active", "ssh")
 	if strings.TrimSpace(string(sshStatus)) != "inactive" {
 		return true
 	}
 	// Check if ssh socket service is enabled via systemd
-	sshSocketStatus, _ := shared.RunCommand("systemctl", "is-enabled", "sshd.socket")
+	sshSocketStatus, _ := shared.RunCommandContext(ctx, "systemctl", "is-enabled", "sshd.socket")
 	if strings.TrimSpace(string(sshSocketStatus)) == "enabled" {
 		return true
 	}
 
 	// Check if ssh socket service is enabled via systemd
-	sshSocketStatus, _ = shared.RunCommand("systemctl", "is-enabled", "ssh.socket")
+	sshSocketStatus, _ = shared.RunCommandContext(ctx, "systemctl", "is-enabled", "ssh.socket")
 	if strings.TrimSpace(string(sshSocketStatus)) == "enabled" {
 		return true
 	}
@@ -111,6 +147,77 @@ func (s *SSHConfigCheck) Status() string {
 	return s.status
 }
 
+func (s *SSHConfigCheck) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "remote-access",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Set PasswordAuthentication no, PermitRootLogin no and PermitEmptyPasswords no in /etc/ssh/sshd_config, then restart the SSH server.",
+			check.FamilyDebian:  "Set PasswordAuthentication no, PermitRootLogin no and PermitEmptyPasswords no in /etc/ssh/sshd_config, then run `sudo systemctl restart ssh`.",
+			check.FamilyFedora:  "Set PasswordAuthentication no, PermitRootLogin no and PermitEmptyPasswords no in /etc/ssh/sshd_config, then run `sudo systemctl restart sshd`.",
+			check.FamilyNixOS:   "Set services.openssh.settings.PasswordAuthentication = false and PermitRootLogin = \"no\" in your NixOS configuration and rebuild.",
+		},
+	}
+}
+
 func (s *SSHConfigCheck) RequiresRoot() bool {
 	return true
 }
+
+// hardenedOptions returns the sshd options that fix what the last run found,
+// or all of them if the last run went through the root helper.
+func (s *SSHConfigCheck) hardenedOptions() []string {
+	var options []string
+	for _, h := range sshdHardening {
+		if _, found := s.options[h.key]; found || len(s.options) == 0 {
+			options = append(options, h.option)
+		}
+	}
+	return options
+}
+
+// Plan returns the steps Apply takes to harden the SSH server
+func (s *SSHConfigCheck) Plan() []string {
+	plan := []string{}
+	for _, option := range s.hardenedOptions() {
+		step := "Set " + option + " in " + sshdDropInPath
+		if option == "PasswordAuthentication no" {
+			step += " (password logins stop working, make sure you can log in with an SSH key)"
+		}
+		plan = append(plan, step)
+	}
+	return append(plan, "Validate the configuration with `sshd -t` and reload the SSH server")
+}
+
+// Apply writes the hardened options to a drop-in and reloads the SSH server.
+// The drop-in is removed again if sshd rejects the resulting configuration.
+func (s *SSHConfigCheck) Apply(ctx context.Context) error {
+	if _, err := osStat(filepath.Dir(sshdDropInPath)); err != nil {
+		return fmt.Errorf("sshd does not read drop-in files from %s: %w", filepath.Dir(sshdDropInPath), err)
+	}
+
+	content := "# Written by Pareto Security, see `paretosecurity fix`\n" + strings.Join(s.hardenedOptions(), "\n") + "\n"
+	if err := osWriteFile(sshdDropInPath, []byte(content), 0o644); err != nil {
+		return err
+	}
+	if out, err := shared.RunCommandContext(ctx, "sshd", "-t"); err != nil {
+		if rmErr := osRemove(sshdDropInPath); rmErr != nil {
+			log.WithError(rmErr).Warn("Failed to remove sshd drop-in")
+		}
+		return fmt.Errorf("sshd rejected the configuration: %w: %s", err, strings.TrimSpace(out))
+	}
+
+	// The service is called sshd on most distributions and ssh on Debian
+	for _, service := range []string{"sshd", "ssh"} {
+		if _, err := shared.RunCommandContext(ctx, "systemctl", "reload", service); err == nil {
+			return nil
+		}
+	}
+	log.Warn("Failed to reload the SSH server, the new configuration applies to new connections only")
+	return nil
+}
+
+// FixRequiresRoot returns whether Apply has to run as root
+func (s *SSHConfigCheck) FixRequiresRoot() bool {
+	return true
+}
@@ -1,6 +1,8 @@
 package checks
 
 import (
+	"context"
+	"os"
 	"testing"
 
 	"github.com/ParetoSecurity/agent/shared"
@@ -14,6 +16,7 @@ func TestCheckSSHConfig(t *testing.T) {
 		setupMocks     map[string]string
 		expectedPassed bool
 		expectedDetail string
+		expectedOption string
 	}{
 		{
 			name: "All ok",
@@ -30,6 +33,7 @@ func TestCheckSSHConfig(t *testing.T) {
 			},
 			expectedPassed: false,
 			expectedDetail: "PasswordAuthentication is enabled",
+			expectedOption: "passwordauthentication",
 		},
 		{
 			name: "PermitRootLogin is enabled",
@@ -38,6 +42,7 @@ func TestCheckSSHConfig(t *testing.T) {
 			},
 			expectedPassed: false,
 			expectedDetail: "Root login is enabled",
+			expectedOption: "permitrootlogin",
 		},
 
 		{
@@ -47,6 +52,7 @@ func TestCheckSSHConfig(t *testing.T) {
 			},
 			expectedPassed: false,
 			expectedDetail: "Empty passwords are allowed",
+			expectedOption: "permitemptypasswords",
 		},
 	}
 
@@ -58,10 +64,15 @@ func TestCheckSSHConfig(t *testing.T) {
 			}
 			su := &SSHConfigCheck{}
 
-			err := su.Run()
+			err := su.Run(context.Background())
 			assert.Nil(t, err)
 			assert.Equal(t, tt.expectedPassed, su.passed)
 			assert.Equal(t, tt.expectedDetail, su.status)
+			if tt.expectedOption != "" {
+				assert.Equal(t, "yes", su.Evidence()[tt.expectedOption])
+			} else {
+				assert.Empty(t, su.Evidence())
+			}
 		})
 	}
 }
@@ -179,3 +190,46 @@ func TestSSHConfigCheck_PassedMessage(t *testing.T) {
 		t.Errorf("Expected PassedMessage %s, got %s", expectedPassedMessage, su.PassedMessage())
 	}
 }
+
+func TestSSHConfigCheck_Apply(t *testing.T) {
+	osStatMock = func(file string) (os.FileInfo, error) { return nil, nil }
+	defer func() { osStatMock = nil; osWriteFileMock = nil; osRemoveMock = nil }()
+
+	var written string
+	osWriteFileMock = func(file string, data []byte, perm os.FileMode) error {
+		assert.Equal(t, sshdDropInPath, file)
+		written = string(data)
+		return nil
+	}
+	removed := false
+	osRemoveMock = func(file string) error {
+		removed = true
+		return nil
+	}
+
+	s := &SSHConfigCheck{options: map[string]string{"permitrootlogin": "yes"}}
+	assert.Equal(t, []string{
+		"Set PermitRootLogin no in " + sshdDropInPath,
+		"Validate the configuration with `sshd -t` and reload the SSH server",
+	}, s.Plan())
+
+	shared.RunCommandMocks = convertCommandMapToMocks(map[string]string{
+		"sshd -t":               "",
+		"systemctl reload sshd": "",
+	})
+	assert.NoError(t, s.Apply(context.Background()))
+	assert.Contains(t, written, "PermitRootLogin no\n")
+	assert.NotContains(t, written, "PasswordAuthentication")
+	assert.False(t, removed)
+
+	// Without the options of the last run, all options are hardened
+	s = &SSHConfigCheck{}
+	assert.Len(t, s.Plan(), 4)
+
+	// A configuration rejected by sshd is rolled back
+	shared.RunCommandMocks = []shared.RunCommandMock{
+		{Command: "sshd", Args: []string{"-t"}, Out: "bad option", Err: assert.AnError},
+	}
+	assert.Error(t, s.Apply(context.Background()))
+	assert.True(t, removed)
+}
@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/caarlos0/log"
+)
+
+// MaxOutputSize caps how much a plugin may write to stdout and stderr.
+const MaxOutputSize = 1 << 20
+
+// commandWaitDelay bounds how long a killed plugin may keep its output
+// pipes open, e.g. through orphaned child processes.
+const commandWaitDelay = 2 * time.Second
+
+// limitedBuffer is a bytes.Buffer that fails writes beyond its limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("plugin output exceeds %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
+
+// invoke runs the plugin at path with the given subcommand in a sandbox
+// and returns its stdout. The plugin is killed when ctx is done.
+func invoke(ctx context.Context, path string, subcommand string) ([]byte, error) {
+	stdout := &limitedBuffer{limit: MaxOutputSize}
+	stderr := &limitedBuffer{limit: MaxOutputSize}
+
+	cmd := exec.CommandContext(ctx, path, subcommand)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = sandboxEnv()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = commandWaitDelay
+	sandbox(cmd)
+
+	err := cmd.Run()
+	log.WithField("plugin", path).
+		WithField("subcommand", subcommand).
+		WithError(err).
+		Debug(strings.TrimSpace(stderr.String()))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("plugin %s timed out", filepath.Base(path))
+		}
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed: %w", filepath.Base(path), err)
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/caarlos0/log"
+)
+
+// DescribeTimeout is how long a plugin may take to describe itself.
+const DescribeTimeout = 5 * time.Second
+
+// SystemDir holds the plugins installed by the administrator. Only plugins
+// here may require root, and they must be owned by root and not writable by
+// other users, as the root helper runs them.
+var SystemDir = "/etc/paretosecurity/plugins"
+
+// UserDir returns the directory with the plugins of the current user.
+func UserDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".config", "paretosecurity", "plugins")
+}
+
+// LoadDir describes the plugins in dir. Plugins that cannot be described are
+// skipped and returned as errors. If system is true, plugins must be trusted
+// and may require root, otherwise plugins that require root are rejected.
+func LoadDir(dir string, system bool) ([]*Plugin, []error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, []error{err}
+	}
+
+	plugins := []*Plugin{}
+	errs := []error{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !isExecutable(info) {
+			continue
+		}
+		if system {
+			if err := isTrusted(info); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				continue
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), DescribeTimeout)
+		plugin, err := Describe(ctx, path)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if plugin.RequiresRoot() && !system {
+			errs = append(errs, fmt.Errorf("%s: plugin %s requires root, which is only allowed in %s", path, plugin.UUID(), SystemDir))
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, errs
+}
+
+// Load describes the plugins in SystemDir and UserDir. Problems with the
+// plugins are logged, and a UUID that is provided more than once is only
+// loaded the first time, so the system plugins win.
+func Load() []*Plugin {
+	plugins := []*Plugin{}
+	seen := map[string]bool{}
+	dirs := []struct {
+		path   string
+		system bool
+	}{
+		{SystemDir, true},
+		{UserDir(), false},
+	}
+	for _, dir := range dirs {
+		if dir.path == "" {
+			continue
+		}
+		loaded, errs := LoadDir(dir.path, dir.system)
+		for _, err := range errs {
+			log.WithError(err).Warn("invalid check plugin")
+		}
+		for _, plugin := range loaded {
+			if seen[plugin.UUID()] {
+				log.WithField("uuid", plugin.UUID()).Warn("check plugin is provided more than once, ignoring it")
+				continue
+			}
+			seen[plugin.UUID()] = true
+			plugins = append(plugins, plugin)
+		}
+	}
+	return plugins
+}
@@ -0,0 +1,249 @@
+// Package plugin runs checks implemented by external executables.
+//
+// A plugin is an executable in SystemDir or UserDir that supports two
+// subcommands, each answering with a JSON object on stdout:
+//
+//	$ plugin describe
+//	{"uuid": "...", "name": "...", "claim": "...", "passedMessage": "...",
+//	 "failedMessage": "...", "requiresRoot": false, "severity": "medium"}
+//
+//	$ plugin run
+//	{"result": "pass", "details": "...", "evidence": {"key": "value"}}
+//
+// The result is one of pass, fail or error. Plugins run with a minimal
+// environment, a capped output size and are killed when their timeout passes.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
+	"github.com/google/uuid"
+)
+
+// DefaultClaim is the claim of plugins that do not name one.
+const DefaultClaim = "Plugins"
+
+// Description is the answer of a plugin to the describe subcommand.
+type Description struct {
+	UUID          string   `json:"uuid"`
+	Name          string   `json:"name"`
+	Claim         string   `json:"claim,omitempty"`
+	PassedMessage string   `json:"passedMessage"`
+	FailedMessage string   `json:"failedMessage"`
+	RequiresRoot  bool     `json:"requiresRoot"`
+	Severity      string   `json:"severity,omitempty"`
+	Category      string   `json:"category,omitempty"`
+	Remediation   string   `json:"remediation,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+	DependsOn     []string `json:"dependsOn,omitempty"`
+}
+
+// Validate returns an error if the description is incomplete.
+func (d Description) Validate() error {
+	if _, err := uuid.Parse(d.UUID); err != nil {
+		return fmt.Errorf("invalid uuid %q", d.UUID)
+	}
+	for field, value := range map[string]string{
+		"name":          d.Name,
+		"passedMessage": d.PassedMessage,
+		"failedMessage": d.FailedMessage,
+	} {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("plugin %s: %s is required", d.UUID, field)
+		}
+	}
+	if d.Severity != "" && check.Severity(d.Severity).Rank() == 0 {
+		return fmt.Errorf("plugin %s: unknown severity %q", d.UUID, d.Severity)
+	}
+	return nil
+}
+
+// Response is the answer of a plugin to the run subcommand.
+type Response struct {
+	Result   check.Outcome     `json:"result"`
+	Details  string            `json:"details,omitempty"`
+	Evidence map[string]string `json:"evidence,omitempty"`
+}
+
+// Plugin adapts an external executable to check.Check.
+type Plugin struct {
+	path     string
+	desc     Description
+	passed   bool
+	status   string
+	evidence map[string]string
+}
+
+// Describe runs the describe subcommand of the executable at path and
+// returns the plugin it describes.
+func Describe(ctx context.Context, path string) (*Plugin, error) {
+	output, err := invoke(ctx, path, "describe")
+	if err != nil {
+		return nil, err
+	}
+	var desc Description
+	if err := json.Unmarshal(output, &desc); err != nil {
+		return nil, fmt.Errorf("invalid describe output: %w", err)
+	}
+	if err := desc.Validate(); err != nil {
+		return nil, err
+	}
+	return New(path, desc), nil
+}
+
+// New returns the plugin at path with the given description, which should
+// be valid.
+func New(path string, desc Description) *Plugin {
+	if desc.Claim == "" {
+		desc.Claim = DefaultClaim
+	}
+	return &Plugin{path: path, desc: desc}
+}
+
+// Description returns how the plugin described itself
+func (p *Plugin) Description() Description {
+	return p.desc
+}
+
+// Path returns the path of the plugin executable
+func (p *Plugin) Path() string {
+	return p.path
+}
+
+// Claim returns the title of the claim the check belongs to
+func (p *Plugin) Claim() string {
+	return p.desc.Claim
+}
+
+// Name returns the name of the check
+func (p *Plugin) Name() string {
+	return p.desc.Name
+}
+
+// Run executes the check
+func (p *Plugin) Run(ctx context.Context) error {
+	p.passed = false
+	p.status = ""
+	p.evidence = nil
+
+	if p.RequiresRoot() && !shared.IsRoot() {
+		log.Debug("Running check via root helper")
+		res, err := shared.RunCheckViaHelper(ctx, p.UUID())
+		if err != nil {
+			log.WithError(err).Warn("Failed to run check via root helper")
+			return err
+		}
+		p.passed = res.Passed()
+		p.status = res.Reason
+		p.evidence = res.Evidence
+		return nil
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shared.CheckTimeout(p.UUID()))
+		defer cancel()
+	}
+	output, err := invoke(ctx, p.path, "run")
+	if err != nil {
+		return err
+	}
+	var res Response
+	if err := json.Unmarshal(output, &res); err != nil {
+		return fmt.Errorf("invalid run output: %w", err)
+	}
+
+	p.evidence = res.Evidence
+	switch res.Result {
+	case check.OutcomePass:
+		p.passed = true
+	case check.OutcomeFail:
+		p.status = res.Details
+	case check.OutcomeError:
+		if res.Details == "" {
+			return errors.New("plugin failed without details")
+		}
+		return errors.New(res.Details)
+	default:
+		return fmt.Errorf("unknown result %q", res.Result)
+	}
+	return nil
+}
+
+// Passed returns the status of the check
+func (p *Plugin) Passed() bool {
+	return p.passed
+}
+
+// IsRunnable returns whether the check can run
+func (p *Plugin) IsRunnable() bool {
+	if p.RequiresRoot() && !shared.IsRoot() {
+		can := shared.IsSocketServicePresent()
+		if !can {
+			p.status = "Root helper is not available, check cannot run. See https://paretosecurity.com/docs/linux/root-helper for more information."
+		}
+		return can
+	}
+	return true
+}
+
+// UUID returns the UUID of the check
+func (p *Plugin) UUID() string {
+	return p.desc.UUID
+}
+
+// PassedMessage returns the message to return if the check passed
+func (p *Plugin) PassedMessage() string {
+	return p.desc.PassedMessage
+}
+
+// FailedMessage returns the message to return if the check failed
+func (p *Plugin) FailedMessage() string {
+	return p.desc.FailedMessage
+}
+
+// RequiresRoot returns whether the check requires root access
+func (p *Plugin) RequiresRoot() bool {
+	return p.desc.RequiresRoot
+}
+
+// Status returns the status of the check
+func (p *Plugin) Status() string {
+	if p.Passed() {
+		return p.PassedMessage()
+	}
+	if p.status != "" {
+		return p.status
+	}
+	return p.FailedMessage()
+}
+
+// Evidence returns what the plugin reported during the last run
+func (p *Plugin) Evidence() map[string]string {
+	return p.evidence
+}
+
+// Metadata returns the severity, category and remediation of the check
+func (p *Plugin) Metadata() check.Metadata {
+	meta := check.Metadata{
+		Severity: check.Severity(p.desc.Severity),
+		Category: p.desc.Category,
+	}
+	if meta.Severity == "" {
+		meta.Severity = check.SeverityMedium
+	}
+	if meta.Category == "" {
+		meta.Category = "plugin"
+	}
+	if p.desc.Remediation != "" {
+		meta.Remediation = map[string]string{check.FamilyDefault: p.desc.Remediation}
+	}
+	return meta
+}
@@ -0,0 +1,150 @@
+//go:build !windows
+// +build !windows
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+const describeOutput = `{"uuid": "8a0f9e52-7b1c-4c3d-9e8f-1a2b3c4d5e6f", "name": "Backups are recent", "passedMessage": "Backups are recent", "failedMessage": "Backups are stale", "severity": "high"}`
+
+// writePlugin writes an executable shell script plugin to dir that answers
+// describe with describeOutput and run with the given script.
+func writePlugin(t *testing.T, dir, name, describe, run string) string {
+	t.Helper()
+	script := "#!/bin/sh\ncase \"$1\" in\ndescribe)\n" + describe + "\n;;\nrun)\n" + run + "\n;;\nesac\n"
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func TestDescribe(t *testing.T) {
+	dir := t.TempDir()
+	path := writePlugin(t, dir, "backups", "echo '"+describeOutput+"'", "")
+
+	p, err := Describe(context.Background(), path)
+	assert.NoError(t, err)
+	assert.Equal(t, "8a0f9e52-7b1c-4c3d-9e8f-1a2b3c4d5e6f", p.UUID())
+	assert.Equal(t, "Backups are recent", p.Name())
+	assert.Equal(t, DefaultClaim, p.Claim())
+	assert.False(t, p.RequiresRoot())
+	assert.Equal(t, check.SeverityHigh, p.Metadata().Severity)
+
+	invalid := writePlugin(t, dir, "invalid", `echo '{"uuid": "nope"}'`, "")
+	_, err = Describe(context.Background(), invalid)
+	assert.ErrorContains(t, err, "invalid uuid")
+
+	garbage := writePlugin(t, dir, "garbage", "echo hello", "")
+	_, err = Describe(context.Background(), garbage)
+	assert.ErrorContains(t, err, "invalid describe output")
+}
+
+func TestPlugin_Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      string
+		wantErr  string
+		passed   bool
+		status   string
+		evidence map[string]string
+	}{
+		{
+			name:   "pass",
+			run:    `echo '{"result": "pass"}'`,
+			passed: true,
+			status: "Backups are recent",
+		},
+		{
+			name:     "fail with details",
+			run:      `echo '{"result": "fail", "details": "Last backup is 9 days old", "evidence": {"age": "9d"}}'`,
+			status:   "Last backup is 9 days old",
+			evidence: map[string]string{"age": "9d"},
+		},
+		{
+			name:    "error",
+			run:     `echo '{"result": "error", "details": "backup tool is not configured"}'`,
+			wantErr: "backup tool is not configured",
+		},
+		{
+			name:    "unknown result",
+			run:     `echo '{"result": "maybe"}'`,
+			wantErr: "unknown result",
+		},
+		{
+			name:    "non-zero exit",
+			run:     "exit 3",
+			wantErr: "exit status 3",
+		},
+		{
+			name:   "environment is sandboxed",
+			run:    `test -z "$SECRET_TOKEN" && echo '{"result": "pass"}'`,
+			passed: true,
+			status: "Backups are recent",
+		},
+	}
+
+	t.Setenv("SECRET_TOKEN", "hunter2")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writePlugin(t, t.TempDir(), "backups", "echo '"+describeOutput+"'", tt.run)
+			p, err := Describe(context.Background(), path)
+			assert.NoError(t, err)
+			assert.True(t, p.IsRunnable())
+
+			err = p.Run(context.Background())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.passed, p.Passed())
+			assert.Equal(t, tt.status, p.Status())
+			assert.Equal(t, tt.evidence, p.Evidence())
+		})
+	}
+}
+
+func TestPlugin_RunTimeout(t *testing.T) {
+	path := writePlugin(t, t.TempDir(), "slow", "echo '"+describeOutput+"'", "sleep 30 & sleep 30")
+	p, err := Describe(context.Background(), path)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = p.Run(ctx)
+	assert.ErrorContains(t, err, "timed out")
+	assert.Less(t, time.Since(started), 5*time.Second)
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "backups", "echo '"+describeOutput+"'", "")
+	writePlugin(t, dir, "root", `echo '{"uuid": "4d3c2b1a-0f9e-4d8c-b7a6-5f4e3d2c1b0a", "name": "Root", "passedMessage": "ok", "failedMessage": "not ok", "requiresRoot": true}'`, "")
+	writePlugin(t, dir, "broken", "exit 1", "")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a plugin"), 0644))
+
+	plugins, errs := LoadDir(dir, false)
+	assert.Len(t, plugins, 1)
+	assert.Equal(t, "8a0f9e52-7b1c-4c3d-9e8f-1a2b3c4d5e6f", plugins[0].UUID())
+	assert.Len(t, errs, 2)
+
+	shared := t.TempDir()
+	path := writePlugin(t, shared, "backups", "echo '"+describeOutput+"'", "")
+	assert.NoError(t, os.Chmod(path, 0777))
+	plugins, errs = LoadDir(shared, true)
+	assert.Empty(t, plugins)
+	assert.Len(t, errs, 1)
+
+	plugins, errs = LoadDir(filepath.Join(dir, "missing"), false)
+	assert.Empty(t, plugins)
+	assert.Empty(t, errs)
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+// +build !windows
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/ParetoSecurity/agent/shared"
+)
+
+// sandbox runs the plugin in its own process group, so that the whole group
+// is killed when the plugin times out.
+func sandbox(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// sandboxEnv returns the minimal environment plugins run with.
+func sandboxEnv() []string {
+	env := []string{
+		"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		"LANG=C",
+		"PARETOSECURITY_VERSION=" + shared.Version,
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		env = append(env, "HOME="+home)
+	}
+	return env
+}
+
+// isExecutable returns whether info describes an executable regular file.
+func isExecutable(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// isTrusted returns an error unless the plugin is owned by root and cannot
+// be modified by other users, as required for plugins run by the root helper.
+func isTrusted(info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("file owner is not available")
+	}
+	if stat.Uid != 0 {
+		return fmt.Errorf("%s is not owned by root", info.Name())
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is writable by other users", info.Name())
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ParetoSecurity/agent/shared"
+)
+
+// sandbox has no process group handling on Windows, where the plugin
+// process itself is killed when it times out.
+func sandbox(cmd *exec.Cmd) {}
+
+// sandboxEnv returns the environment plugins run with. Windows programs
+// depend on many system variables, so the environment is inherited.
+func sandboxEnv() []string {
+	return append(os.Environ(), "PARETOSECURITY_VERSION="+shared.Version)
+}
+
+// isExecutable returns whether info describes an executable file.
+func isExecutable(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && strings.EqualFold(filepath.Ext(info.Name()), ".exe")
+}
+
+// isTrusted always succeeds on Windows, as there is no root helper.
+func isTrusted(info os.FileInfo) error {
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"runtime"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 	"github.com/carlmjohnson/requests"
@@ -25,7 +26,7 @@ func (f *ParetoUpdated) Name() string {
 }
 
 // Run executes the check
-func (f *ParetoUpdated) Run() error {
+func (f *ParetoUpdated) Run(ctx context.Context) error {
 	f.passed = false
 	res := ParetoReleases{}
 	device := shared.CurrentReportingDevice()
@@ -37,8 +38,15 @@ func (f *ParetoUpdated) Run() error {
 		platform = "windows"
 	}
 
+	endpoints := shared.Endpoints()
+	client, err := endpoints.HTTPClient()
+	if err != nil {
+		return err
+	}
+
 	if shared.IsLinked() {
-		err := requests.URL("https://paretosecurity.com/api/updates").
+		err := requests.URL(endpoints.UpdatesURL()).
+			Client(client).
 			Param("uuid", device.MachineUUID).
 			Param("version", shared.Version).
 			Param("os_version", device.OSVersion).
@@ -51,7 +59,7 @@ func (f *ParetoUpdated) Run() error {
 				return "app-live-opensource"
 			}()).
 			ToJSON(&res).
-			Fetch(context.Background())
+			Fetch(ctx)
 		if err != nil {
 			log.WithError(err).
 				Warnf("Failed to check for updates")
@@ -68,9 +76,10 @@ func (f *ParetoUpdated) Run() error {
 		return nil
 	}
 
-	err := requests.URL("https://api.github.com/repos/ParetoSecurity/agent/releases").
+	err = requests.URL(endpoints.ReleasesURL()).
+		Client(client).
 		ToJSON(&res).
-		Fetch(context.Background())
+		Fetch(ctx)
 	if err != nil {
 		log.WithError(err).
 			Warnf("Failed to check for updates")
@@ -115,6 +124,20 @@ func (f *ParetoUpdated) FailedMessage() string {
 	return "Pareto Security is outdated " + f.details
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *ParetoUpdated) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityMedium,
+		Category: "updates",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Update Pareto Security from https://paretosecurity.com.",
+			check.FamilyDebian:  "Run `sudo apt update && sudo apt install paretosecurity`.",
+			check.FamilyFedora:  "Run `sudo dnf upgrade paretosecurity`.",
+			check.FamilyNixOS:   "Update your nixpkgs channel or flake input and rebuild.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *ParetoUpdated) RequiresRoot() bool {
 	return false
@@ -1,6 +1,10 @@
 package shared
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/ParetoSecurity/agent/shared"
@@ -20,7 +24,7 @@ func TestParetoUpdated_Run(t *testing.T) {
 		Reply(200).
 		JSON([]map[string]string{{"tag_name": "1.7.91"}})
 	check := &ParetoUpdated{}
-	err := check.Run()
+	err := check.Run(context.Background())
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -37,7 +41,7 @@ func TestParetoUpdated_RunPublic(t *testing.T) {
 		Reply(200).
 		JSON([]map[string]string{{"tag_name": "1.7.91"}})
 	check := &ParetoUpdated{}
-	err := check.Run()
+	err := check.Run(context.Background())
 
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
@@ -45,6 +49,33 @@ func TestParetoUpdated_RunPublic(t *testing.T) {
 
 }
 
+func TestParetoUpdated_RunSelfHosted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates" || r.URL.Query().Get("app") != "auditor" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `[{"tag_name": %q}]`, shared.Version)
+	}))
+	defer server.Close()
+	shared.Config.TeamID = "test-team-id"
+	shared.Config.AuthToken = "test-auth-token"
+	shared.Config.Endpoints = &shared.EndpointSettings{Updates: server.URL + "/updates"}
+	defer func() {
+		shared.Config.TeamID = ""
+		shared.Config.AuthToken = ""
+		shared.Config.Endpoints = nil
+	}()
+
+	check := &ParetoUpdated{}
+	if err := check.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !check.Passed() {
+		t.Errorf("expected the check to pass, got %q", check.Status())
+	}
+}
+
 func TestParetoUpdated_Name(t *testing.T) {
 	dockerAccess := &ParetoUpdated{}
 	expectedName := "Pareto Security is up to date"
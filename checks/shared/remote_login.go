@@ -1,8 +1,10 @@
 package shared
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/caarlos0/log"
 )
 
@@ -17,7 +19,7 @@ func (f *RemoteLogin) Name() string {
 }
 
 // Run executes the check
-func (f *RemoteLogin) Run() error {
+func (f *RemoteLogin) Run(ctx context.Context) error {
 	f.passed = true
 	f.ports = make(map[int]string)
 
@@ -40,6 +42,15 @@ func (f *RemoteLogin) Run() error {
 	return nil
 }
 
+// Evidence returns the open ports found during the last run
+func (f *RemoteLogin) Evidence() map[string]string {
+	evidence := make(map[string]string)
+	for port, service := range f.ports {
+		evidence[fmt.Sprintf("tcp/%d", port)] = service
+	}
+	return evidence
+}
+
 // Passed returns the status of the check
 func (f *RemoteLogin) Passed() bool {
 	return f.passed
@@ -65,6 +76,21 @@ func (f *RemoteLogin) FailedMessage() string {
 	return "Remote access services found running"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *RemoteLogin) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "remote-access",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Stop and disable remote access services such as SSH, RDP or VNC that you do not need.",
+			check.FamilyDebian:  "Run `sudo systemctl disable --now ssh` and disable any RDP or VNC servers you do not need.",
+			check.FamilyFedora:  "Run `sudo systemctl disable --now sshd` and disable any RDP or VNC servers you do not need.",
+			check.FamilyMacOS:   "Turn off Remote Login and Screen Sharing in System Settings > General > Sharing.",
+			check.FamilyWindows: "Turn off Remote Desktop in Settings > System > Remote Desktop.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *RemoteLogin) RequiresRoot() bool {
 	return false
@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,7 +15,7 @@ func TestRemoteLogin_Run_NoOpenPorts(t *testing.T) {
 		return false
 	}
 
-	err := remoteLogin.Run()
+	err := remoteLogin.Run(context.Background())
 	assert.NoError(t, err)
 	assert.True(t, remoteLogin.Passed())
 	assert.Empty(t, remoteLogin.ports)
@@ -28,7 +29,7 @@ func TestRemoteLogin_Run_OpenPorts(t *testing.T) {
 		return port == 22 || port == 3389
 	}
 
-	err := remoteLogin.Run()
+	err := remoteLogin.Run(context.Background())
 	assert.NoError(t, err)
 	assert.False(t, remoteLogin.Passed())
 	assert.NotEmpty(t, remoteLogin.ports)
@@ -36,6 +37,7 @@ func TestRemoteLogin_Run_OpenPorts(t *testing.T) {
 	assert.Contains(t, remoteLogin.ports, 3389)
 	assert.NotContains(t, remoteLogin.ports, 3390)
 	assert.NotContains(t, remoteLogin.ports, 5900)
+	assert.Equal(t, map[string]string{"tcp/22": "SSH", "tcp/3389": "RDP"}, remoteLogin.Evidence())
 	assert.NotEmpty(t, remoteLogin.UUID())
 	assert.False(t, remoteLogin.RequiresRoot())
 }
@@ -1,10 +1,12 @@
 package shared
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	sharedG "github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 	"golang.org/x/crypto/ssh"
@@ -33,7 +35,7 @@ func (f *SSHKeys) hasPassword(privateKeyPath string) bool {
 }
 
 // Run executes the check
-func (f *SSHKeys) Run() error {
+func (f *SSHKeys) Run(ctx context.Context) error {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return err
@@ -47,6 +49,7 @@ func (f *SSHKeys) Run() error {
 	}
 
 	f.passed = true
+	f.failedKeys = []string{}
 	for _, file := range files {
 		if strings.HasSuffix(file.Name(), ".pub") {
 			privateKeyPath := filepath.Join(sshDir, strings.TrimSuffix(file.Name(), ".pub"))
@@ -62,6 +65,15 @@ func (f *SSHKeys) Run() error {
 	return nil
 }
 
+// Evidence returns the unprotected key files found during the last run
+func (f *SSHKeys) Evidence() map[string]string {
+	evidence := make(map[string]string)
+	for _, key := range f.failedKeys {
+		evidence[key] = "unprotected"
+	}
+	return evidence
+}
+
 // Passed returns the status of the check
 func (f *SSHKeys) Passed() bool {
 	return f.passed
@@ -116,6 +128,17 @@ func (f *SSHKeys) FailedMessage() string {
 	return "SSH keys are not using password"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *SSHKeys) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityHigh,
+		Category: "credentials",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Add a passphrase to each listed key with `ssh-keygen -p -f ~/.ssh/<key>`.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *SSHKeys) RequiresRoot() bool {
 	return false
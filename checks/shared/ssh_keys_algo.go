@@ -2,12 +2,14 @@
 package shared
 
 import (
+	"context"
 	"crypto/rsa"
 
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/caarlos0/log"
 	"golang.org/x/crypto/ssh" // Import the crypto/ssh package
 )
@@ -57,7 +59,7 @@ func (f *SSHKeysAlgo) isKeyStrong(path string) bool {
 }
 
 // Run executes the check
-func (f *SSHKeysAlgo) Run() error {
+func (f *SSHKeysAlgo) Run(ctx context.Context) error {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return err
@@ -152,6 +154,17 @@ func (f *SSHKeysAlgo) FailedMessage() string {
 	return "SSH keys are using weak encryption"
 }
 
+// Metadata returns the severity, category and remediation of the check
+func (f *SSHKeysAlgo) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityMedium,
+		Category: "credentials",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Generate a new key with `ssh-keygen -t ed25519` and replace the weak one wherever it is used.",
+		},
+	}
+}
+
 // RequiresRoot returns whether the check requires root access
 func (f *SSHKeysAlgo) RequiresRoot() bool {
 	return false
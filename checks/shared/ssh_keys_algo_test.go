@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -129,7 +130,7 @@ func TestSSHKeysAlgo_isKeyStrong(t *testing.T) {
 	t.Run("Run command", func(t *testing.T) {
 		sshCheck := &SSHKeysAlgo{}
 		_ = sshCheck.IsRunnable()
-		_ = sshCheck.Run()
+		_ = sshCheck.Run(context.Background())
 	})
 }
 
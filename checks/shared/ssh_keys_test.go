@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 
@@ -66,7 +67,7 @@ func TestHasPassword(t *testing.T) {
 
 	t.Run("Run command", func(t *testing.T) {
 		_ = s.IsRunnable()
-		_ = s.Run()
+		_ = s.Run(context.Background())
 	})
 }
 
@@ -1,9 +1,12 @@
 package checks
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/ParetoSecurity/agent/check"
 )
 
 type PasswordManagerCheck struct {
@@ -14,7 +17,7 @@ func (pmc *PasswordManagerCheck) Name() string {
 	return "Password Manager Presence"
 }
 
-func (pmc *PasswordManagerCheck) Run() error {
+func (pmc *PasswordManagerCheck) Run(ctx context.Context) error {
 	// TODO; need real paths
 	userProfile := os.Getenv("USERPROFILE")
 	paths := []string{
@@ -94,6 +97,16 @@ func (pmc *PasswordManagerCheck) FailedMessage() string {
 	return "No password manager found"
 }
 
+func (pmc *PasswordManagerCheck) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity: check.SeverityLow,
+		Category: "credentials",
+		Remediation: map[string]string{
+			check.FamilyDefault: "Install a password manager such as 1Password, Bitwarden or KeePassXC.",
+		},
+	}
+}
+
 func (pmc *PasswordManagerCheck) RequiresRoot() bool {
 	return false
 }
@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -61,7 +62,7 @@ func TestPasswordManagerCheck_Run(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			osStatMock = tt.mockFiles
 			pmc := &PasswordManagerCheck{}
-			err := pmc.Run()
+			err := pmc.Run(context.Background())
 			assert.NoError(t, err)
 			assert.Equal(t, tt.expectedPassed, pmc.Passed())
 			assert.Equal(t, tt.expectedStatus, pmc.Status())
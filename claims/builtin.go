@@ -0,0 +1,65 @@
+package claims
+
+import (
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	darwin "github.com/ParetoSecurity/agent/checks/darwin"
+	linux "github.com/ParetoSecurity/agent/checks/linux"
+	shared "github.com/ParetoSecurity/agent/checks/shared"
+	windows "github.com/ParetoSecurity/agent/checks/windows"
+)
+
+// Platforms of the built-in checks, as reported by runtime.GOOS.
+var (
+	onLinux   = []string{"linux"}
+	onDarwin  = []string{"darwin"}
+	onWindows = []string{"windows"}
+	onAll     = []string{"linux", "darwin", "windows"}
+)
+
+// Tags of the built-in checks that name the kind of device a check is
+// relevant for. Profiles select checks by these and by topic tags such as
+// "ssh" or "network".
+const (
+	TagWorkstation = "workstation"
+	TagServer      = "server"
+	TagDeveloper   = "developer"
+	TagCISLevel1   = "cis-l1"
+)
+
+// Files the root checks read, so that the root helper drops their cached
+// results when the files change. Checks of runtime state that no file
+// reflects, such as whether the firewall is running, are not cached.
+var (
+	watchSSHD     = []string{"/etc/ssh/sshd_config", "/etc/ssh/sshd_config.d/*"}
+	watchCrypttab = []string{"/etc/crypttab"}
+)
+
+// builtin lists the checks compiled into the agent, in the order they are
+// shown to the user.
+var builtin = []check.Registration{
+	{Claim: "Access Security", Platforms: onLinux, Tags: []string{TagWorkstation, TagCISLevel1, "login"}, New: func() check.Check { return &linux.Autologin{} }},
+	{Claim: "Access Security", Platforms: onLinux, Tags: []string{TagDeveloper, TagServer, "containers"}, New: func() check.Check { return &linux.DockerAccess{} }},
+	{Claim: "Access Security", Platforms: onLinux, Tags: []string{TagWorkstation, TagCISLevel1, "login"}, New: func() check.Check { return &linux.PasswordToUnlock{} }},
+	{Claim: "Access Security", Platforms: onAll, Tags: []string{TagDeveloper, TagServer, "ssh"}, New: func() check.Check { return &shared.SSHKeys{} }},
+	{Claim: "Access Security", Platforms: onAll, Tags: []string{TagDeveloper, TagServer, TagCISLevel1, "ssh"}, New: func() check.Check { return &shared.SSHKeysAlgo{} }},
+	{Claim: "Access Security", Platforms: onLinux, Tags: []string{TagServer, TagCISLevel1, "ssh"}, CacheTTL: 15 * time.Minute, Watch: watchSSHD, New: func() check.Check { return &linux.SSHConfigCheck{} }},
+	{Claim: "Access Security", Platforms: onLinux, Tags: []string{TagWorkstation}, New: func() check.Check { return &linux.PasswordManagerCheck{} }},
+	{Claim: "Access Security", Platforms: onDarwin, Tags: []string{TagWorkstation}, New: func() check.Check { return &darwin.PasswordManagerCheck{} }},
+	{Claim: "Access Security", Platforms: onWindows, Tags: []string{TagWorkstation}, New: func() check.Check { return &windows.PasswordManagerCheck{} }},
+	{Claim: "Application Updates", Platforms: onLinux, Tags: []string{TagWorkstation, TagServer, TagCISLevel1, "updates"}, New: func() check.Check { return &linux.ApplicationUpdates{} }},
+	{Claim: "Application Updates", Platforms: onAll, Tags: []string{TagWorkstation, TagServer, "updates"}, New: func() check.Check { return &shared.ParetoUpdated{} }},
+	{Claim: "Firewall & Sharing", Platforms: onLinux, Tags: []string{TagWorkstation, TagServer, TagCISLevel1, "network"}, New: func() check.Check { return &linux.Firewall{} }},
+	{Claim: "Firewall & Sharing", Platforms: onLinux, Tags: []string{TagWorkstation, "network"}, New: func() check.Check { return &linux.Printer{} }},
+	{Claim: "Firewall & Sharing", Platforms: onAll, Tags: []string{TagWorkstation, TagServer, "network"}, New: func() check.Check { return &shared.RemoteLogin{} }},
+	{Claim: "Firewall & Sharing", Platforms: onLinux, Tags: []string{TagWorkstation, "network"}, New: func() check.Check { return &linux.Sharing{} }},
+	{Claim: "System Integrity", Platforms: onLinux, Tags: []string{TagWorkstation, TagServer, TagCISLevel1, "boot"}, New: func() check.Check { return &linux.SecureBoot{} }},
+	{Claim: "System Integrity", Platforms: onLinux, Tags: []string{TagWorkstation, "encryption"}, CacheTTL: time.Hour, Watch: watchCrypttab, New: func() check.Check { return &linux.EncryptingFS{} }},
+}
+
+func init() {
+	for _, reg := range builtin {
+		Registry.MustRegister(reg)
+	}
+}
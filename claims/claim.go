@@ -1,8 +1,65 @@
 package claims
 
-import "github.com/ParetoSecurity/agent/check"
+import (
+	"runtime"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+)
 
 type Claim struct {
 	Title  string
 	Checks []check.Check
 }
+
+// Registry holds every check known to the agent: the built-in checks, the
+// custom checks and the check plugins.
+var Registry = check.NewRegistry()
+
+// All returns the claims with fresh instances of the checks that run on
+// this platform. Claims are ordered by their first registered check.
+func All() []Claim {
+	return Select(check.Query{Platform: runtime.GOOS})
+}
+
+// Select returns the claims with fresh instances of the checks selected by
+// q, grouped by claim in registration order.
+func Select(q check.Query) []Claim {
+	return group(Registry.Query(q))
+}
+
+// group returns the claims with fresh instances of the checks of regs, in
+// the order of regs.
+func group(regs []check.Registration) []Claim {
+	claims := []Claim{}
+	index := map[string]int{}
+	for _, reg := range regs {
+		i, found := index[reg.Claim]
+		if !found {
+			i = len(claims)
+			index[reg.Claim] = i
+			claims = append(claims, Claim{Title: reg.Claim})
+		}
+		claims[i].Checks = append(claims[i].Checks, reg.New())
+	}
+	return claims
+}
+
+// Find returns a fresh instance of the check with uuid on this platform, or
+// nil if there is none.
+func Find(uuid string) check.Check {
+	reg, found := Registry.Lookup(uuid, runtime.GOOS)
+	if !found {
+		return nil
+	}
+	return reg.New()
+}
+
+func init() {
+	// The state file needs the root helper's signature on results of the
+	// checks it runs
+	shared.IsRootCheck = func(uuid string) bool {
+		chk := Find(uuid)
+		return chk != nil && chk.RequiresRoot()
+	}
+}
@@ -0,0 +1,39 @@
+package claims
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelect(t *testing.T) {
+	all := Select(check.Query{Platform: "linux"})
+	titles := []string{}
+	for _, claim := range all {
+		titles = append(titles, claim.Title)
+	}
+	assert.Equal(t, []string{"Access Security", "Application Updates", "Firewall & Sharing", "System Integrity"}, titles)
+
+	ssh := Select(check.Query{Platform: "linux", Tag: "ssh"})
+	assert.Len(t, ssh, 1)
+	assert.Len(t, ssh[0].Checks, 3)
+}
+
+func TestAllReturnsFreshInstances(t *testing.T) {
+	first, second := All(), All()
+	assert.NotEmpty(t, first)
+	assert.NotSame(t, first[0].Checks[0], second[0].Checks[0])
+}
+
+func TestFind(t *testing.T) {
+	chk := Find("b6aaec0f-d76c-429e-aecf-edab7f1ac400")
+	assert.NotNil(t, chk)
+	assert.Equal(t, "b6aaec0f-d76c-429e-aecf-edab7f1ac400", chk.UUID())
+	assert.NotSame(t, chk, Find(chk.UUID()))
+	assert.Nil(t, Find("unknown"))
+
+	_, found := Registry.Lookup("f962c423-fdf5-428a-a57a-827abc9b253e", runtime.GOOS)
+	assert.True(t, found)
+}
@@ -0,0 +1,47 @@
+package claims
+
+import (
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/checks/custom"
+	"github.com/ParetoSecurity/agent/checks/plugin"
+	"github.com/caarlos0/log"
+)
+
+// LoadCustomChecks registers the checks defined in the policy files of
+// custom.SystemDir and custom.UserDir, under the claim named by each
+// definition. Definitions reusing the UUID of another check are ignored.
+func LoadCustomChecks() {
+	for _, chk := range custom.Load() {
+		def := chk.Definition()
+		registerExternal(check.Registration{
+			UUID:      def.UUID,
+			Claim:     def.Claim,
+			Tags:      def.Tags,
+			DependsOn: def.DependsOn,
+			New:       func() check.Check { return custom.New(def) },
+		})
+	}
+}
+
+// LoadPlugins registers the check plugins found in plugin.SystemDir and
+// plugin.UserDir, under the claim named by each plugin. Plugins reusing the
+// UUID of another check are ignored.
+func LoadPlugins() {
+	for _, p := range plugin.Load() {
+		path, desc := p.Path(), p.Description()
+		registerExternal(check.Registration{
+			UUID:      desc.UUID,
+			Claim:     p.Claim(),
+			Tags:      desc.Tags,
+			DependsOn: desc.DependsOn,
+			New:       func() check.Check { return plugin.New(path, desc) },
+		})
+	}
+}
+
+// registerExternal registers a check that is not built into the agent.
+func registerExternal(reg check.Registration) {
+	if err := Registry.Register(reg); err != nil {
+		log.WithError(err).WithField("uuid", reg.UUID).Warn("cannot register check, ignoring it")
+	}
+}
@@ -0,0 +1,90 @@
+package claims
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/samber/lo"
+)
+
+// Profiles are the built-in profiles. A profile of the same name in
+// pareto.toml replaces the built-in one.
+var Profiles = map[string]shared.Profile{
+	"workstation": {
+		Description: "Laptops and desktops used by a single person",
+		Tags:        []string{TagWorkstation},
+	},
+	"server": {
+		Description: "Headless servers and build machines",
+		Tags:        []string{TagServer},
+	},
+	"developer": {
+		Description: "Workstations used for software development",
+		Tags:        []string{TagWorkstation, TagDeveloper},
+	},
+	"cis-l1": {
+		Description: "Checks that map to CIS Benchmark Level 1 recommendations",
+		Tags:        []string{TagCISLevel1},
+	},
+}
+
+// ProfileNames returns the sorted names of the built-in profiles and of the
+// profiles in pareto.toml.
+func ProfileNames() []string {
+	names := lo.Uniq(append(lo.Keys(Profiles), lo.Keys(shared.Config.Profiles)...))
+	slices.Sort(names)
+	return names
+}
+
+// LookupProfile returns the profile with the given name, preferring the
+// profiles in pareto.toml over the built-in ones.
+func LookupProfile(name string) (shared.Profile, error) {
+	if profile, found := shared.Config.Profiles[name]; found {
+		return profile, nil
+	}
+	if profile, found := Profiles[name]; found {
+		return profile, nil
+	}
+	return shared.Profile{}, fmt.Errorf("unknown profile %q, use one of: %s", name, strings.Join(ProfileNames(), ", "))
+}
+
+// SelectProfile returns the claims with fresh instances of the checks of the
+// named profile that run on this platform, and of the checks they depend on,
+// so that a dependency the profile leaves out is still run first. An empty
+// name selects all checks.
+func SelectProfile(name string) ([]Claim, error) {
+	if name == "" {
+		return All(), nil
+	}
+	profile, err := LookupProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	regs := Registry.Query(check.Query{Platform: runtime.GOOS})
+	selected := map[string]bool{}
+	var pending []string
+	for _, reg := range regs {
+		if profile.Matches(reg) {
+			pending = append(pending, reg.UUID)
+		}
+	}
+	for len(pending) > 0 {
+		uuid := pending[0]
+		pending = pending[1:]
+		if selected[uuid] {
+			continue
+		}
+		selected[uuid] = true
+		if reg, found := Registry.Lookup(uuid, runtime.GOOS); found {
+			pending = append(pending, reg.DependsOn...)
+		}
+	}
+	regs = lo.Filter(regs, func(reg check.Registration, _ int) bool {
+		return selected[reg.UUID]
+	})
+	return group(regs), nil
+}
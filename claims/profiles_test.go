@@ -0,0 +1,80 @@
+package claims
+
+import (
+	"testing"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/checks/custom"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func uuidsOf(all []Claim) []string {
+	uuids := []string{}
+	for _, claim := range all {
+		for _, chk := range claim.Checks {
+			uuids = append(uuids, chk.UUID())
+		}
+	}
+	return uuids
+}
+
+func TestSelectProfile(t *testing.T) {
+	all, err := SelectProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, uuidsOf(All()), uuidsOf(all))
+
+	server, err := SelectProfile("server")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, server)
+	assert.Less(t, len(uuidsOf(server)), len(uuidsOf(all)))
+	// Printer sharing is only relevant for workstations
+	assert.NotContains(t, uuidsOf(server), "b96524e0-150b-4bb8-abc7-517051b6c14e")
+
+	_, err = SelectProfile("kiosk")
+	assert.ErrorContains(t, err, "unknown profile")
+}
+
+func TestSelectProfile_FromConfig(t *testing.T) {
+	shared.Config.Profiles = map[string]shared.Profile{
+		"kiosk":  {Checks: []string{"b6aaec0f-d76c-429e-aecf-edab7f1ac400"}},
+		"server": {Tags: []string{"ssh"}},
+	}
+	defer func() { shared.Config.Profiles = nil }()
+
+	kiosk, err := SelectProfile("kiosk")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b6aaec0f-d76c-429e-aecf-edab7f1ac400"}, uuidsOf(kiosk))
+
+	// Profiles in pareto.toml replace the built-in ones
+	server, err := SelectProfile("server")
+	assert.NoError(t, err)
+	assert.Len(t, server, 1)
+	assert.Equal(t, "Access Security", server[0].Title)
+
+	assert.Contains(t, ProfileNames(), "kiosk")
+	assert.Contains(t, ProfileNames(), "cis-l1")
+}
+
+func TestSelectProfile_Dependencies(t *testing.T) {
+	registry := Registry
+	Registry = check.NewRegistry()
+	defer func() { Registry = registry }()
+	register := func(uuid, claim string, tags []string, dependsOn ...string) {
+		Registry.MustRegister(check.Registration{
+			Claim:     claim,
+			Tags:      tags,
+			DependsOn: dependsOn,
+			New:       func() check.Check { return custom.New(custom.Definition{UUID: uuid, Name: uuid}) },
+		})
+	}
+	register("base", "System Integrity", nil)
+	register("middle", "System Integrity", nil, "base")
+	register("dependent", "Access Security", []string{TagServer}, "middle")
+	register("unrelated", "Access Security", nil)
+
+	// The profile leaves out the checks the dependent check depends on
+	server, err := SelectProfile("server")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"base", "middle", "dependent"}, uuidsOf(server))
+}
@@ -2,70 +2,224 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"maps"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
 	"github.com/ParetoSecurity/agent/runner"
 	shared "github.com/ParetoSecurity/agent/shared"
 	team "github.com/ParetoSecurity/agent/team"
 	"github.com/caarlos0/log"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
 var checkCmd = &cobra.Command{
-	Use:   "check [--skip <uuid>] [--only <uuid>]",
+	Use:   "check [--profile <name>] [--skip <uuid>] [--only <uuid>] [--format <format>] [--output <file>] [--fresh] [--notify]",
 	Short: "Run checks on your system",
+	Long: `Run checks on your system.
+
+The exit code reflects the highest severity among the failed checks:
+0 when all checks pass, 2 for low, 3 for medium, 4 for high and 5 for
+critical. Exit code 1 is left for errors, such as invalid flags.
+
+With --format, the results are written to stdout (or --output) as json,
+junit, sarif, markdown or tap, while logs go to stderr.
+
+With --profile, only the checks of the named profile are run and reported
+to the team. Without it, the Profile set in pareto.toml is used, if any.
+
+Each check is stopped after 30 seconds, or after the Timeout set for it in
+pareto.toml, and is then reported with the error outcome.
+
+The root helper may answer with results it cached from an earlier run, until
+they expire or the files they depend on change. With --fresh, every check is
+run again.
+
+With --notify, as used by the hourly timer, a desktop notification is sent
+for each check that passed in the previous run and fails now. How often to
+notify and the quiet hours are set in the Notifications section of
+pareto.toml.`,
+	Annotations: needsChecks,
 	Run: func(cc *cobra.Command, args []string) {
-		skipUUIDs, _ := cc.Flags().GetStringArray("skip")
-		onlyUUID, _ := cc.Flags().GetString("only")
-		checkCommand(skipUUIDs, onlyUUID)
+		opts := checkOptions{}
+		opts.profile, _ = cc.Flags().GetString("profile")
+		opts.skipUUIDs, _ = cc.Flags().GetStringArray("skip")
+		opts.onlyUUID, _ = cc.Flags().GetString("only")
+		opts.format, _ = cc.Flags().GetString("format")
+		opts.output, _ = cc.Flags().GetString("output")
+		opts.fresh, _ = cc.Flags().GetBool("fresh")
+		opts.notify, _ = cc.Flags().GetBool("notify")
+		checkCommand(opts)
 	},
 }
 
+// checkOptions holds the flags of the check command.
+type checkOptions struct {
+	profile   string
+	skipUUIDs []string
+	onlyUUID  string
+	format    string
+	output    string
+	fresh     bool
+	notify    bool
+}
+
 func init() {
 	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().String("profile", "", fmt.Sprintf("only run the checks of a profile, such as %s", strings.Join(claims.ProfileNames(), ", ")))
 	checkCmd.Flags().StringArray("skip", []string{}, "skip checks by UUID")
 	checkCmd.Flags().String("only", "", "only run checks by UUID")
+	checkCmd.Flags().String("format", "", fmt.Sprintf("output format of the results (%s)", strings.Join(runner.Formats, ", ")))
+	checkCmd.Flags().String("output", "", "write formatted results to a file instead of stdout")
+	checkCmd.Flags().Bool("fresh", false, "do not use results cached by the root helper")
+	checkCmd.Flags().Bool("notify", false, "notify about checks that started failing since the previous run")
 }
 
-func checkCommand(skipUUIDs []string, onlyUUID string) {
+func checkCommand(opts checkOptions) {
 	if shared.IsRoot() {
 		log.Warn("Please run this command as a normal user, as it won't report all checks correctly.")
 	}
+	if opts.format != "" && !lo.Contains(runner.Formats, opts.format) {
+		log.Fatalf("Unknown format %q, use one of: %s", opts.format, strings.Join(runner.Formats, ", "))
+	}
+	if opts.output != "" && opts.format == "" {
+		log.Fatal("Please specify the --format of the output")
+	}
+
+	expireExemptions()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	profile := opts.profile
+	if profile == "" {
+		profile = shared.Config.Profile
+	}
+	// Every run gets fresh check instances from the registry
+	claimsTorun, err := claims.SelectProfile(profile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to select checks")
+	}
+	if profile != "" {
+		log.Infof("Running the checks of the %s profile", profile)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout(claimsTorun))
 	defer cancel()
+	if opts.fresh {
+		ctx = shared.WithFreshResults(ctx)
+	}
 
-	done := make(chan struct{})
-	go func() {
-		runner.Check(ctx, claims.All, skipUUIDs, onlyUUID)
-		close(done)
-	}()
-
-	select {
-	case <-done:
-		if shared.IsLinked() {
-			err := team.ReportToTeam(false)
-			if err != nil {
-				log.WithError(err).Warn("failed to report to team")
-			}
+	// The stored states are updated by the run, keep the previous ones to
+	// find regressions
+	previous := maps.Clone(shared.GetLastStates())
+
+	// Checks that exceed their own timeout are recorded as errors, and the
+	// results of the finished checks are saved even if the whole run is cut
+	// short.
+	results := runner.Check(ctx, claimsTorun, opts.skipUUIDs, opts.onlyUUID)
+	if ctx.Err() != nil {
+		log.Warn("Check run timed out")
+	}
+
+	if opts.format != "" {
+		if err := writeResults(opts, results); err != nil {
+			log.WithError(err).Fatal("Failed to write results")
+		}
+	}
+
+	if shared.IsLinked() {
+		err := team.ReportToTeam(false, profile)
+		if err != nil {
+			log.WithError(err).Warn("failed to report to team")
 		}
+	}
+
+	if opts.notify {
+		notifyCtx, cancel := context.WithTimeout(context.Background(), regressionActionWait)
+		notifyRegressions(notifyCtx, previous, maps.Clone(shared.GetLastStates()), time.Now())
+		cancel()
+	}
 
-		// if checks failed, exit with a non-zero status code
-		if !shared.AllChecksPassed() {
-			// Log the failed checks
-			if failedChecks := shared.GetFailedChecks(); len(failedChecks) > 0 && verbose {
-				for _, check := range failedChecks {
-					log.Errorf("Failed check: %s (UUID: %s)", check.Name, check.UUID)
-				}
+	// if checks failed, exit with a non-zero status code
+	if failedChecks := failedChecksIn(claimsTorun); len(failedChecks) > 0 {
+		// Log the failed checks
+		if verbose {
+			for _, check := range failedChecks {
+				log.Errorf("Failed check: %s (UUID: %s)", check.Name, check.UUID)
 			}
-			log.Info("You can use `paretosecurity check --verbose` to get a detailed report.")
-			os.Exit(1)
 		}
+		log.Info("You can use `paretosecurity check --verbose` to get a detailed report.")
+		os.Exit(severityExitCode(runner.HighestFailedSeverity(claimsTorun, failedChecks)))
+	}
+}
 
-	case <-ctx.Done():
-		log.Warn("Check run timed out")
-		os.Exit(1)
+// failedChecksIn returns the failed states of the checks in claimsTorun,
+// ignoring states left by earlier runs of other checks or profiles.
+func failedChecksIn(claimsTorun []claims.Claim) []shared.LastState {
+	return lo.Filter(shared.GetFailedChecks(), func(state shared.LastState, _ int) bool {
+		return lo.ContainsBy(claimsTorun, func(claim claims.Claim) bool {
+			return lo.ContainsBy(claim.Checks, func(chk check.Check) bool {
+				return chk.UUID() == state.UUID
+			})
+		})
+	})
+}
+
+// runTimeout returns the time budget of a whole check run. Checks run
+// concurrently, so the budget covers the longest per-check timeout.
+func runTimeout(claimsTorun []claims.Claim) time.Duration {
+	timeout := 1 * time.Minute
+	for _, claim := range claimsTorun {
+		for _, chk := range claim.Checks {
+			timeout = max(timeout, shared.CheckTimeout(chk.UUID())+10*time.Second)
+		}
+	}
+	return timeout
+}
+
+// writeResults renders the results in the requested format to stdout, or to
+// the output file if one was given.
+func writeResults(opts checkOptions, results []runner.ClaimResult) error {
+	if opts.output == "" {
+		return runner.Render(os.Stdout, opts.format, results)
+	}
+	file, err := os.Create(opts.output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return runner.Render(file, opts.format, results)
+}
+
+// severityExitCode maps the highest failed severity to the exit code of the
+// check command. The codes start above 1, which cobra and log.Fatal exit
+// with on errors, and failures of unknown severity count as low.
+func severityExitCode(severity check.Severity) int {
+	return max(severity.Rank(), 1) + 1
+}
+
+// expireExemptions turns expired exemptions back into normal checks, saves
+// the config and notifies the user about each of them. Exemptions with an
+// invalid expiry are reported as errors in the config.
+func expireExemptions() {
+	if err := shared.ValidateExemptions(); err != nil {
+		log.WithError(err).Error("Ignoring exemptions with an invalid expiry, use YYYY-MM-DD")
+	}
+	expired := shared.ExpireExemptions(time.Now())
+	if len(expired) == 0 {
+		return
+	}
+	if err := shared.SaveConfig(); err != nil {
+		log.WithError(err).Warn("failed to save config")
+	}
+	for _, claim := range claims.All() {
+		for _, chk := range claim.Checks {
+			if lo.Contains(expired, chk.UUID()) {
+				log.Warnf("Exemption for %s has expired, the check is enabled again", chk.Name())
+				Notify(fmt.Sprintf("Exemption for \"%s\" has expired, the check is enabled again.", chk.Name()))
+			}
+		}
 	}
 }
@@ -5,6 +5,11 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/claims"
+	"github.com/ParetoSecurity/agent/shared"
 )
 
 func Test_CheckCMD(t *testing.T) {
@@ -20,3 +25,37 @@ func Test_CheckCMD(t *testing.T) {
 		t.Fatalf("expected \"%s\" got \"%s\"", expected, string(out))
 	}
 }
+
+func Test_severityExitCode(t *testing.T) {
+	tests := []struct {
+		severity check.Severity
+		expected int
+	}{
+		{check.SeverityCritical, 5},
+		{check.SeverityHigh, 4},
+		{check.SeverityMedium, 3},
+		{check.SeverityLow, 2},
+		{"", 2},
+	}
+	for _, tt := range tests {
+		if got := severityExitCode(tt.severity); got != tt.expected {
+			t.Errorf("severityExitCode(%q) = %d, want %d", tt.severity, got, tt.expected)
+		}
+	}
+}
+
+func Test_runTimeout(t *testing.T) {
+	shared.Config.Checks = map[string]shared.CheckStatus{}
+	defer func() { shared.Config.Checks = nil }()
+
+	chk := claims.All()[0].Checks[0]
+	fast := []claims.Claim{{Title: "fast", Checks: []check.Check{chk}}}
+	if got := runTimeout(fast); got != time.Minute {
+		t.Errorf("runTimeout() = %s, want %s", got, time.Minute)
+	}
+
+	shared.Config.Checks[chk.UUID()] = shared.CheckStatus{Timeout: "5m"}
+	if got := runTimeout(fast); got != 5*time.Minute+10*time.Second {
+		t.Errorf("runTimeout() = %s, want %s", got, 5*time.Minute+10*time.Second)
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/claims"
+	shared "github.com/ParetoSecurity/agent/shared"
+	"github.com/ParetoSecurity/agent/team"
+	"github.com/caarlos0/log"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [--file <state file>] [--at <duration|date>] [--format text|json]",
+	Short: "Show how the checks changed since an earlier run",
+	Long: `Compare the current state of the checks with an earlier run and show
+new failures, new passes, checks that became runnable or not runnable and
+checks whose details changed.
+
+By default the current state is compared with the run before the last one,
+as recorded in the history. Use --at to compare with the state as of an
+earlier time, or --file to compare with a copy of the state file.`,
+	Annotations: needsChecks,
+	Run: func(cc *cobra.Command, args []string) {
+		file, _ := cc.Flags().GetString("file")
+		at, _ := cc.Flags().GetString("at")
+		format, _ := cc.Flags().GetString("format")
+
+		baseline, before, err := diffBaseline(file, at, time.Now())
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load the state to compare with")
+		}
+		after := maps.Clone(shared.GetLastStates())
+		if err := printDiff(os.Stdout, format, newStateDiff(baseline, before, after)); err != nil {
+			log.WithError(err).Fatal("Failed to print the changes")
+		}
+	},
+}
+
+// stateDiff is the output of the diff command.
+type stateDiff struct {
+	// Baseline describes the state that was compared with.
+	Baseline string `json:"baseline"`
+	// SignificantChange is set if the team report would be sent again,
+	// because the set of failing or disabled checks changed.
+	SignificantChange bool                 `json:"significantChange"`
+	Changes           []shared.StateChange `json:"changes"`
+}
+
+func newStateDiff(baseline string, before, after map[string]shared.LastState) stateDiff {
+	all := claims.All()
+	beforeReport := team.StatesReport(all, before)
+	afterReport := team.StatesReport(all, after)
+	changes := shared.DiffStates(before, after)
+	if changes == nil {
+		changes = []shared.StateChange{}
+	}
+	return stateDiff{
+		Baseline:          baseline,
+		SignificantChange: beforeReport.SignificantChange != afterReport.SignificantChange,
+		Changes:           changes,
+	}
+}
+
+// diffBaseline loads the state to compare with: the state file at file, the
+// state as of at according to the history or, if neither is given, the run
+// before the last one.
+func diffBaseline(file, at string, now time.Time) (string, map[string]shared.LastState, error) {
+	if file != "" {
+		states, err := shared.ReadStateFile(file)
+		return file, states, err
+	}
+
+	entries, err := shared.ReadHistory("", time.Time{})
+	if err != nil {
+		return "", nil, err
+	}
+	if at != "" {
+		when, err := parseSince(at, now)
+		if err != nil {
+			return "", nil, err
+		}
+		return "state as of " + when.Format(time.RFC3339), shared.HistoryStates(entries, when), nil
+	}
+
+	runs := shared.HistoryRuns(entries)
+	if len(runs) < 2 {
+		return "", nil, errors.New("no earlier run recorded in the history, use --file to compare with a state file")
+	}
+	previous := runs[len(runs)-2]
+	return "run at " + previous.Format(time.RFC3339), shared.HistoryStates(entries, previous), nil
+}
+
+// diffHeadings are the headings of the kinds of changes in text output.
+var diffHeadings = map[shared.ChangeKind]string{
+	shared.ChangeNewFailure:  "New failures",
+	shared.ChangeNewPass:     "New passes",
+	shared.ChangeRunnable:    "Became runnable",
+	shared.ChangeNotRunnable: "No longer runnable",
+	shared.ChangeDetails:     "Details changed",
+}
+
+// printDiff prints diff in format, which is text or json.
+func printDiff(out io.Writer, format string, diff stateDiff) error {
+	switch format {
+	case "", "text":
+		printStateDiff(out, diff)
+		return nil
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+	return fmt.Errorf("unknown format %q, use text or json", format)
+}
+
+// printStateDiff prints diff as text, grouped by kind of change.
+func printStateDiff(out io.Writer, diff stateDiff) {
+	fmt.Fprintf(out, "Comparing with %s\n", diff.Baseline)
+	if len(diff.Changes) == 0 {
+		fmt.Fprintln(out, "No changes")
+		return
+	}
+
+	var kind shared.ChangeKind
+	for _, change := range diff.Changes {
+		if change.Kind != kind {
+			kind = change.Kind
+			fmt.Fprintf(out, "\n%s:\n", diffHeadings[kind])
+		}
+		if kind == shared.ChangeDetails {
+			fmt.Fprintf(out, "  %s: %q -> %q\n", change.Name, change.FromDetails, change.ToDetails)
+			continue
+		}
+		fmt.Fprintf(out, "  %s: %s -> %s", change.Name, outcomeOrNone(change.From), outcomeOrNone(change.To))
+		if change.ToDetails != "" {
+			fmt.Fprintf(out, " (%s)", change.ToDetails)
+		}
+		fmt.Fprintln(out)
+	}
+
+	if diff.SignificantChange {
+		fmt.Fprintln(out, "\nThe failing or disabled checks changed, the next team report will include this change")
+	}
+}
+
+// outcomeOrNone returns outcome, or "none" for a check that was not recorded.
+func outcomeOrNone(outcome check.Outcome) string {
+	if outcome == "" {
+		return "none"
+	}
+	return string(outcome)
+}
+
+func init() {
+	diffCmd.Flags().String("file", "", "compare with this state file instead of the history")
+	diffCmd.Flags().String("at", "", "compare with the state as of this duration ago, date or timestamp")
+	diffCmd.Flags().String("format", "text", "output format: text or json")
+	rootCmd.AddCommand(diffCmd)
+}
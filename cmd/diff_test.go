@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffBaseline(t *testing.T) {
+	statePath := shared.StatePath
+	shared.StatePath = filepath.Join(t.TempDir(), "state")
+	defer func() { shared.StatePath = statePath }()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	_, _, err := diffBaseline("", "", now)
+	assert.Error(t, err, "no history to compare with")
+
+	assert.NoError(t, shared.AppendHistory([]shared.HistoryEntry{
+		{Time: now.Add(-2 * time.Hour), UUID: "uuid", Name: "Firewall", Outcome: check.OutcomePass},
+		{Time: now.Add(-time.Hour), UUID: "uuid", Name: "Firewall", Outcome: check.OutcomeFail},
+		{Time: now, UUID: "uuid", Name: "Firewall", Outcome: check.OutcomeFail},
+	}))
+
+	baseline, states, err := diffBaseline("", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "run at 2024-05-01T11:00:00Z", baseline)
+	assert.Equal(t, check.OutcomeFail, states["uuid"].Outcome)
+
+	_, states, err = diffBaseline("", "90m", now)
+	assert.NoError(t, err)
+	assert.Equal(t, check.OutcomePass, states["uuid"].Outcome)
+
+	shared.UpdateLastState(shared.LastState{UUID: "uuid", Name: "Firewall", Outcome: check.OutcomePass, State: true})
+	assert.NoError(t, shared.CommitLastState())
+	baseline, states, err = diffBaseline(shared.StatePath, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, shared.StatePath, baseline)
+	assert.True(t, states["uuid"].State)
+}
+
+func TestPrintStateDiff(t *testing.T) {
+	var out bytes.Buffer
+	printStateDiff(&out, stateDiff{Baseline: "run at yesterday"})
+	assert.Contains(t, out.String(), "No changes")
+
+	out.Reset()
+	printStateDiff(&out, stateDiff{
+		Baseline:          "run at yesterday",
+		SignificantChange: true,
+		Changes: []shared.StateChange{
+			{Kind: shared.ChangeNewFailure, Name: "Firewall", From: check.OutcomePass, To: check.OutcomeFail, ToDetails: "Firewall is off"},
+			{Kind: shared.ChangeDetails, Name: "Updates", From: check.OutcomeFail, To: check.OutcomeFail, FromDetails: "3 updates", ToDetails: "5 updates"},
+		},
+	})
+	assert.Contains(t, out.String(), "New failures:\n  Firewall: pass -> fail (Firewall is off)")
+	assert.Contains(t, out.String(), "Details changed:\n  Updates: \"3 updates\" -> \"5 updates\"")
+	assert.Contains(t, out.String(), "next team report")
+}
+
+func TestPrintDiff(t *testing.T) {
+	diff := stateDiff{
+		Baseline: "run at yesterday",
+		Changes: []shared.StateChange{
+			{Kind: shared.ChangeNewPass, Name: "Firewall", From: check.OutcomeFail, To: check.OutcomePass},
+		},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, printDiff(&out, "json", diff))
+	var decoded stateDiff
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, diff, decoded)
+
+	out.Reset()
+	assert.NoError(t, printDiff(&out, "text", diff))
+	assert.Contains(t, out.String(), "New passes:")
+
+	assert.Error(t, printDiff(&out, "yaml", diff))
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/claims"
+	shared "github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
+	"github.com/spf13/cobra"
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [--dry-run] [--only <uuid>] [--yes]",
+	Short: "Fix failing checks",
+	Long: `Fix failing checks that know how to fix themselves.
+
+Each failing check is run first. If it can be fixed, the planned changes are
+shown and applied after you confirm them. Fixes that need root are applied by
+the root helper. After a fix is applied, the check is run again to report
+whether it now passes.
+
+With --dry-run, the planned changes are shown but nothing is changed.
+
+The exit code is 1 if any fix failed or did not make its check pass.`,
+	Annotations: needsChecks,
+	Run: func(cc *cobra.Command, args []string) {
+		opts := fixOptions{}
+		opts.dryRun, _ = cc.Flags().GetBool("dry-run")
+		opts.onlyUUID, _ = cc.Flags().GetString("only")
+		opts.yes, _ = cc.Flags().GetBool("yes")
+
+		ok := fixChecks(context.Background(), claims.All(), opts, cc.InOrStdin(), cc.OutOrStdout())
+		if err := shared.CommitLastState(); err != nil {
+			log.WithError(err).Warn("Failed to commit last state")
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// fixOptions holds the flags of the fix command.
+type fixOptions struct {
+	dryRun   bool
+	onlyUUID string
+	yes      bool
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+	fixCmd.Flags().Bool("dry-run", false, "only show the planned changes")
+	fixCmd.Flags().String("only", "", "only fix the check with this UUID")
+	fixCmd.Flags().Bool("yes", false, "apply fixes without asking for confirmation")
+}
+
+// fixChecks fixes the failing checks of claimsToFix, asking for confirmation
+// on in before each fix, and reports progress on out. It returns false if any
+// fix failed or did not make its check pass.
+func fixChecks(ctx context.Context, claimsToFix []claims.Claim, opts fixOptions, in io.Reader, out io.Writer) bool {
+	// Load the stored states so that updating them keeps the other checks
+	shared.GetLastStates()
+	// Decide what to fix on the current state, not on cached results
+	ctx = shared.WithFreshResults(ctx)
+
+	reader := bufio.NewReader(in)
+	ok := true
+	found := false
+	for _, claim := range claimsToFix {
+		for _, chk := range claim.Checks {
+			if opts.onlyUUID != "" && chk.UUID() != opts.onlyUUID {
+				continue
+			}
+			found = true
+			if shared.IsCheckExempted(chk.UUID()) {
+				continue
+			}
+			fixer, isFixer := chk.(check.Fixer)
+			if !isFixer {
+				if opts.onlyUUID != "" {
+					fmt.Fprintf(out, "%s: no automatic fix available\n", chk.Name())
+				}
+				continue
+			}
+			if !fixCheck(ctx, chk, fixer, opts, reader, out) {
+				ok = false
+			}
+		}
+	}
+	if opts.onlyUUID != "" && !found {
+		fmt.Fprintf(out, "Check %s not found\n", opts.onlyUUID)
+		return false
+	}
+	return ok
+}
+
+// fixCheck runs chk and, if it fails, shows the plan of fixer and applies it
+// once confirmed. It returns false if the fix failed or chk still fails.
+func fixCheck(ctx context.Context, chk check.Check, fixer check.Fixer, opts fixOptions, reader *bufio.Reader, out io.Writer) bool {
+	viaHelper := fixer.FixRequiresRoot() && !shared.IsRoot()
+	res, plan := planFix(ctx, chk, fixer, viaHelper)
+	switch res.Outcome {
+	case check.OutcomePass, check.OutcomeNotApplicable, check.OutcomeSkipped:
+		if opts.onlyUUID != "" {
+			fmt.Fprintf(out, "%s: nothing to fix\n", chk.Name())
+		}
+		return true
+	case check.OutcomeError:
+		fmt.Fprintf(out, "%s: %s\n", chk.Name(), res.Reason)
+		return false
+	}
+
+	if len(plan) == 0 {
+		fmt.Fprintf(out, "%s: %s, no automatic fix available\n", chk.Name(), res.Reason)
+		return true
+	}
+	fmt.Fprintf(out, "%s: %s\n", chk.Name(), res.Reason)
+	for _, step := range plan {
+		fmt.Fprintf(out, "  - %s\n", step)
+	}
+	if opts.dryRun {
+		return true
+	}
+	if !opts.yes && !confirm(reader, out, "Apply this fix?") {
+		fmt.Fprintln(out, "  Skipped")
+		return true
+	}
+
+	var err error
+	if viaHelper {
+		err = shared.RunFixViaHelper(ctx, chk.UUID(), plan)
+	} else {
+		err = fixer.Apply(ctx)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "  Failed to apply fix: %s\n", err)
+		return false
+	}
+
+	started := time.Now()
+	res = evaluateWithTimeout(ctx, chk)
+	shared.UpdateLastState(shared.NewLastState(chk, res))
+	if err := shared.AppendHistory([]shared.HistoryEntry{{
+		Time:       started,
+		UUID:       chk.UUID(),
+		Name:       chk.Name(),
+		Outcome:    res.Outcome,
+		Details:    res.Reason,
+		DurationMs: time.Since(started).Milliseconds(),
+	}}); err != nil {
+		log.WithError(err).Warn("failed to append check history")
+	}
+	if !res.Passed() {
+		fmt.Fprintf(out, "  Fix applied, but the check still fails: %s\n", res.Reason)
+		return false
+	}
+	fmt.Fprintf(out, "  Fixed, the check now passes\n")
+	return true
+}
+
+// planFix runs chk and returns its result and the plan of fixer. Fixes the
+// root helper applies are planned by the helper, as only it can see what
+// needs fixing, so that the plan confirmed is the one applied.
+func planFix(ctx context.Context, chk check.Check, fixer check.Fixer, viaHelper bool) (check.Result, []string) {
+	if !viaHelper {
+		res := evaluateWithTimeout(ctx, chk)
+		return res, fixer.Plan()
+	}
+	ctx, cancel := context.WithTimeout(ctx, shared.CheckTimeout(chk.UUID())+5*time.Second)
+	defer cancel()
+	res, plan, err := shared.PlanFixViaHelper(ctx, chk.UUID())
+	if err != nil {
+		return check.Result{Outcome: check.OutcomeError, Reason: err.Error()}, nil
+	}
+	return res, plan
+}
+
+// evaluateWithTimeout runs chk with its configured timeout.
+func evaluateWithTimeout(ctx context.Context, chk check.Check) check.Result {
+	ctx, cancel := context.WithTimeout(ctx, shared.CheckTimeout(chk.UUID()))
+	defer cancel()
+	return check.Evaluate(ctx, chk)
+}
+
+// confirm asks question on out and returns whether the answer read from
+// reader is yes. Anything else, including no answer at all, means no.
+func confirm(reader *bufio.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
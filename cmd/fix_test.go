@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/claims"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixableCheck is a failing check whose fix makes it pass.
+type fixableCheck struct {
+	passed   bool
+	fixed    bool
+	applyErr error
+	noFix    bool
+}
+
+func (f *fixableCheck) Name() string                  { return "Fixable check" }
+func (f *fixableCheck) PassedMessage() string         { return "passed" }
+func (f *fixableCheck) FailedMessage() string         { return "failed" }
+func (f *fixableCheck) Run(ctx context.Context) error { f.passed = f.fixed; return nil }
+func (f *fixableCheck) Passed() bool                  { return f.passed }
+func (f *fixableCheck) IsRunnable() bool              { return true }
+func (f *fixableCheck) UUID() string                  { return "fixable-uuid" }
+func (f *fixableCheck) RequiresRoot() bool            { return false }
+func (f *fixableCheck) FixRequiresRoot() bool         { return false }
+
+func (f *fixableCheck) Status() string {
+	if f.passed {
+		return f.PassedMessage()
+	}
+	return f.FailedMessage()
+}
+
+func (f *fixableCheck) Plan() []string {
+	if f.noFix {
+		return nil
+	}
+	return []string{"Fix the thing"}
+}
+
+func (f *fixableCheck) Apply(ctx context.Context) error {
+	if f.applyErr != nil {
+		return f.applyErr
+	}
+	f.fixed = true
+	return nil
+}
+
+func TestFixChecks(t *testing.T) {
+	statePath := shared.StatePath
+	shared.StatePath = filepath.Join(t.TempDir(), "state")
+	defer func() { shared.StatePath = statePath }()
+
+	tests := []struct {
+		name     string
+		check    *fixableCheck
+		opts     fixOptions
+		input    string
+		wantOK   bool
+		wantOut  []string
+		wantFix  bool
+		notInOut string
+	}{
+		{
+			name:    "confirmed fix passes",
+			check:   &fixableCheck{},
+			input:   "y\n",
+			wantOK:  true,
+			wantOut: []string{"Fixable check: failed", "  - Fix the thing", "Apply this fix? [y/N]", "Fixed, the check now passes"},
+			wantFix: true,
+		},
+		{
+			name:     "declined fix is skipped",
+			check:    &fixableCheck{},
+			input:    "n\n",
+			wantOK:   true,
+			wantOut:  []string{"Skipped"},
+			notInOut: "Fixed",
+		},
+		{
+			name:     "dry run only shows the plan",
+			check:    &fixableCheck{},
+			opts:     fixOptions{dryRun: true},
+			wantOK:   true,
+			wantOut:  []string{"  - Fix the thing"},
+			notInOut: "Apply this fix?",
+		},
+		{
+			name:     "yes skips the prompt",
+			check:    &fixableCheck{},
+			opts:     fixOptions{yes: true},
+			wantOK:   true,
+			wantOut:  []string{"Fixed, the check now passes"},
+			wantFix:  true,
+			notInOut: "Apply this fix?",
+		},
+		{
+			name:    "failed fix",
+			check:   &fixableCheck{applyErr: errors.New("boom")},
+			input:   "y\n",
+			wantOK:  false,
+			wantOut: []string{"Failed to apply fix: boom"},
+		},
+		{
+			name:    "passing check is left alone",
+			check:   &fixableCheck{fixed: true},
+			opts:    fixOptions{onlyUUID: "fixable-uuid"},
+			wantOK:  true,
+			wantOut: []string{"Fixable check: nothing to fix"},
+		},
+		{
+			name:    "empty plan",
+			check:   &fixableCheck{noFix: true},
+			wantOK:  true,
+			wantOut: []string{"no automatic fix available"},
+		},
+		{
+			name:    "unknown UUID",
+			check:   &fixableCheck{},
+			opts:    fixOptions{onlyUUID: "unknown"},
+			wantOK:  false,
+			wantOut: []string{"Check unknown not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			toFix := []claims.Claim{{Title: "Test", Checks: []check.Check{tt.check}}}
+			ok := fixChecks(context.Background(), toFix, tt.opts, strings.NewReader(tt.input), out)
+
+			assert.Equal(t, tt.wantOK, ok)
+			for _, want := range tt.wantOut {
+				assert.Contains(t, out.String(), want)
+			}
+			if tt.notInOut != "" {
+				assert.NotContains(t, out.String(), tt.notInOut)
+			}
+			if tt.wantFix {
+				assert.True(t, tt.check.Passed())
+				state, found, _ := shared.GetLastState(tt.check.UUID())
+				assert.True(t, found)
+				assert.True(t, state.State)
+			}
+		})
+	}
+}
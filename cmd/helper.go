@@ -1,10 +1,20 @@
 package cmd
 
 import (
+	"context"
+	"crypto/ed25519"
 	"encoding/json"
+	"fmt"
 	"net"
 	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
 	shared "github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
@@ -12,69 +22,175 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func runHelper() {
-	// Get the socket from file descriptor 0
-	file := os.NewFile(0, "socket")
-	listener, err := net.FileListener(file)
+// resultCache holds the results of root checks while the helper runs. It is
+// nil when results are not cached.
+var resultCache *shared.ResultCache
+
+// signingKey signs the results of root checks while the helper runs. It is
+// nil when results are not signed.
+var signingKey ed25519.PrivateKey
+
+// runHelper serves connections on the socket passed by systemd, or on
+// socketPath, until it was idle for idleTimeout or is asked to terminate.
+func runHelper(socketPath string, idleTimeout time.Duration) {
+	listener, err := helperListener(socketPath)
 	if err != nil {
-		log.WithError(err).Fatal("Failed to create listener, not running in systemd context")
+		log.WithError(err).Fatal("Failed to create listener")
 	}
 	defer listener.Close()
+	resultCache = shared.NewResultCache(shared.ResultCachePath)
+	if signingKey, err = shared.LoadHelperKey(); err != nil {
+		log.WithError(err).Warn("Failed to load the helper key, results will not be signed")
+	}
 	log.WithField("socket", shared.SocketPath).WithField("version", shared.Version).Info("Listening on socket")
 
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.WithError(err).Warn("Failed to accept connection")
-			continue
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	newHelperServer(listener, serveConnection, idleTimeout).serve(ctx)
+}
+
+// serveConnection handles conn if the caller is allowed to use the helper,
+// as decided by the credentials the kernel reports for it.
+func serveConnection(conn net.Conn) {
+	client, err := authorizeConnection(conn)
+	logger := log.WithField("uid", client.cred.UID).WithField("pid", client.cred.PID).WithField("exe", client.cred.Exe)
+	if err != nil {
+		logger.WithError(err).Warn("Rejected connection")
+		rejectConnection(conn, err)
+		return
+	}
+	logger.WithField("admin", client.mayFix).Info("Accepted connection")
+	handleConnection(conn, client)
+}
+
+// helperClient is the caller on a connection the helper accepted.
+type helperClient struct {
+	cred shared.PeerCred
+	// mayFix is set if the caller may have the helper apply fixes.
+	mayFix bool
+}
 
-		handleConnection(conn)
-		break
+// authorizeConnection returns the caller on conn, and an error unless the
+// allowlist lets it use the helper. Callers are rejected when their
+// credentials or the allowlist cannot be read.
+func authorizeConnection(conn net.Conn) (helperClient, error) {
+	if hc, ok := conn.(*helperConn); ok {
+		conn = hc.Conn
+	}
+	cred, err := shared.PeerCredentials(conn)
+	client := helperClient{cred: cred}
+	if err != nil {
+		return client, err
+	}
+	allowlist, err := shared.LoadHelperAllowlist()
+	if err != nil {
+		return client, err
+	}
+	client.mayFix = shared.AuthorizeHelperFix(cred, allowlist) == nil
+	return client, shared.AuthorizeHelperClient(cred, allowlist)
+}
+
+// rejectConnection tells a version 2 client why it was rejected and closes
+// conn. Version 1 clients only see the connection closed.
+func rejectConnection(conn net.Conn, reason error) {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(time.Second)); err != nil {
+		return
+	}
+	var msg helperMessage
+	if err := json.NewDecoder(conn).Decode(&msg); err != nil || msg.Version == 0 {
+		return
+	}
+	hello := shared.HelperHello{Version: shared.HelperProtocolVersion, Error: "not authorized: " + reason.Error()}
+	if err := json.NewEncoder(conn).Encode(hello); err != nil {
+		log.Debugf("Failed to write to connection: %v\n", err)
 	}
 }
 
+// helperMessage is the first message on a connection: either a version 1
+// request or the version 2 handshake.
+type helperMessage struct {
+	Version int    `json:"version"`
+	UUID    string `json:"uuid"`
+	Action  string `json:"action"`
+}
+
 // handleConnection handles an incoming network connection.
 // It reads input from the connection, processes the input to run checks,
-// and sends back the status of the checks as a JSON response.
+// and sends back the results of the checks as a JSON response.
 //
-// The input is expected to be a JSON object containing a "uuid" key.
-// The function will look for checks that are runnable, require root,
-// and match the provided UUID. It will run those checks and collect their status.
-func handleConnection(conn net.Conn) {
+// Clients speaking version 2 of the protocol start with a handshake and then
+// send a batch of UUIDs, see shared.HelperProtocolVersion. Version 1 clients
+// send a JSON object containing a "uuid" key instead. Only registered checks
+// that require root are run, and only admins may have them fixed.
+func handleConnection(conn net.Conn, client helperClient) {
 	defer conn.Close()
 	log.Info("Connection received")
 
-	// Read input from connection
+	// Read input from connection, requests carry no parameters but UUIDs
 	decoder := json.NewDecoder(conn)
-	var input map[string]string
-	if err := decoder.Decode(&input); err != nil {
+	decoder.DisallowUnknownFields()
+	var msg helperMessage
+	if err := decoder.Decode(&msg); err != nil {
 		log.Debugf("Failed to decode input: %v\n", err)
 		return
 	}
-	uuid, ok := input["uuid"]
-	if !ok {
+	if msg.Version == 0 {
+		handleLegacyRequest(conn, msg, client)
+		return
+	}
+
+	version := min(msg.Version, shared.HelperProtocolVersion)
+	log.Debugf("Client speaks protocol version %d, using %d", msg.Version, version)
+	encoder := json.NewEncoder(conn)
+	if err := encoder.Encode(shared.HelperHello{Version: version}); err != nil {
+		log.Debugf("Failed to write to connection: %v\n", err)
+		return
+	}
+
+	var req shared.HelperRequest
+	if err := decoder.Decode(&req); err != nil {
+		log.Debugf("Failed to decode request: %v\n", err)
+		return
+	}
+	log.Debugf("Received UUIDs: %v, action: %s", req.UUIDs, req.Action)
+
+	var response shared.HelperResponse
+	switch {
+	case req.Action == shared.HelperActionProductUUID:
+		response = productUUIDResponse()
+	case req.Action == shared.HelperActionFix && !client.mayFix:
+		response.Results = denyFix(req.UUIDs, client)
+	case req.Action == shared.HelperActionFix && len(req.Plan) == 0:
+		response.Results = refuseRequest(req.UUIDs, "the plan of the fix was not confirmed, please update the agent")
+	default:
+		response.Results = runHelperRequest(req, client)
+	}
+	if err := encoder.Encode(response); err != nil {
+		log.Debugf("Failed to write to connection: %v\n", err)
+	}
+}
+
+// handleLegacyRequest answers a version 1 request with a map of the UUID to
+// whether the check passed. Checks that could not be run are left out.
+func handleLegacyRequest(conn net.Conn, msg helperMessage, client helperClient) {
+	if msg.UUID == "" {
 		log.Debugf("UUID not found in input")
 		return
 	}
-	log.Debugf("Received UUID: %s", uuid)
+	log.Debugf("Received UUID: %s, action: %s", msg.UUID, msg.Action)
 
 	status := map[string]bool{}
-	for _, claim := range claims.All {
-		for _, chk := range claim.Checks {
-			if chk.IsRunnable() && chk.RequiresRoot() && uuid == chk.UUID() {
-				log.Infof("Running check %s\n", chk.UUID())
-				if chk.Run() != nil {
-					log.Warnf("Failed to run check %s\n", chk.UUID())
-					continue
-				}
-				log.Infof("Check %s completed\n", chk.UUID())
-				status[chk.UUID()] = chk.Passed()
-			}
-		}
+	var res shared.HelperResult
+	if msg.Action == shared.HelperActionFix && !client.mayFix {
+		res = denyFix([]string{msg.UUID}, client)[msg.UUID]
+	} else {
+		res = runRootCheck(msg.UUID, shared.HelperRequest{Action: msg.Action})
+	}
+	if res.Error == "" && (res.Outcome == check.OutcomePass || res.Outcome == check.OutcomeFail) {
+		status[msg.UUID] = res.Passed()
 	}
 
-	// Handle the request
 	response, err := json.Marshal(status)
 	if err != nil {
 		log.Debugf("Failed to marshal response: %v\n", err)
@@ -85,22 +201,188 @@ func handleConnection(conn net.Conn) {
 	}
 }
 
-var helperCmd = &cobra.Command{
-	Use:   "helper [--socket]",
-	Short: "A root helper",
-	Long:  `A root helper that listens on a Unix domain socket and responds to authenticated requests.`,
-	Run: func(cmd *cobra.Command, args []string) {
+// denyFix answers a request to fix the checks with the given UUIDs from a
+// client that is not an admin.
+func denyFix(uuids []string, client helperClient) map[string]shared.HelperResult {
+	log.WithField("uid", client.cred.UID).Warn("Refusing to apply fixes for a client that is not an admin")
+	return refuseRequest(uuids, fmt.Sprintf("uid %d may not apply fixes, add it to the admins in %s", client.cred.UID, shared.HelperAllowlistPath))
+}
+
+// refuseRequest answers a request for the checks with the given UUIDs with
+// reason.
+func refuseRequest(uuids []string, reason string) map[string]shared.HelperResult {
+	results := map[string]shared.HelperResult{}
+	for _, uuid := range uuids {
+		results[uuid] = shared.HelperResult{Error: reason}
+	}
+	return results
+}
+
+// productUUIDResponse answers the product-uuid action.
+func productUUIDResponse() shared.HelperResponse {
+	id, err := shared.ProductUUID()
+	if err != nil {
+		log.WithError(err).Warn("Failed to read the product UUID")
+		return shared.HelperResponse{Error: err.Error()}
+	}
+	return shared.HelperResponse{ProductUUID: id}
+}
+
+// runHelperRequest runs or fixes the checks of req concurrently and returns
+// their results keyed by UUID. The results of runs are signed for client, so
+// that the agent can tell them from results forged in the state file.
+func runHelperRequest(req shared.HelperRequest, client helperClient) map[string]shared.HelperResult {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := map[string]shared.HelperResult{}
+	for _, uuid := range lo.Uniq(req.UUIDs) {
+		wg.Add(1)
+		go func(uuid string) {
+			defer wg.Done()
+			res := runRootCheck(uuid, req)
+			if signingKey != nil && (req.Action == "" || req.Action == shared.HelperActionRun) && res.Error == "" {
+				shared.SignHelperResult(signingKey, uuid, client.cred.UID, &res, time.Now().Add(-time.Duration(res.AgeSeconds)*time.Second))
+			}
+			mu.Lock()
+			results[uuid] = res
+			mu.Unlock()
+		}(uuid)
+	}
+	wg.Wait()
+	return results
+}
 
-		socketFlag, _ := cmd.Flags().GetString("socket")
-		if lo.IsNotEmpty(socketFlag) {
-			shared.SocketPath = socketFlag
+// runRootCheck runs the check with the given UUID or, with the plan and fix
+// actions of req, plans or applies its fix. The result has Error set if the
+// check cannot be run by the helper. Unless req asks for fresh results, a
+// cached result is returned if there is one.
+func runRootCheck(uuid string, req shared.HelperRequest) shared.HelperResult {
+	// Plugins that require root are run by the helper as well
+	loadPlugins()
+	chk := claims.Find(uuid)
+	action := req.Action
+	switch {
+	case action != "" && action != shared.HelperActionRun && action != shared.HelperActionFix && action != shared.HelperActionPlan:
+		log.Warnf("Unknown action %s\n", action)
+		return shared.HelperResult{Error: fmt.Sprintf("unknown action %s", action)}
+	case chk == nil:
+		log.Warnf("Check %s is not registered\n", uuid)
+		return shared.HelperResult{Error: fmt.Sprintf("check %s is not registered", uuid)}
+	case action == shared.HelperActionPlan:
+		return planRootFix(chk, req.CheckTimeout(uuid))
+	case action == shared.HelperActionFix:
+		return applyRootFix(chk, req.Plan, req.CheckTimeout(uuid))
+	case !chk.RequiresRoot():
+		log.Warnf("Check %s does not require root, not running\n", uuid)
+		return shared.HelperResult{Error: fmt.Sprintf("check %s does not require root", uuid)}
+	case !chk.IsRunnable():
+		log.Infof("Check %s is not runnable\n", uuid)
+		return shared.HelperResult{Error: fmt.Sprintf("check %s is not runnable: %s", uuid, chk.Status())}
+	}
+
+	reg, _ := claims.Registry.Lookup(uuid, runtime.GOOS)
+	cacheable := resultCache != nil && reg.CacheTTL > 0
+	if cacheable && !req.Fresh {
+		if res, age, ok := resultCache.Get(uuid, reg.CacheTTL, reg.Watch); ok {
+			log.Infof("Check %s answered from cache, %s old\n", uuid, age.Round(time.Second))
+			return shared.HelperResult{Result: res, Cached: true, AgeSeconds: int64(age.Seconds())}
 		}
+	}
+
+	log.Infof("Running check %s\n", uuid)
+	ctx, cancel := context.WithTimeout(context.Background(), req.CheckTimeout(uuid))
+	defer cancel()
+	res := check.Evaluate(ctx, chk)
+	log.Infof("Check %s completed: %s\n", uuid, res.Outcome)
+	// Errors are worth retrying, so only actual findings are cached
+	if cacheable && (res.Outcome == check.OutcomePass || res.Outcome == check.OutcomeFail) {
+		resultCache.Put(uuid, res, reg.Watch)
+	}
+	return shared.HelperResult{Result: res}
+}
 
-		runHelper()
+// rootFixer returns the fix of chk, or an error result if it has no fix that
+// requires root.
+func rootFixer(chk check.Check) (check.Fixer, *shared.HelperResult) {
+	fixer, isFixer := chk.(check.Fixer)
+	if !isFixer || !fixer.FixRequiresRoot() {
+		log.Warnf("Check %s cannot be fixed by the helper\n", chk.UUID())
+		return nil, &shared.HelperResult{Error: fmt.Sprintf("check %s cannot be fixed by the helper", chk.UUID())}
+	}
+	return fixer, nil
+}
+
+// planRootFix runs chk for up to timeout and returns its result with the
+// plan of its fix if it fails.
+func planRootFix(chk check.Check, timeout time.Duration) shared.HelperResult {
+	fixer, refused := rootFixer(chk)
+	if refused != nil {
+		return *refused
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	res := check.Evaluate(ctx, chk)
+	if res.Outcome != check.OutcomeFail {
+		return shared.HelperResult{Result: res}
+	}
+	return shared.HelperResult{Result: res, Plan: fixer.Plan()}
+}
+
+// applyRootFix runs chk and, if it fails, applies its fix and runs it again.
+// The result is that of the last run, with Error set if chk has no fix that
+// requires root or the fix could not be applied. Unless confirmed is nil,
+// the fix is only applied if its plan is still the confirmed one. Each run
+// of chk may take up to timeout.
+func applyRootFix(chk check.Check, confirmed []string, timeout time.Duration) shared.HelperResult {
+	fixer, refused := rootFixer(chk)
+	if refused != nil {
+		return *refused
+	}
+
+	// Allow for running the check, fixing it and running it again
+	ctx, cancel := context.WithTimeout(context.Background(), 3*timeout)
+	defer cancel()
+
+	res := check.Evaluate(ctx, chk)
+	plan := fixer.Plan()
+	if res.Outcome != check.OutcomeFail || len(plan) == 0 {
+		return shared.HelperResult{Result: res}
+	}
+	if confirmed != nil && !slices.Equal(plan, confirmed) {
+		log.Warnf("Plan of check %s changed since it was confirmed, not fixing\n", chk.UUID())
+		return shared.HelperResult{Result: res, Error: "the fix changed since it was confirmed, run the fix again"}
+	}
+
+	log.Infof("Fixing check %s\n", chk.UUID())
+	if resultCache != nil {
+		resultCache.Invalidate(chk.UUID())
+	}
+	if err := fixer.Apply(ctx); err != nil {
+		log.WithError(err).Warnf("Failed to fix check %s\n", chk.UUID())
+		return shared.HelperResult{Result: res, Error: fmt.Sprintf("failed to fix check %s: %v", chk.UUID(), err)}
+	}
+	res = check.Evaluate(ctx, chk)
+	log.Infof("Check %s fixed: %s\n", chk.UUID(), res.Outcome)
+	return shared.HelperResult{Result: res}
+}
+
+var helperCmd = &cobra.Command{
+	Use:   "helper [--socket <path>] [--idle-timeout <duration>]",
+	Short: "A root helper",
+	Long: `A root helper that listens on a Unix domain socket and responds to authenticated requests.
+
+The helper uses the socket passed by systemd socket activation, or listens on
+--socket when started on its own. It serves many connections at once and
+exits once no connection arrived for --idle-timeout, or never if it is 0.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		socketPath, _ := cmd.Flags().GetString("socket")
+		idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+		runHelper(socketPath, idleTimeout)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(helperCmd)
-	helperCmd.Flags().Bool("socket", false, "socket path")
+	helperCmd.Flags().String("socket", "", "socket path to listen on without socket activation")
+	helperCmd.Flags().Duration("idle-timeout", helperDefaultIdleTimeout, "exit after being idle for this long, 0 to never exit")
 }
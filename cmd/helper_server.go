@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	shared "github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
+)
+
+const (
+	// helperIOTimeout bounds each read from and write to a client, so that
+	// stalled clients cannot hold a connection open.
+	helperIOTimeout = 10 * time.Second
+	// helperMaxRequestSize bounds how much a client may send on a connection.
+	helperMaxRequestSize = 64 * 1024
+	// helperDefaultIdleTimeout is how long the helper waits for another
+	// connection before it exits.
+	helperDefaultIdleTimeout = time.Minute
+)
+
+var errRequestTooLarge = errors.New("request too large")
+
+// helperConn sets a fresh deadline before every read and write on the
+// connection, and fails reads once the client sent more than the maximum
+// request size.
+type helperConn struct {
+	net.Conn
+	timeout   time.Duration
+	remaining int64
+}
+
+func (c *helperConn) Read(b []byte) (int, error) {
+	if c.remaining <= 0 {
+		return 0, errRequestTooLarge
+	}
+	if int64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+func (c *helperConn) Write(b []byte) (int, error) {
+	if err := c.Conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
+
+// helperServer serves connections to the root helper concurrently and stops
+// once no connection arrived for idleTimeout.
+type helperServer struct {
+	listener       net.Listener
+	handle         func(net.Conn)
+	idleTimeout    time.Duration
+	ioTimeout      time.Duration
+	maxRequestSize int64
+
+	mu     sync.Mutex
+	active int
+	idle   *time.Timer
+	wg     sync.WaitGroup
+}
+
+// newHelperServer returns a server for listener that handles each
+// connection with handle. An idleTimeout of zero keeps it running until
+// ctx is done.
+func newHelperServer(listener net.Listener, handle func(net.Conn), idleTimeout time.Duration) *helperServer {
+	return &helperServer{
+		listener:       listener,
+		handle:         handle,
+		idleTimeout:    idleTimeout,
+		ioTimeout:      helperIOTimeout,
+		maxRequestSize: helperMaxRequestSize,
+	}
+}
+
+// serve accepts connections until ctx is done or the server was idle for
+// too long, then waits for the connections being handled to finish.
+func (s *helperServer) serve(ctx context.Context) {
+	stop := context.AfterFunc(ctx, func() {
+		log.Info("Shutting down")
+		s.listener.Close()
+	})
+	defer stop()
+	s.mu.Lock()
+	if s.idleTimeout > 0 {
+		s.idle = time.AfterFunc(s.idleTimeout, s.shutdownIdle)
+	}
+	s.mu.Unlock()
+
+	for {
+		conn, err := s.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			break
+		}
+		if err != nil {
+			log.WithError(err).Warn("Failed to accept connection")
+			continue
+		}
+
+		s.mu.Lock()
+		s.active++
+		if s.idle != nil {
+			s.idle.Stop()
+		}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.release()
+			s.handle(&helperConn{Conn: conn, timeout: s.ioTimeout, remaining: s.maxRequestSize})
+		}()
+	}
+	s.wg.Wait()
+}
+
+// release marks a connection as done and starts the idle timer once no
+// connection is left.
+func (s *helperServer) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if s.active == 0 && s.idle != nil {
+		s.idle.Reset(s.idleTimeout)
+	}
+}
+
+// shutdownIdle closes the listener unless a connection arrived meanwhile.
+func (s *helperServer) shutdownIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active > 0 {
+		return
+	}
+	log.WithField("idle", s.idleTimeout).Info("Idle, shutting down")
+	s.listener.Close()
+}
+
+// helperListener returns the socket passed by systemd on fd 0 or, when not
+// started by socket activation, a socket listening on path.
+func helperListener(path string) (net.Listener, error) {
+	file := os.NewFile(0, "socket")
+	if listener, err := net.FileListener(file); err == nil {
+		log.Debug("Using the socket passed by systemd")
+		return listener, nil
+	}
+	if path == "" {
+		return nil, errors.New("not running in systemd context and no --socket given")
+	}
+
+	// Remove a socket left behind by an earlier run
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Anyone may connect, clients are authorized by their credentials
+	if err := os.Chmod(path, 0o666); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	shared.SocketPath = path
+	return listener, nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+// listenUnix returns a listener on a new socket and its path.
+func listenUnix(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "helper.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	return listener, path
+}
+
+// echoLine answers the first line sent on conn with the same line.
+func echoLine(conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	_, _ = conn.Write([]byte(line))
+}
+
+func TestHelperServer_Concurrent(t *testing.T) {
+	listener, path := listenUnix(t)
+	server := newHelperServer(listener, echoLine, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		server.serve(ctx)
+		close(done)
+	}()
+
+	// Both connections are open at the same time and both get served
+	first, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	defer second.Close()
+
+	for _, conn := range []net.Conn{second, first} {
+		_, err := conn.Write([]byte("ping\n"))
+		assert.NoError(t, err)
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "ping\n", line)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+}
+
+func TestHelperServer_IdleShutdown(t *testing.T) {
+	listener, path := listenUnix(t)
+	server := newHelperServer(listener, echoLine, 200*time.Millisecond)
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		server.serve(context.Background())
+		close(done)
+	}()
+
+	// A connection keeps the server alive past the idle timeout
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	time.Sleep(300 * time.Millisecond)
+	_, err = conn.Write([]byte("ping\n"))
+	assert.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "ping\n", line)
+	conn.Close()
+
+	select {
+	case <-done:
+		assert.Greater(t, time.Since(start), 500*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down when idle")
+	}
+}
+
+func TestHelperServer_Limits(t *testing.T) {
+	listener, path := listenUnix(t)
+	errs := make(chan error, 1)
+	server := newHelperServer(listener, func(conn net.Conn) {
+		defer conn.Close()
+		_, err := io.ReadAll(conn)
+		errs <- err
+	}, 0)
+	server.ioTimeout = 100 * time.Millisecond
+	server.maxRequestSize = 16
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.serve(ctx)
+
+	// Sending more than the maximum request size fails the read
+	conn, err := net.Dial("unix", path)
+	assert.NoError(t, err)
+	_, _ = conn.Write([]byte("01234567890123456789"))
+	assert.ErrorIs(t, <-errs, errRequestTooLarge)
+	conn.Close()
+
+	// A client that sends nothing runs into the read deadline
+	conn, err = net.Dial("unix", path)
+	assert.NoError(t, err)
+	defer conn.Close()
+	select {
+	case err := <-errs:
+		assert.True(t, errors.Is(err, os.ErrDeadlineExceeded), "error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read deadline was not applied")
+	}
+}
+
+func TestHelperListener_Socket(t *testing.T) {
+	socketPath := shared.SocketPath
+	defer func() { shared.SocketPath = socketPath }()
+
+	path := filepath.Join(t.TempDir(), "helper.sock")
+	listener, err := helperListener(path)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o666), info.Mode().Perm())
+	assert.Equal(t, path, shared.SocketPath)
+}
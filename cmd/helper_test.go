@@ -1,17 +1,25 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
+	"github.com/ParetoSecurity/agent/shared"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestHandleConnection(t *testing.T) {
 
-	claims.All = []claims.Claim{}
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
 
 	// Create a pair of connected sockets
 	server, client := net.Pipe()
@@ -19,7 +27,7 @@ func TestHandleConnection(t *testing.T) {
 	defer client.Close()
 
 	// Run handleConnection in a separate goroutine
-	go handleConnection(server)
+	go handleConnection(server, adminClient)
 
 	// Send a valid JSON payload with a "uuid" field
 	input := map[string]string{"uuid": "test-uuid"}
@@ -39,3 +47,381 @@ func TestHandleConnection(t *testing.T) {
 	expected := map[string]bool{}
 	assert.Equal(t, expected, response)
 }
+
+// rootCheck is a check that requires root and always passes.
+type rootCheck struct {
+	passed bool
+}
+
+func (r *rootCheck) Name() string                  { return "Root check" }
+func (r *rootCheck) PassedMessage() string         { return "passed" }
+func (r *rootCheck) FailedMessage() string         { return "failed" }
+func (r *rootCheck) Run(ctx context.Context) error { r.passed = true; return nil }
+func (r *rootCheck) Passed() bool                  { return r.passed }
+func (r *rootCheck) IsRunnable() bool              { return true }
+func (r *rootCheck) UUID() string                  { return "root-uuid" }
+func (r *rootCheck) Status() string                { return "passed" }
+func (r *rootCheck) RequiresRoot() bool            { return true }
+
+func TestHandleConnection_RegisteredCheck(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &rootCheck{} },
+	})
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	go handleConnection(server, adminClient)
+
+	if err := json.NewEncoder(client).Encode(map[string]string{"uuid": "root-uuid"}); err != nil {
+		t.Fatalf("failed to encode input: %v", err)
+	}
+	var response map[string]bool
+	if err := json.NewDecoder(client).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	assert.Equal(t, map[string]bool{"root-uuid": true}, response)
+}
+
+// rootFixableCheck is a failing check whose fix requires root.
+type rootFixableCheck struct {
+	rootCheck
+	fixed bool
+}
+
+func (r *rootFixableCheck) Run(ctx context.Context) error   { r.passed = r.fixed; return nil }
+func (r *rootFixableCheck) UUID() string                    { return "root-fixable-uuid" }
+func (r *rootFixableCheck) Plan() []string                  { return []string{"Fix the thing"} }
+func (r *rootFixableCheck) Apply(ctx context.Context) error { r.fixed = true; return nil }
+func (r *rootFixableCheck) FixRequiresRoot() bool           { return true }
+
+func TestHandleConnection_Fix(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &rootFixableCheck{} },
+	})
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &rootCheck{} },
+	})
+
+	tests := []struct {
+		uuid     string
+		expected map[string]bool
+	}{
+		{"root-fixable-uuid", map[string]bool{"root-fixable-uuid": true}},
+		// Checks without a fix are refused
+		{"root-uuid", map[string]bool{}},
+	}
+	for _, tt := range tests {
+		server, client := net.Pipe()
+		go handleConnection(server, adminClient)
+
+		input := map[string]string{"uuid": tt.uuid, "action": "fix"}
+		if err := json.NewEncoder(client).Encode(input); err != nil {
+			t.Fatalf("failed to encode input: %v", err)
+		}
+		var response map[string]bool
+		if err := json.NewDecoder(client).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		assert.Equal(t, tt.expected, response)
+		client.Close()
+	}
+}
+
+// adminClient is a caller that may use the helper and apply fixes.
+var adminClient = helperClient{mayFix: true}
+
+func TestHandleConnection_FixRequiresAdmin(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+	fixable := &rootFixableCheck{}
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return fixable },
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go handleConnection(server, helperClient{cred: shared.PeerCred{UID: 1000}})
+
+	encoder, decoder := json.NewEncoder(client), json.NewDecoder(client)
+	if err := encoder.Encode(shared.HelperHello{Version: shared.HelperProtocolVersion}); err != nil {
+		t.Fatalf("failed to encode hello: %v", err)
+	}
+	var hello shared.HelperHello
+	if err := decoder.Decode(&hello); err != nil {
+		t.Fatalf("failed to decode hello: %v", err)
+	}
+	request := shared.HelperRequest{Action: shared.HelperActionFix, UUIDs: []string{"root-fixable-uuid"}}
+	if err := encoder.Encode(request); err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	var response shared.HelperResponse
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	assert.Contains(t, response.Results["root-fixable-uuid"].Error, "may not apply fixes")
+	assert.False(t, fixable.fixed)
+}
+
+// requestHelper sends req to a helper serving client over version 2 of the
+// protocol and returns the response.
+func requestHelper(t *testing.T, client helperClient, req shared.HelperRequest) shared.HelperResponse {
+	t.Helper()
+	server, conn := net.Pipe()
+	defer conn.Close()
+	go handleConnection(server, client)
+
+	encoder, decoder := json.NewEncoder(conn), json.NewDecoder(conn)
+	if err := encoder.Encode(shared.HelperHello{Version: shared.HelperProtocolVersion}); err != nil {
+		t.Fatalf("failed to encode hello: %v", err)
+	}
+	var hello shared.HelperHello
+	if err := decoder.Decode(&hello); err != nil {
+		t.Fatalf("failed to decode hello: %v", err)
+	}
+	if err := encoder.Encode(req); err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	var response shared.HelperResponse
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return response
+}
+
+func TestHandleConnection_PlanAndFix(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &rootFixableCheck{} },
+	})
+	uuids := []string{"root-fixable-uuid"}
+
+	res := requestHelper(t, adminClient, shared.HelperRequest{Action: shared.HelperActionPlan, UUIDs: uuids}).Results["root-fixable-uuid"]
+	assert.Empty(t, res.Error)
+	assert.Equal(t, check.OutcomeFail, res.Outcome)
+	assert.Equal(t, []string{"Fix the thing"}, res.Plan)
+
+	// Fixes are only applied as confirmed
+	res = requestHelper(t, adminClient, shared.HelperRequest{Action: shared.HelperActionFix, UUIDs: uuids}).Results["root-fixable-uuid"]
+	assert.Contains(t, res.Error, "not confirmed")
+	res = requestHelper(t, adminClient, shared.HelperRequest{Action: shared.HelperActionFix, UUIDs: uuids, Plan: []string{"Fix another thing"}}).Results["root-fixable-uuid"]
+	assert.Contains(t, res.Error, "changed since it was confirmed")
+	res = requestHelper(t, adminClient, shared.HelperRequest{Action: shared.HelperActionFix, UUIDs: uuids, Plan: []string{"Fix the thing"}}).Results["root-fixable-uuid"]
+	assert.Empty(t, res.Error)
+	assert.True(t, res.Passed())
+}
+
+// userCheck is a check that does not require root.
+type userCheck struct {
+	rootCheck
+}
+
+func (u *userCheck) UUID() string       { return "user-uuid" }
+func (u *userCheck) RequiresRoot() bool { return false }
+
+func TestHandleConnection_V2Batch(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &rootCheck{} },
+	})
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &userCheck{} },
+	})
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go handleConnection(server, adminClient)
+
+	encoder, decoder := json.NewEncoder(client), json.NewDecoder(client)
+	if err := encoder.Encode(shared.HelperHello{Version: 3}); err != nil {
+		t.Fatalf("failed to encode hello: %v", err)
+	}
+	var hello shared.HelperHello
+	if err := decoder.Decode(&hello); err != nil {
+		t.Fatalf("failed to decode hello: %v", err)
+	}
+	assert.Equal(t, shared.HelperProtocolVersion, hello.Version)
+
+	request := shared.HelperRequest{UUIDs: []string{"root-uuid", "user-uuid", "unknown-uuid"}}
+	if err := encoder.Encode(request); err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	var response shared.HelperResponse
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	assert.Len(t, response.Results, 3)
+	assert.Equal(t, check.OutcomePass, response.Results["root-uuid"].Outcome)
+	assert.Equal(t, "passed", response.Results["root-uuid"].Reason)
+	assert.Empty(t, response.Results["root-uuid"].Error)
+	assert.Equal(t, "check user-uuid does not require root", response.Results["user-uuid"].Error)
+	assert.Equal(t, "check unknown-uuid is not registered", response.Results["unknown-uuid"].Error)
+}
+
+func TestHandleConnection_SignsResults(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+	claims.Registry.MustRegister(check.Registration{
+		Claim: "Test",
+		New:   func() check.Check { return &rootCheck{} },
+	})
+
+	dir := t.TempDir()
+	shared.HelperKeyPath = filepath.Join(dir, "helper.key")
+	shared.HelperPublicKeyPath = filepath.Join(dir, "helper.pub")
+	var err error
+	if signingKey, err = shared.LoadHelperKey(); err != nil {
+		t.Fatalf("failed to load helper key: %v", err)
+	}
+	defer func() { signingKey = nil }()
+
+	for _, action := range []string{shared.HelperActionRun, shared.HelperActionFix} {
+		server, client := net.Pipe()
+		go handleConnection(server, adminClient)
+
+		encoder, decoder := json.NewEncoder(client), json.NewDecoder(client)
+		if err := encoder.Encode(shared.HelperHello{Version: shared.HelperProtocolVersion}); err != nil {
+			t.Fatalf("failed to encode hello: %v", err)
+		}
+		var hello shared.HelperHello
+		if err := decoder.Decode(&hello); err != nil {
+			t.Fatalf("failed to decode hello: %v", err)
+		}
+		request := shared.HelperRequest{Action: action, UUIDs: []string{"root-uuid", "unknown-uuid"}}
+		if err := encoder.Encode(request); err != nil {
+			t.Fatalf("failed to encode request: %v", err)
+		}
+		var response shared.HelperResponse
+		if err := decoder.Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		client.Close()
+
+		// Only results of checks that ran are signed, and fixes are not
+		if action == shared.HelperActionRun {
+			assert.NotEmpty(t, response.Results["root-uuid"].Signature)
+			assert.NotZero(t, response.Results["root-uuid"].SignedAt)
+		} else {
+			assert.Empty(t, response.Results["root-uuid"].Signature)
+		}
+		assert.Empty(t, response.Results["unknown-uuid"].Signature)
+	}
+}
+
+func TestHandleConnection_ProductUUID(t *testing.T) {
+	mocks := shared.ReadFileMocks
+	defer func() { shared.ReadFileMocks = mocks }()
+	shared.ReadFileMocks = map[string]string{
+		"/sys/devices/virtual/dmi/id/product_uuid": "4C4C4544-0042-3510-8052-B4C04F384D32\n",
+	}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	go handleConnection(server, adminClient)
+
+	encoder, decoder := json.NewEncoder(client), json.NewDecoder(client)
+	if err := encoder.Encode(shared.HelperHello{Version: shared.HelperProtocolVersion}); err != nil {
+		t.Fatalf("failed to encode hello: %v", err)
+	}
+	var hello shared.HelperHello
+	if err := decoder.Decode(&hello); err != nil {
+		t.Fatalf("failed to decode hello: %v", err)
+	}
+	if err := encoder.Encode(shared.HelperRequest{Action: shared.HelperActionProductUUID}); err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	var response shared.HelperResponse
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	assert.Equal(t, "4c4c4544-0042-3510-8052-b4c04f384d32", response.ProductUUID)
+	assert.Empty(t, response.Error)
+	assert.Empty(t, response.Results)
+}
+
+func TestRejectConnection(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go rejectConnection(server, errors.New("uid 33 is not a regular user"))
+
+	if err := json.NewEncoder(client).Encode(shared.HelperHello{Version: shared.HelperProtocolVersion}); err != nil {
+		t.Fatalf("failed to encode hello: %v", err)
+	}
+	var hello shared.HelperHello
+	if err := json.NewDecoder(client).Decode(&hello); err != nil {
+		t.Fatalf("failed to decode hello: %v", err)
+	}
+	assert.Equal(t, "not authorized: uid 33 is not a regular user", hello.Error)
+}
+
+func TestHandleConnection_UnknownFields(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go handleConnection(server, adminClient)
+
+	input := map[string]string{"uuid": "test-uuid", "command": "rm -rf /"}
+	if err := json.NewEncoder(client).Encode(input); err != nil {
+		t.Fatalf("failed to encode input: %v", err)
+	}
+	var response map[string]bool
+	assert.Error(t, json.NewDecoder(client).Decode(&response))
+}
+
+// countingCheck is a root check that counts its runs.
+type countingCheck struct {
+	rootCheck
+	runs *int
+}
+
+func (c *countingCheck) Run(ctx context.Context) error { *c.runs++; c.passed = true; return nil }
+
+func TestRunRootCheck_Cache(t *testing.T) {
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	cache := resultCache
+	resultCache = shared.NewResultCache("")
+	defer func() { claims.Registry = registry; resultCache = cache }()
+
+	runs := 0
+	claims.Registry.MustRegister(check.Registration{
+		Claim:    "Test",
+		CacheTTL: time.Minute,
+		New:      func() check.Check { return &countingCheck{runs: &runs} },
+	})
+
+	res := runRootCheck("root-uuid", shared.HelperRequest{})
+	assert.False(t, res.Cached)
+	assert.True(t, res.Passed())
+
+	res = runRootCheck("root-uuid", shared.HelperRequest{})
+	assert.True(t, res.Cached)
+	assert.True(t, res.Passed())
+	assert.Equal(t, 1, runs)
+
+	// Fresh requests bypass the cache
+	res = runRootCheck("root-uuid", shared.HelperRequest{Fresh: true})
+	assert.False(t, res.Cached)
+	assert.Equal(t, 2, runs)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	shared "github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history [--uuid <uuid>] [--since <duration|date>]",
+	Short: "Show the history of check results",
+	Long: `Show when checks started or stopped passing, how long they spent
+failing and which checks keep flapping between pass and fail.
+
+--since accepts a duration such as 12h or 7d, a date such as 2006-01-02
+or an RFC 3339 timestamp.`,
+	Run: func(cc *cobra.Command, args []string) {
+		uuid, _ := cc.Flags().GetString("uuid")
+		sinceFlag, _ := cc.Flags().GetString("since")
+
+		now := time.Now()
+		since, err := parseSince(sinceFlag, now)
+		if err != nil {
+			log.WithError(err).Fatal("Invalid --since")
+		}
+		entries, err := shared.ReadHistory(uuid, since)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to read check history")
+		}
+		printHistory(os.Stdout, entries, now)
+	},
+}
+
+// parseSince parses the --since flag relative to now. An empty value means
+// the whole history.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return now.Add(-duration), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration, a date nor a timestamp", value)
+}
+
+// printHistory prints the transitions found in entries followed by a
+// summary of each check.
+func printHistory(out io.Writer, entries []shared.HistoryEntry, now time.Time) {
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No check results recorded yet")
+		return
+	}
+
+	transitions := shared.HistoryTransitions(entries)
+	if len(transitions) == 0 {
+		fmt.Fprintf(out, "No transitions since %s\n", entries[0].Time.Format(time.RFC3339))
+	}
+	for _, transition := range transitions {
+		fmt.Fprintf(out, "%s %s: %s -> %s\n", transition.Time.Format(time.RFC3339), transition.Name, transition.From, transition.To)
+	}
+	fmt.Fprintln(out)
+
+	data := [][]string{}
+	for _, summary := range shared.SummarizeHistory(entries, now) {
+		failingSince := ""
+		if !summary.FailingSince.IsZero() {
+			failingSince = summary.FailingSince.Format(time.RFC3339)
+		}
+		flapping := ""
+		if summary.Flapping {
+			flapping = "yes"
+		}
+		data = append(data, []string{
+			summary.UUID,
+			summary.Name,
+			strconv.Itoa(summary.Runs),
+			strconv.Itoa(summary.Transitions),
+			summary.Failing.Round(time.Second).String(),
+			failingSince,
+			flapping,
+		})
+	}
+
+	printTable(out, []string{"UUID", "Name", "Runs", "Transitions", "Failing For", "Failing Since", "Flapping"}, data)
+}
+
+func init() {
+	historyCmd.Flags().String("uuid", "", "only show the history of the check with this UUID")
+	historyCmd.Flags().String("since", "", "only show results recorded since this duration, date or timestamp")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "12h", want: now.Add(-12 * time.Hour)},
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2024-05-01T08:00:00Z", want: time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
+		{value: "last week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSince(tt.value, now)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	var out bytes.Buffer
+	printHistory(&out, nil, time.Now())
+	assert.Contains(t, out.String(), "No check results recorded yet")
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	out.Reset()
+	printHistory(&out, []shared.HistoryEntry{
+		{Time: start, UUID: "uuid", Name: "Firewall", Outcome: check.OutcomePass},
+		{Time: start.Add(time.Hour), UUID: "uuid", Name: "Firewall", Outcome: check.OutcomeFail},
+	}, start.Add(2*time.Hour))
+
+	assert.Contains(t, out.String(), "2024-05-01T11:00:00Z Firewall: pass -> fail")
+	assert.Contains(t, out.String(), "1h0m0s")
+}
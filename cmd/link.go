@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	shared "github.com/ParetoSecurity/agent/shared"
 	"github.com/ParetoSecurity/agent/team"
@@ -32,13 +35,63 @@ ZaoGJg3y8zjrxYBQDC8gF6sCAwEAAQ==
 type InviteClaims struct {
 	TeamAuth string `json:"token"`
 	TeamUUID string `json:"teamID"`
+	// ReportURL and UpdatesURL point a device at a self-hosted dashboard,
+	// overriding the endpoints in pareto.toml. They are only honored in
+	// invites verified with the invite key of the endpoints.
+	ReportURL  string `json:"reportURL,omitempty"`
+	UpdatesURL string `json:"updatesURL,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// enrollTokenEnv is the environment variable headless enrollment reads the
+// invite token from.
+const enrollTokenEnv = "PARETOSECURITY_ENROLL_TOKEN"
+
+// Exit codes of headless enrollment, so that configuration management can
+// tell failures worth retrying from those that need a new invite.
+const (
+	exitEnrollFailed       = 1 // the team could not be reached or refused the device
+	exitEnrollNoToken      = 2 // no invite token was given
+	exitEnrollInvalidToken = 3 // the invite is malformed, expired or for another dashboard
+	exitEnrollOtherTeam    = 4 // the device is linked to another team
+)
+
+var (
+	errNoInviteToken   = errors.New("no invite token given")
+	errInvalidInvite   = errors.New("invalid invite")
+	errLinkedOtherTeam = errors.New("already linked to another team")
+)
+
 var linkCmd = &cobra.Command{
 	Use:   "link <url>",
 	Short: "Link team with this device",
+	Long: `Link team with this device.
+
+With --headless, the device is linked without user interaction, for use from
+configuration management. The invite token is taken from --token, the team
+URL, the PARETOSECURITY_ENROLL_TOKEN environment variable or the Token key of
+/etc/paretosecurity/enroll.toml, in this order. The result is printed as JSON.
+
+The device is linked in the config of the user running the command. When run
+as root, --user links it in the config of the given user instead, who owns
+the files written; without it, root links only its own config, which the
+agent running as a normal user does not read.
+The exit code is 0 when the device is linked, also if it already was, 1 when
+the team could not be reached, 2 without a token, 3 when the invite is invalid
+or expired and 4 when the device is linked to another team.`,
 	Run: func(cc *cobra.Command, args []string) {
+		if headless, _ := cc.Flags().GetBool("headless"); headless {
+			token, _ := cc.Flags().GetString("token")
+			if token == "" && len(args) > 0 {
+				token = args[0]
+			}
+			owner, _ := cc.Flags().GetString("user")
+			result, code := runHeadlessLink(token, owner)
+			if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+				log.WithError(err).Fatal("Failed to print the result")
+			}
+			os.Exit(code)
+		}
 		if shared.IsRoot() {
 			log.Fatal("Please run this command as a normal user.")
 		}
@@ -54,6 +107,112 @@ var linkCmd = &cobra.Command{
 	},
 }
 
+// enrollResult is the outcome of headless enrollment, as printed by
+// `link --headless`.
+type enrollResult struct {
+	// Status is linked, already-linked or failed.
+	Status   string `json:"status"`
+	TeamID   string `json:"teamID,omitempty"`
+	DeviceID string `json:"deviceID,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// runHeadlessLink links the device with the invite in token, a team URL or
+// the token itself, falling back to enrollTokenEnv and shared.EnrollPath. A
+// non-empty owner links it in the config of that user, which only root may
+// do. It returns the result and the exit code of the command.
+func runHeadlessLink(token, owner string) (enrollResult, int) {
+	invite, err := headlessInvite(token)
+	if err == nil && owner != "" {
+		err = useConfigOf(owner)
+	}
+	if err == nil {
+		err = linkInvite(invite)
+	}
+
+	result := enrollResult{Status: "linked"}
+	if invite != nil {
+		result.TeamID = invite.TeamUUID
+	}
+	code := 0
+	switch {
+	case err == nil:
+	case errors.Is(err, errAlreadyLinked):
+		result.Status = "already-linked"
+	default:
+		result.Status = "failed"
+		result.Error = err.Error()
+		code = exitEnrollFailed
+		switch {
+		case errors.Is(err, errNoInviteToken):
+			code = exitEnrollNoToken
+		case errors.Is(err, errInvalidInvite):
+			code = exitEnrollInvalidToken
+		case errors.Is(err, errLinkedOtherTeam):
+			code = exitEnrollOtherTeam
+		}
+	}
+	if code == 0 {
+		result.DeviceID, _ = shared.SystemUUID()
+	}
+	return result, code
+}
+
+// headlessInvite returns the validated invite of token, or of the first
+// token found in the environment or in shared.EnrollPath if token is empty.
+func headlessInvite(token string) (*InviteClaims, error) {
+	if token == "" {
+		token = os.Getenv(enrollTokenEnv)
+	}
+	if token == "" {
+		settings, err := shared.LoadEnrollSettings()
+		if err != nil {
+			return nil, err
+		}
+		if settings != nil {
+			token = settings.Token
+		}
+	}
+	if token == "" {
+		return nil, errNoInviteToken
+	}
+	if strings.Contains(token, "token=") {
+		fromURL, err := getTokenFromURL(token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidInvite, err)
+		}
+		token = fromURL
+	}
+
+	invite, err := parseJWT(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidInvite, err)
+	}
+	// Tokens for headless enrollment sit in files and environments for long,
+	// so they must expire and name the dashboard they are for
+	switch {
+	case invite.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: the invite does not expire", errInvalidInvite)
+	case len(invite.Audience) == 0:
+		return nil, fmt.Errorf("%w: the invite does not name its dashboard", errInvalidInvite)
+	}
+	if err := validateInvite(invite, time.Now()); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// useConfigOf switches to the config of the user with the given name.
+func useConfigOf(name string) error {
+	if !shared.IsRoot() {
+		return errors.New("only root can link the device for another user")
+	}
+	if err := shared.UseConfigOf(name); err != nil {
+		return fmt.Errorf("failed to use the config of %s: %w", name, err)
+	}
+	return nil
+}
+
 func runLinkCommand(teamURL string) error {
 	if lo.IsEmpty(teamURL) {
 		log.Warn("Please provide a team URL")
@@ -69,36 +228,119 @@ func runLinkCommand(teamURL string) error {
 		return errors.New("already linked to a team")
 	}
 
-	if lo.IsNotEmpty(teamURL) {
-		token, err := getTokenFromURL(teamURL)
-		if err != nil {
-			log.WithError(err).Warn("failed to get token from URL")
-			return err
-		}
+	token, err := getTokenFromURL(teamURL)
+	if err != nil {
+		log.WithError(err).Warn("failed to get token from URL")
+		return err
+	}
 
-		parsedToken, err := parseJWT(token)
-		if err != nil {
-			log.WithError(err).Warn("failed to parse JWT")
-			return err
+	parsedToken, err := parseJWT(token)
+	if err != nil {
+		log.WithError(err).Warn("failed to parse JWT")
+		return err
+	}
+	if err := validateInvite(parsedToken, time.Now()); err != nil {
+		log.WithError(err).Warn("invalid invite")
+		return err
+	}
+	return linkInvite(parsedToken)
+}
+
+// errAlreadyLinked is returned by linkInvite when the device is already
+// linked to the team of the invite.
+var errAlreadyLinked = errors.New("already linked to the team")
+
+// linkInvite links the device to the team of invite and saves the config.
+// Linking again to the same team changes nothing and returns
+// errAlreadyLinked.
+func linkInvite(invite *InviteClaims) error {
+	if shared.IsLinked() {
+		if shared.Config.TeamID == invite.TeamUUID {
+			log.Infof("Device is already linked to team: %s", invite.TeamUUID)
+			return errAlreadyLinked
 		}
+		return fmt.Errorf("%w: %s", errLinkedOtherTeam, shared.Config.TeamID)
+	}
 
-		shared.Config.TeamID = parsedToken.TeamUUID
-		shared.Config.AuthToken = parsedToken.TeamAuth
+	if err := applyInviteEndpoints(invite); err != nil {
+		log.WithError(err).Warn("invalid endpoint in the invite")
+		return fmt.Errorf("%w: %w", errInvalidInvite, err)
+	}
+	// The device ID is chosen before the device is linked, as linked
+	// devices without one keep the ID of their network interface
+	if _, err := shared.SystemUUID(); err != nil {
+		log.WithError(err).Warn("failed to choose the device ID")
+		return err
+	}
+	shared.Config.TeamID = invite.TeamUUID
+	shared.Config.AuthToken = invite.TeamAuth
 
-		err = team.ReportToTeam(true)
-		if err != nil {
-			log.WithError(err).Warn("failed to report to team")
-			return err
+	if err := team.ReportToTeam(true, ""); err != nil {
+		log.WithError(err).Warn("failed to report to team")
+		// The device is not linked unless the team knows it
+		shared.Config.TeamID = ""
+		shared.Config.AuthToken = ""
+		return err
+	}
+
+	if err := shared.SaveConfig(); err != nil {
+		log.Errorf("Error saving config: %v", err)
+		return err
+	}
+	log.Infof("Device successfully linked to team: %s", invite.TeamUUID)
+	return nil
+}
+
+// validateInvite returns an error wrapping errInvalidInvite if invite has
+// expired at now, or if it names an audience other than the dashboard the
+// device reports to. Invites without an expiry or audience are accepted, but
+// headlessInvite requires both.
+func validateInvite(invite *InviteClaims, now time.Time) error {
+	if invite.TeamUUID == "" || invite.TeamAuth == "" {
+		return fmt.Errorf("%w: the invite has no team", errInvalidInvite)
+	}
+	if invite.ExpiresAt != nil && !now.Before(invite.ExpiresAt.Time) {
+		return fmt.Errorf("%w: the invite expired at %s", errInvalidInvite, invite.ExpiresAt.Format(time.RFC3339))
+	}
+	if len(invite.Audience) == 0 {
+		return nil
+	}
+	dashboard := invite.ReportURL
+	if dashboard == "" {
+		dashboard = shared.Endpoints().ReportURL()
+	}
+	for _, audience := range invite.Audience {
+		if strings.TrimSuffix(audience, "/") == strings.TrimSuffix(dashboard, "/") {
+			return nil
 		}
+	}
+	return fmt.Errorf("%w: the invite is for %s, not %s", errInvalidInvite, strings.Join(invite.Audience, ", "), dashboard)
+}
 
-		err = shared.SaveConfig()
-		if err != nil {
-			log.Errorf("Error saving config: %v", err)
+// applyInviteEndpoints stores the endpoints set by the invite in the config,
+// so that the device is linked to and reports to the dashboard that issued
+// it. Only https endpoints are accepted, as they are sent the team token.
+func applyInviteEndpoints(invite *InviteClaims) error {
+	if invite.ReportURL == "" && invite.UpdatesURL == "" {
+		return nil
+	}
+	endpoints := shared.Endpoints()
+	for _, endpoint := range []struct {
+		value  string
+		target *string
+	}{
+		{invite.ReportURL, &endpoints.Report},
+		{invite.UpdatesURL, &endpoints.Updates},
+	} {
+		if endpoint.value == "" {
+			continue
+		}
+		if err := shared.ValidateSecureEndpoint(endpoint.value); err != nil {
 			return err
 		}
-		log.Infof("Device successfully linked to team: %s", parsedToken.TeamUUID)
-
+		*endpoint.target = endpoint.value
 	}
+	shared.Config.Endpoints = &endpoints
 	return nil
 }
 
@@ -118,16 +360,40 @@ func getTokenFromURL(teamURL string) (string, error) {
 	return token, nil
 }
 
+// parseJWT returns the claims of the invite token. When the endpoints name
+// an invite key, such as that of a self-hosted dashboard, only tokens signed
+// with it are accepted. Otherwise the signature is not verified, so the
+// endpoints the invite sets are ignored.
 func parseJWT(token string) (*InviteClaims, error) {
+	if endpoints := shared.Endpoints(); endpoints.InviteKey != "" {
+		key, err := endpoints.InvitePublicKey()
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := jwt.ParseWithClaims(token, &InviteClaims{}, func(*jwt.Token) (interface{}, error) {
+			return key, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify the invite: %w", err)
+		}
+		return parsed.Claims.(*InviteClaims), nil
+	}
 	jwttToken, _ := jwt.ParseWithClaims(token, &InviteClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(strings.ReplaceAll(rsaPublicKey, "\n", "")), nil
 	})
 	if claims, ok := jwttToken.Claims.(*InviteClaims); ok {
+		if claims.ReportURL != "" || claims.UpdatesURL != "" {
+			log.Warn("Ignoring the endpoints of the invite, as it cannot be verified without an invite key")
+			claims.ReportURL, claims.UpdatesURL = "", ""
+		}
 		return claims, nil
 	}
 	return nil, fmt.Errorf("failed to parse JWT")
 }
 
 func init() {
+	linkCmd.Flags().Bool("headless", false, "link without user interaction and print the result as JSON")
+	linkCmd.Flags().String("token", "", "invite token or team URL to link with in headless mode")
+	linkCmd.Flags().String("user", "", "link in the config of this user in headless mode, as root")
 	rootCmd.AddCommand(linkCmd)
 }
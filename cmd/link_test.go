@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/ParetoSecurity/agent/shared"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/assert"
 )
@@ -25,6 +33,48 @@ func TestParseJWT(t *testing.T) {
 	})
 }
 
+// useInviteKey points the endpoints at a new invite key and returns the key
+// that signs the invites.
+func useInviteKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(public)
+	assert.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "invite.pem")
+	assert.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	shared.Config.Endpoints = &shared.EndpointSettings{InviteKey: keyPath}
+	t.Cleanup(func() { shared.Config.Endpoints = nil })
+	return private
+}
+
+func TestParseJWT_InviteKey(t *testing.T) {
+	private := useInviteKey(t)
+
+	claims := InviteClaims{TeamUUID: "team", TeamAuth: "auth"}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(private)
+	assert.NoError(t, err)
+	invite, err := parseJWT(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "team", invite.TeamUUID)
+
+	// Invites signed with any other key are refused
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(other)
+	assert.NoError(t, err)
+	_, err = parseJWT(forged)
+	assert.Error(t, err)
+	forged, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = parseJWT(forged)
+	assert.Error(t, err)
+
+	shared.Config.Endpoints.InviteKey = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = parseJWT(signed)
+	assert.Error(t, err)
+}
+
 func TestRunLinkCommand_Success(t *testing.T) {
 	defer gock.Off()
 
@@ -33,12 +83,14 @@ func TestRunLinkCommand_Success(t *testing.T) {
 		JSON([]map[string]string{{"status": "ok"}})
 
 	// Reset config
+	shared.DeviceKeyPath = filepath.Join(t.TempDir(), "device.key")
 	shared.Config.TeamID = ""
 	shared.Config.AuthToken = ""
 
 	defer func() {
 		shared.Config.TeamID = ""
 		shared.Config.AuthToken = ""
+		shared.Config.DeviceID = ""
 		shared.SaveConfig()
 	}()
 
@@ -57,3 +109,153 @@ func TestRunLinkCommand_Success(t *testing.T) {
 	assert.Equal(t, expectedTeamUUID, shared.Config.TeamID)
 	assert.Equal(t, expectedTeamAuth, shared.Config.AuthToken)
 }
+
+func TestApplyInviteEndpoints(t *testing.T) {
+	private := useInviteKey(t)
+	shared.Config.Endpoints.CABundle = "/etc/ssl/dash.pem"
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, InviteClaims{
+		TeamUUID:  "team",
+		ReportURL: "https://dash.internal",
+	}).SignedString(private)
+	assert.NoError(t, err)
+	invite, err := parseJWT(token)
+	assert.NoError(t, err)
+
+	assert.NoError(t, applyInviteEndpoints(invite))
+	assert.Equal(t, "https://dash.internal", shared.Endpoints().ReportURL())
+	assert.Equal(t, shared.DefaultUpdatesURL, shared.Endpoints().UpdatesURL())
+	assert.Equal(t, "/etc/ssl/dash.pem", shared.Endpoints().CABundle, "other settings are kept")
+
+	assert.Error(t, applyInviteEndpoints(&InviteClaims{UpdatesURL: "dash.internal/updates"}))
+	assert.Error(t, applyInviteEndpoints(&InviteClaims{ReportURL: "http://dash.internal"}))
+	assert.Equal(t, "https://dash.internal", shared.Endpoints().ReportURL())
+	assert.Equal(t, shared.DefaultUpdatesURL, shared.Endpoints().UpdatesURL())
+}
+
+func TestParseJWT_UnverifiedEndpoints(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, InviteClaims{
+		TeamUUID:   "team",
+		TeamAuth:   "auth",
+		ReportURL:  "https://attacker.example",
+		UpdatesURL: "https://attacker.example/updates",
+	}).SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	// Without an invite key the invite cannot be verified, so its endpoints
+	// are ignored
+	invite, err := parseJWT(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "team", invite.TeamUUID)
+	assert.Empty(t, invite.ReportURL)
+	assert.Empty(t, invite.UpdatesURL)
+}
+
+func TestValidateInvite(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	invite := func(expiresAt time.Time, audience ...string) *InviteClaims {
+		claims := &InviteClaims{TeamUUID: "team", TeamAuth: "auth"}
+		if !expiresAt.IsZero() {
+			claims.ExpiresAt = jwt.NewNumericDate(expiresAt)
+		}
+		claims.Audience = audience
+		return claims
+	}
+
+	assert.NoError(t, validateInvite(invite(time.Time{}), now))
+	assert.NoError(t, validateInvite(invite(now.Add(time.Hour)), now))
+	assert.NoError(t, validateInvite(invite(time.Time{}, shared.DefaultReportURL+"/"), now))
+	assert.ErrorIs(t, validateInvite(invite(now), now), errInvalidInvite)
+	assert.ErrorIs(t, validateInvite(invite(time.Time{}, "https://dash.internal"), now), errInvalidInvite)
+	assert.ErrorIs(t, validateInvite(&InviteClaims{}, now), errInvalidInvite)
+
+	// Invites for a self-hosted dashboard name it as their audience
+	selfHosted := invite(time.Time{}, "https://dash.internal")
+	selfHosted.ReportURL = "https://dash.internal"
+	assert.NoError(t, validateInvite(selfHosted, now))
+}
+
+func TestRunHeadlessLink(t *testing.T) {
+	defer gock.Off()
+	shared.DeviceKeyPath = filepath.Join(t.TempDir(), "device.key")
+	enrollPath := shared.EnrollPath
+	shared.EnrollPath = filepath.Join(t.TempDir(), "enroll.toml")
+	defer func() {
+		shared.EnrollPath = enrollPath
+		shared.Config.TeamID = ""
+		shared.Config.AuthToken = ""
+		shared.Config.DeviceID = ""
+		shared.SaveConfig()
+	}()
+	shared.Config.TeamID = ""
+	shared.Config.AuthToken = ""
+
+	newToken := func(team string, expiresAt time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, InviteClaims{
+			TeamUUID: team,
+			TeamAuth: "auth-" + team,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				Audience:  jwt.ClaimStrings{shared.DefaultReportURL},
+			},
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		return token
+	}
+	valid := newToken("team", time.Now().Add(time.Hour))
+
+	result, code := runHeadlessLink("", "")
+	assert.Equal(t, exitEnrollNoToken, code)
+	assert.Equal(t, "failed", result.Status)
+
+	_, code = runHeadlessLink(newToken("team", time.Now().Add(-time.Hour)), "")
+	assert.Equal(t, exitEnrollInvalidToken, code)
+
+	// Headless invites must expire and name their dashboard
+	for _, claims := range []jwt.RegisteredClaims{
+		{Audience: jwt.ClaimStrings{shared.DefaultReportURL}},
+		{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	} {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, InviteClaims{
+			TeamUUID:         "team",
+			TeamAuth:         "auth-team",
+			RegisteredClaims: claims,
+		}).SignedString([]byte("secret"))
+		assert.NoError(t, err)
+		_, code = runHeadlessLink(token, "")
+		assert.Equal(t, exitEnrollInvalidToken, code)
+	}
+
+	gock.New(shared.DefaultReportURL).
+		Put("/api/v1/team/team/device").
+		Reply(500)
+	t.Setenv(enrollTokenEnv, valid)
+	result, code = runHeadlessLink("", "")
+	assert.Equal(t, exitEnrollFailed, code)
+	assert.False(t, shared.IsLinked())
+
+	gock.New(shared.DefaultReportURL).
+		Put("/api/v1/team/team/device").
+		Reply(200)
+	result, code = runHeadlessLink("", "")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, enrollResult{Status: "linked", TeamID: "team", DeviceID: shared.Config.DeviceID}, result)
+	assert.Equal(t, "auth-team", shared.Config.AuthToken)
+	assert.True(t, gock.IsDone())
+
+	// Linking again to the same team does not contact it
+	result, code = runHeadlessLink("paretosecurity://linkDevice/?invite_id=1&token="+valid, "")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "already-linked", result.Status)
+
+	// Linking for a user that does not exist changes nothing
+	result, code = runHeadlessLink(valid, "no-such-user-of-pareto")
+	assert.Equal(t, exitEnrollFailed, code)
+	assert.Contains(t, result.Error, "no-such-user-of-pareto")
+	assert.Equal(t, "team", shared.Config.TeamID)
+
+	result, code = runHeadlessLink(newToken("other", time.Now().Add(time.Hour)), "")
+	assert.Equal(t, exitEnrollOtherTeam, code)
+	assert.Equal(t, "other", result.TeamID)
+	assert.Equal(t, "team", shared.Config.TeamID)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/caarlos0/log"
+	"github.com/godbus/dbus/v5"
+)
+
+// nmStateConnectedGlobal is the NetworkManager state of a device with full
+// network access.
+const nmStateConnectedGlobal = 70
+
+// networkUp returns a channel that receives a value whenever NetworkManager
+// reports full network access, until ctx is done. The channel never
+// receives if NetworkManager cannot be reached.
+func networkUp(ctx context.Context) <-chan struct{} {
+	up := make(chan struct{}, 1)
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		log.WithError(err).Debug("Cannot watch the network state")
+		return up
+	}
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath("/org/freedesktop/NetworkManager"),
+		dbus.WithMatchInterface("org.freedesktop.NetworkManager"),
+		dbus.WithMatchMember("StateChanged"),
+	); err != nil {
+		log.WithError(err).Debug("Cannot watch the network state")
+		conn.Close()
+		return up
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case signal := <-signals:
+				if len(signal.Body) == 0 {
+					continue
+				}
+				if state, _ := signal.Body[0].(uint32); state == nmStateConnectedGlobal {
+					select {
+					case up <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return up
+}
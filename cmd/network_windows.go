@@ -0,0 +1,9 @@
+package cmd
+
+import "context"
+
+// networkUp returns a channel that never receives, as network changes are
+// not watched on Windows; queued reports are retried periodically instead.
+func networkUp(ctx context.Context) <-chan struct{} {
+	return nil
+}
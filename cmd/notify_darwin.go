@@ -1,5 +1,7 @@
 package cmd
 
+import "context"
+
 func Notify(message string) {
 
 }
@@ -7,3 +9,7 @@ func Notify(message string) {
 func NotifyBlocking(message string) {
 
 }
+
+func NotifyWithActions(ctx context.Context, message string, actions []string) (string, error) {
+	return "", nil
+}
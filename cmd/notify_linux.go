@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/godbus/dbus/v5"
 )
 
@@ -80,3 +82,68 @@ func NotifyBlocking(message string) (string, error) {
 
 	return "", nil
 }
+
+// NotifyWithActions shows message with a button for each action, given as
+// pairs of action ID and label, and returns the ID of the action the user
+// picked. It returns an empty ID if the notification was closed or ctx is
+// done first. Without actions, it returns as soon as the notification is
+// shown.
+func NotifyWithActions(ctx context.Context, message string, actions []string) (string, error) {
+	// A private connection, so that concurrent notifications do not share
+	// their signals
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath("/org/freedesktop/Notifications"),
+		dbus.WithMatchInterface("org.freedesktop.Notifications"),
+	); err != nil {
+		return "", err
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	obj := conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := obj.CallWithContext(ctx, "org.freedesktop.Notifications.Notify", 0,
+		"ParetoSecurity",  // Application name
+		uint32(0),         // Replace ID
+		"dialog-warning",  // Icon
+		"Pareto Security", // Summary
+		message,           // Body
+		actions,           // Actions
+		map[string]interface{}{
+			"urgency": byte(1), // Normal urgency
+		},
+		int32(-1)) // Timeout (-1 means the server default)
+	if call.Err != nil {
+		return "", call.Err
+	}
+	var notificationId uint32
+	if err := call.Store(&notificationId); err != nil || len(actions) == 0 {
+		return "", err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", nil
+		case signal := <-signals:
+			if len(signal.Body) < 2 {
+				continue
+			}
+			if id, _ := signal.Body[0].(uint32); id != notificationId {
+				continue
+			}
+			switch signal.Name {
+			case "org.freedesktop.Notifications.ActionInvoked":
+				action, _ := signal.Body[1].(string)
+				return action, nil
+			case "org.freedesktop.Notifications.NotificationClosed":
+				return "", nil
+			}
+		}
+	}
+}
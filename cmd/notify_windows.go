@@ -1,8 +1,14 @@
 package cmd
 
+import "context"
+
 func Notify(message string) {
 
 }
 func NotifyBlocking(message string) {
 
 }
+
+func NotifyWithActions(ctx context.Context, message string, actions []string) (string, error) {
+	return "", nil
+}
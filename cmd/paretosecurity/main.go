@@ -2,6 +2,7 @@
 package main
 
 import (
+	"github.com/ParetoSecurity/agent/claims"
 	"github.com/ParetoSecurity/agent/cmd"
 	shared "github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
@@ -13,5 +14,6 @@ func main() {
 			log.WithError(err).Warn("failed to load config")
 		}
 	}
+	claims.LoadCustomChecks()
 	cmd.Execute()
 }
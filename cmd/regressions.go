@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/checks/custom"
+	"github.com/ParetoSecurity/agent/checks/plugin"
+	"github.com/ParetoSecurity/agent/claims"
+	shared "github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
+	"github.com/pkg/browser"
+	"github.com/samber/lo"
+)
+
+const (
+	// maxRegressionNotifications is how many regressions are notified about
+	// one by one, the rest are summed up in a single notification.
+	maxRegressionNotifications = 3
+	// regressionActionWait is how long a run waits for the user to pick an
+	// action on its notifications.
+	regressionActionWait = 2 * time.Minute
+
+	actionDetails = "details"
+	actionSnooze  = "snooze"
+)
+
+// notifyWithActions shows a notification, replaced in tests.
+var notifyWithActions = NotifyWithActions
+
+// notify shows a notification without actions, replaced in tests.
+var notify = func(message string) error {
+	_, err := notifyWithActions(context.Background(), message, nil)
+	return err
+}
+
+// openURL opens a URL in the browser, replaced in tests.
+var openURL = browser.OpenURL
+
+// isExternalCheck returns whether chk is a plugin or a custom check instead
+// of a check built into the agent.
+func isExternalCheck(chk check.Check) bool {
+	switch chk.(type) {
+	case *plugin.Plugin, *custom.Check:
+		return true
+	}
+	return false
+}
+
+// checkURL returns the page on the website that explains the check with the
+// given UUID and how to fix it.
+func checkURL(uuid, details string) string {
+	arch := "check-linux"
+	if runtime.GOOS == "windows" {
+		arch = "check-windows"
+	}
+	return fmt.Sprintf("https://paretosecurity.com/%s/%s?details=%s", arch, uuid, url.QueryEscape(details))
+}
+
+// regressions returns the checks that passed in previous and fail in
+// current, along with the regressions held back by quiet hours that still
+// fail.
+func regressions(previous, current map[string]shared.LastState, pending map[string]time.Time) []shared.StateChange {
+	var found []shared.StateChange
+	for _, change := range shared.DiffStates(previous, current) {
+		if change.Kind == shared.ChangeNewFailure && change.From == check.OutcomePass {
+			found = append(found, change)
+		}
+	}
+	for uuid := range pending {
+		state, ok := current[uuid]
+		if !ok || !state.Result().Failed() || lo.ContainsBy(found, func(change shared.StateChange) bool { return change.UUID == uuid }) {
+			continue
+		}
+		found = append(found, shared.StateChange{
+			Kind:      shared.ChangeNewFailure,
+			UUID:      uuid,
+			Name:      state.Name,
+			From:      check.OutcomePass,
+			To:        state.Result().Outcome,
+			ToDetails: state.Details,
+		})
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+	return found
+}
+
+// notifyRegressions sends a notification about each check that passed in
+// previous and fails in current, honoring the rate limit, snoozes and quiet
+// hours set in pareto.toml. It waits for the user to pick the Details or
+// Snooze action until ctx is done.
+func notifyRegressions(ctx context.Context, previous, current map[string]shared.LastState, now time.Time) {
+	settings := shared.Notifications()
+	if settings.Disabled {
+		return
+	}
+	notifications := shared.LoadNotificationLog()
+	defer func() {
+		if err := notifications.Save(now); err != nil {
+			log.WithError(err).Warn("failed to save notification log")
+		}
+	}()
+
+	found := regressions(previous, current, notifications.Pending)
+	if settings.IsQuiet(now) {
+		for _, change := range found {
+			if _, held := notifications.Pending[change.UUID]; !held {
+				notifications.Pending[change.UUID] = now
+			}
+		}
+		if len(found) > 0 {
+			log.WithField("count", len(found)).Info("Holding back notifications during quiet hours")
+		}
+		return
+	}
+	notifications.Pending = map[string]time.Time{}
+
+	interval := settings.IntervalDuration()
+	var due []shared.StateChange
+	for _, change := range found {
+		if notifications.Allows(change.UUID, now, interval) {
+			due = append(due, change)
+			notifications.Sent[change.UUID] = now
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	// Each notification waits for its action on its own
+	type notification struct {
+		message string
+		actions []string
+	}
+	var queue []notification
+	shown := due
+	if len(due) > maxRegressionNotifications {
+		shown = due[:maxRegressionNotifications-1]
+		queue = append(queue, notification{
+			message: fmt.Sprintf("%d more checks started failing, run `paretosecurity status --failed` to see them.", len(due)-len(shown)),
+		})
+	}
+	for _, change := range shown {
+		queue = append(queue, notification{
+			message: fmt.Sprintf("%s started failing: %s", change.Name, change.ToDetails),
+			actions: []string{actionDetails, "Details", actionSnooze, "Snooze"},
+		})
+	}
+
+	picked := make([]string, len(queue))
+	var wg sync.WaitGroup
+	for i, n := range queue {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			action, err := notifyWithActions(ctx, n.message, n.actions)
+			if err != nil {
+				log.WithError(err).Warn("failed to send notification")
+			}
+			picked[i] = action
+		}()
+	}
+	wg.Wait()
+	actions := picked[len(queue)-len(shown):]
+
+	for i, change := range shown {
+		switch actions[i] {
+		case actionDetails:
+			showRegressionDetails(change)
+		case actionSnooze:
+			snooze := settings.SnoozeDuration()
+			log.WithField("check", change.Name).WithField("for", snooze).Info("Snoozing notifications")
+			notifications.Snoozed[change.UUID] = now.Add(snooze)
+		}
+	}
+}
+
+// showRegressionDetails opens the remediation of a check that started
+// failing. Plugins and custom checks have no page on the website, so their
+// remediation is shown in a notification instead.
+func showRegressionDetails(change shared.StateChange) {
+	if chk := claims.Find(change.UUID); chk != nil && isExternalCheck(chk) {
+		message := fmt.Sprintf("%s: %s", change.Name, change.ToDetails)
+		if remediation := check.MetadataOf(chk).RemediationFor(shared.DistroFamily()); remediation != "" {
+			message += "\n" + remediation
+		}
+		if err := notify(message); err != nil {
+			log.WithError(err).Warn("failed to send notification")
+		}
+		return
+	}
+	if err := openURL(checkURL(change.UUID, change.ToDetails)); err != nil {
+		log.WithError(err).Error("failed to open check URL")
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNotifier records notifications and answers them with the action
+// configured for their message.
+type fakeNotifier struct {
+	mu       sync.Mutex
+	messages []string
+	answer   func(message string) string
+}
+
+func (f *fakeNotifier) notify(ctx context.Context, message string, actions []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, message)
+	if f.answer == nil || len(actions) == 0 {
+		return "", nil
+	}
+	return f.answer(message), nil
+}
+
+func stubNotifier(t *testing.T, f *fakeNotifier) *[]string {
+	statePath := shared.StatePath
+	shared.StatePath = filepath.Join(t.TempDir(), "state")
+	original, originalOpen := notifyWithActions, openURL
+	notifyWithActions = f.notify
+	var opened []string
+	openURL = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+	t.Cleanup(func() {
+		shared.StatePath = statePath
+		notifyWithActions, openURL = original, originalOpen
+		shared.Config.Notifications = nil
+	})
+	return &opened
+}
+
+func TestNotifyRegressions(t *testing.T) {
+	f := &fakeNotifier{answer: func(message string) string {
+		if message == "Firewall started failing: Firewall is off" {
+			return actionSnooze
+		}
+		return actionDetails
+	}}
+	opened := stubNotifier(t, f)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+
+	previous := map[string]shared.LastState{
+		"fw":  {UUID: "fw", Name: "Firewall", Outcome: check.OutcomePass, State: true},
+		"ssh": {UUID: "ssh", Name: "SSH", Outcome: check.OutcomePass, State: true},
+		"old": {UUID: "old", Name: "Old", Outcome: check.OutcomeFail},
+	}
+	current := map[string]shared.LastState{
+		"fw":  {UUID: "fw", Name: "Firewall", Outcome: check.OutcomeFail, Details: "Firewall is off"},
+		"ssh": {UUID: "ssh", Name: "SSH", Outcome: check.OutcomeFail, Details: "Root login is enabled"},
+		"old": {UUID: "old", Name: "Old", Outcome: check.OutcomeFail},
+	}
+	notifyRegressions(context.Background(), previous, current, now)
+
+	assert.ElementsMatch(t, []string{"Firewall started failing: Firewall is off", "SSH started failing: Root login is enabled"}, f.messages)
+	assert.Equal(t, []string{checkURL("ssh", "Root login is enabled")}, *opened)
+	notifications := shared.LoadNotificationLog()
+	assert.Equal(t, now.Add(shared.DefaultSnooze), notifications.Snoozed["fw"].Local())
+
+	// The same regressions are not notified about again within the interval
+	f.messages = nil
+	notifyRegressions(context.Background(), previous, current, now.Add(time.Hour))
+	assert.Empty(t, f.messages)
+}
+
+func TestNotifyRegressionsQuietHours(t *testing.T) {
+	f := &fakeNotifier{}
+	stubNotifier(t, f)
+	shared.Config.Notifications = &shared.NotificationSettings{QuietHours: "22:00-07:00"}
+	night := time.Date(2024, 5, 1, 23, 0, 0, 0, time.Local)
+
+	previous := map[string]shared.LastState{"fw": {UUID: "fw", Name: "Firewall", Outcome: check.OutcomePass, State: true}}
+	current := map[string]shared.LastState{"fw": {UUID: "fw", Name: "Firewall", Outcome: check.OutcomeFail}}
+	notifyRegressions(context.Background(), previous, current, night)
+	assert.Empty(t, f.messages)
+
+	// The held back regression is sent after the quiet hours, when the
+	// previous run already failed
+	notifyRegressions(context.Background(), current, current, night.Add(9*time.Hour))
+	assert.Equal(t, []string{"Firewall started failing: "}, f.messages)
+}
+
+func TestNotifyRegressionsSummarizes(t *testing.T) {
+	f := &fakeNotifier{}
+	stubNotifier(t, f)
+	previous := map[string]shared.LastState{}
+	current := map[string]shared.LastState{}
+	for _, uuid := range []string{"a", "b", "c", "d", "e"} {
+		previous[uuid] = shared.LastState{UUID: uuid, Name: uuid, Outcome: check.OutcomePass, State: true}
+		current[uuid] = shared.LastState{UUID: uuid, Name: uuid, Outcome: check.OutcomeFail}
+	}
+	notifyRegressions(context.Background(), previous, current, time.Now())
+
+	assert.Len(t, f.messages, maxRegressionNotifications)
+	assert.Contains(t, f.messages, "3 more checks started failing, run `paretosecurity status --failed` to see them.")
+}
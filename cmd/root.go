@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"sync"
+
+	"github.com/ParetoSecurity/agent/claims"
 	shared "github.com/ParetoSecurity/agent/shared"
 	"github.com/caarlos0/log"
 	"github.com/spf13/cobra"
@@ -8,6 +11,14 @@ import (
 
 var verbose bool
 
+// needsChecks annotates the commands that build claims, which have the check
+// plugins loaded before they run.
+var needsChecks = map[string]string{"checks": "true"}
+
+// loadPlugins registers the check plugins once. Describing a plugin runs it,
+// so this is left to the commands that need the checks.
+var loadPlugins = sync.OnceFunc(claims.LoadPlugins)
+
 var rootCmd = &cobra.Command{
 	Use:     "paretosecurity --help --version [command]",
 	Short:   "Pareto Security CLI",
@@ -18,6 +29,9 @@ to paretosecurity.com.`,
 		if verbose {
 			log.SetLevel(log.DebugLevel)
 		}
+		if cmd.Annotations["checks"] != "" {
+			loadPlugins()
+		}
 	},
 }
 
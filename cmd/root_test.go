@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginsLoadedOnlyForChecks(t *testing.T) {
+	load := loadPlugins
+	defer func() { loadPlugins = load }()
+	loaded := 0
+	loadPlugins = func() { loaded++ }
+
+	rootCmd.PersistentPreRun(linkCmd, nil)
+	rootCmd.PersistentPreRun(helperCmd, nil)
+	assert.Zero(t, loaded)
+
+	rootCmd.PersistentPreRun(checkCmd, nil)
+	rootCmd.PersistentPreRun(statusCmd, nil)
+	assert.Equal(t, 2, loaded)
+}
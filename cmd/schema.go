@@ -7,14 +7,21 @@ import (
 )
 
 var schemaCmd = &cobra.Command{
-	Use:   "schema",
-	Short: "Output schema for all checks",
-	Long:  "Output schema for all checks in JSON format.",
+	Use:         "schema",
+	Short:       "Output schema for all checks",
+	Long:        "Output schema for all checks in JSON format.",
+	Annotations: needsChecks,
 	Run: func(cc *cobra.Command, args []string) {
-		runner.PrintSchemaJSON(claims.All)
+		withMetadata, _ := cc.Flags().GetBool("metadata")
+		if withMetadata {
+			runner.PrintSchemaMetadataJSON(claims.All())
+			return
+		}
+		runner.PrintSchemaJSON(claims.All())
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.Flags().Bool("metadata", false, "include severity, category and remediation of each check")
 }
@@ -1,21 +1,252 @@
 package cmd
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/claims"
 	"github.com/ParetoSecurity/agent/shared"
+	"github.com/caarlos0/log"
 	"github.com/spf13/cobra"
 )
 
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [--format json|table|csv] [--failed] [--claim <title>] [--uuid <uuid>]",
 	Short: "Print the status of the checks",
-	Run: func(cmd *cobra.Command, args []string) {
-		shared.PrintStates()
+	Long: `Print the status of the checks as recorded by the last run, grouped by
+claim. The JSON output includes the number of passing, failing and disabled
+checks, for use in shell prompts and status bars.`,
+	Annotations: needsChecks,
+	Run: func(cc *cobra.Command, args []string) {
+		format, _ := cc.Flags().GetString("format")
+		failed, _ := cc.Flags().GetBool("failed")
+		claim, _ := cc.Flags().GetString("claim")
+		uuid, _ := cc.Flags().GetString("uuid")
+
+		states := shared.GetLastStates()
+		if err := shared.StateError(); err != nil {
+			log.WithError(err).Warn("The state file was corrupt and has been reset")
+		}
+		rows := statusRows(claims.All(), states, statusFilter{failed: failed, claim: claim, uuid: uuid}, time.Now())
+		if err := printStatus(os.Stdout, format, rows, len(states), shared.GetModifiedTime()); err != nil {
+			log.WithError(err).Fatal("Failed to print the status")
+		}
 		os.Exit(0)
 	},
 }
 
+// statusFilter selects the rows printed by the status command.
+type statusFilter struct {
+	failed bool
+	claim  string
+	uuid   string
+}
+
+// statusRow is the status of one check.
+type statusRow struct {
+	Claim     string        `json:"claim"`
+	UUID      string        `json:"uuid"`
+	Name      string        `json:"name"`
+	State     string        `json:"state"`
+	Outcome   check.Outcome `json:"outcome"`
+	Details   string        `json:"details"`
+	CheckedAt *time.Time    `json:"checkedAt,omitempty"`
+	// Tampered is why the result cannot be trusted, see shared.LastState.
+	Tampered string `json:"tampered,omitempty"`
+	// Age is the time since the check was run, zero if unknown.
+	Age        time.Duration `json:"-"`
+	AgeSeconds int64         `json:"ageSeconds,omitempty"`
+}
+
+// statusOther is the claim of checks that are not part of any claim, such
+// as checks that were removed since they were run.
+const statusOther = "Other"
+
+// statusRows returns the rows of the states matching filter, grouped by
+// claim in the order of all and then sorted by name.
+func statusRows(all []claims.Claim, states map[string]shared.LastState, filter statusFilter, now time.Time) []statusRow {
+	claimOf := map[string]string{}
+	claimOrder := map[string]int{}
+	for i, claim := range all {
+		claimOrder[claim.Title] = i
+		for _, chk := range claim.Checks {
+			if _, found := claimOf[chk.UUID()]; !found {
+				claimOf[chk.UUID()] = claim.Title
+			}
+		}
+	}
+	claimOrder[statusOther] = len(all)
+
+	var rows []statusRow
+	for uuid, state := range states {
+		claim, found := claimOf[uuid]
+		if !found {
+			claim = statusOther
+		}
+		res := state.Result()
+		switch {
+		case filter.failed && !res.Failed():
+			continue
+		case filter.claim != "" && !strings.EqualFold(filter.claim, claim):
+			continue
+		case filter.uuid != "" && filter.uuid != uuid:
+			continue
+		}
+
+		row := statusRow{
+			Claim:    claim,
+			UUID:     uuid,
+			Name:     state.Name,
+			State:    stateLabel(res),
+			Outcome:  res.Outcome,
+			Details:  state.Details,
+			Tampered: state.Tampered,
+		}
+		if state.Tampered != "" {
+			row.State = "tampered"
+		}
+		if !state.CheckedAt.IsZero() {
+			checkedAt := state.CheckedAt
+			row.CheckedAt = &checkedAt
+			row.Age = max(now.Sub(checkedAt), 0)
+			row.AgeSeconds = int64(row.Age.Seconds())
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Claim != rows[j].Claim {
+			return claimOrder[rows[i].Claim] < claimOrder[rows[j].Claim]
+		}
+		if rows[i].Name != rows[j].Name {
+			return rows[i].Name < rows[j].Name
+		}
+		return rows[i].UUID < rows[j].UUID
+	})
+	return rows
+}
+
+// stateLabel returns the state of res as shown by the status command.
+func stateLabel(res check.Result) string {
+	switch {
+	case res.Passed():
+		return "pass"
+	case res.Outcome == check.OutcomeError:
+		return "error"
+	case res.Failed():
+		return "fail"
+	}
+	return "off"
+}
+
+// statusAge formats the age of a row for the table and CSV output.
+func statusAge(row statusRow) string {
+	if row.CheckedAt == nil {
+		return "unknown"
+	}
+	return row.Age.Round(time.Second).String()
+}
+
+// printStatus prints rows in format, which is table, json or csv. The table
+// starts with the number of states loaded, before they were filtered.
+func printStatus(out io.Writer, format string, rows []statusRow, loaded int, modified time.Time) error {
+	switch format {
+	case "", "table":
+		printStatusTable(out, rows, loaded, modified)
+		return nil
+	case "json":
+		return printStatusJSON(out, rows, modified)
+	case "csv":
+		return printStatusCSV(out, rows)
+	}
+	return fmt.Errorf("unknown format %q, use json, table or csv", format)
+}
+
+func printStatusTable(out io.Writer, rows []statusRow, loaded int, modified time.Time) {
+	fmt.Fprintf(out, "Loaded %d states from %s\n", loaded, shared.StatePath)
+	fmt.Fprintf(out, "Last modified time: %s\n", modified.Format(time.RFC3339))
+	for _, row := range rows {
+		if row.Tampered != "" {
+			fmt.Fprintf(out, "Result of %s cannot be trusted: %s\n", row.Name, row.Tampered)
+		}
+	}
+	fmt.Fprintln(out)
+
+	data := [][]string{}
+	for _, row := range rows {
+		data = append(data, []string{row.Claim, row.UUID, row.Name, strings.ToUpper(row.State[:1]) + row.State[1:], statusAge(row), row.Details})
+	}
+
+	printTable(out, []string{"Claim", "UUID", "Name", "State", "Age", "Details"}, data)
+}
+
+// statusClaim groups the rows of a claim in the JSON output.
+type statusClaim struct {
+	Title  string      `json:"title"`
+	Checks []statusRow `json:"checks"`
+}
+
+func printStatusJSON(out io.Writer, rows []statusRow, modified time.Time) error {
+	output := struct {
+		Passed       int           `json:"passed"`
+		Failed       int           `json:"failed"`
+		Disabled     int           `json:"disabled"`
+		LastModified time.Time     `json:"lastModified"`
+		Claims       []statusClaim `json:"claims"`
+	}{LastModified: modified, Claims: []statusClaim{}}
+
+	for _, row := range rows {
+		switch row.State {
+		case "pass":
+			output.Passed++
+		case "fail", "error", "tampered":
+			output.Failed++
+		default:
+			output.Disabled++
+		}
+		if n := len(output.Claims); n == 0 || output.Claims[n-1].Title != row.Claim {
+			output.Claims = append(output.Claims, statusClaim{Title: row.Claim})
+		}
+		last := &output.Claims[len(output.Claims)-1]
+		last.Checks = append(last.Checks, row)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+func printStatusCSV(out io.Writer, rows []statusRow) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"claim", "uuid", "name", "state", "details", "checked_at", "age_seconds"}); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		checkedAt, age := "", ""
+		if row.CheckedAt != nil {
+			checkedAt = row.CheckedAt.Format(time.RFC3339)
+			age = strconv.FormatInt(row.AgeSeconds, 10)
+		}
+		if err := writer.Write([]string{row.Claim, row.UUID, row.Name, row.State, row.Details, checkedAt, age}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
 func init() {
+	statusCmd.Flags().String("format", "table", "output format: json, table or csv")
+	statusCmd.Flags().Bool("failed", false, "only show failing checks")
+	statusCmd.Flags().String("claim", "", "only show the checks of the claim with this title")
+	statusCmd.Flags().String("uuid", "", "only show the check with this UUID")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/claims"
+	"github.com/ParetoSecurity/agent/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+// statusCheck is a check that is only known by its UUID.
+type statusCheck struct {
+	fixableCheck
+	uuid string
+}
+
+func (s *statusCheck) UUID() string { return s.uuid }
+
+func TestStatusRows(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	all := []claims.Claim{
+		{Title: "Firewall", Checks: []check.Check{&statusCheck{uuid: "fw"}}},
+		{Title: "Access", Checks: []check.Check{&statusCheck{uuid: "ssh"}, &statusCheck{uuid: "lock"}}},
+	}
+	states := map[string]shared.LastState{
+		"lock":    {UUID: "lock", Name: "Screen lock", Outcome: check.OutcomePass, State: true, CheckedAt: now.Add(-time.Hour)},
+		"ssh":     {UUID: "ssh", Name: "SSH", Outcome: check.OutcomeFail, Details: "Root login is enabled"},
+		"fw":      {UUID: "fw", Name: "Firewall", Outcome: check.OutcomeError, Details: "timed out"},
+		"removed": {UUID: "removed", Name: "Removed", Outcome: check.OutcomeNotApplicable},
+	}
+
+	var uuids []string
+	rows := statusRows(all, states, statusFilter{}, now)
+	for _, row := range rows {
+		uuids = append(uuids, row.Claim+"/"+row.UUID)
+	}
+	assert.Equal(t, []string{"Firewall/fw", "Access/ssh", "Access/lock", "Other/removed"}, uuids)
+	assert.Equal(t, time.Hour, rows[2].Age)
+	assert.Nil(t, rows[1].CheckedAt)
+
+	rows = statusRows(all, states, statusFilter{failed: true}, now)
+	assert.Len(t, rows, 2)
+	rows = statusRows(all, states, statusFilter{claim: "access", failed: true}, now)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, "ssh", rows[0].UUID)
+	rows = statusRows(all, states, statusFilter{uuid: "removed"}, now)
+	assert.Len(t, rows, 1)
+	assert.Equal(t, "off", rows[0].State)
+
+	states["lock"] = shared.LastState{UUID: "lock", Name: "Screen lock", Outcome: check.OutcomePass, State: true, Tampered: "the result was modified outside of the agent"}
+	rows = statusRows(all, states, statusFilter{failed: true}, now)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, "tampered", rows[2].State)
+	assert.Equal(t, "the result was modified outside of the agent", rows[2].Tampered)
+}
+
+func TestPrintStatus(t *testing.T) {
+	checkedAt := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	rows := []statusRow{
+		{Claim: "Access", UUID: "lock", Name: "Screen lock", State: "pass", Outcome: check.OutcomePass, CheckedAt: &checkedAt, Age: time.Hour, AgeSeconds: 3600},
+		{Claim: "Access", UUID: "ssh", Name: "SSH", State: "fail", Outcome: check.OutcomeFail, Details: "Root login, enabled"},
+		{Claim: "Firewall", UUID: "fw", Name: "Firewall", State: "error", Outcome: check.OutcomeError},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, printStatus(&out, "json", rows, 5, checkedAt))
+	var decoded struct {
+		Passed int `json:"passed"`
+		Failed int `json:"failed"`
+		Claims []struct {
+			Title  string      `json:"title"`
+			Checks []statusRow `json:"checks"`
+		} `json:"claims"`
+	}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, 1, decoded.Passed)
+	assert.Equal(t, 2, decoded.Failed)
+	assert.Len(t, decoded.Claims, 2)
+	assert.Len(t, decoded.Claims[0].Checks, 2)
+	assert.Equal(t, int64(3600), decoded.Claims[0].Checks[0].AgeSeconds)
+
+	out.Reset()
+	assert.NoError(t, printStatus(&out, "csv", rows, 5, checkedAt))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, "claim,uuid,name,state,details,checked_at,age_seconds", lines[0])
+	assert.Equal(t, "Access,lock,Screen lock,pass,,2024-05-01T11:00:00Z,3600", lines[1])
+	assert.Equal(t, `Access,ssh,SSH,fail,"Root login, enabled",,`, lines[2])
+
+	out.Reset()
+	assert.NoError(t, printStatus(&out, "table", rows, 5, checkedAt))
+	assert.Contains(t, out.String(), "Loaded 5 states from")
+	assert.Contains(t, out.String(), "1h0m0s")
+	assert.Contains(t, out.String(), "unknown")
+
+	out.Reset()
+	rows[0].State, rows[0].Tampered = "tampered", "the result was modified outside of the agent"
+	assert.NoError(t, printStatus(&out, "table", rows, 5, checkedAt))
+	assert.Contains(t, out.String(), "Result of Screen lock cannot be trusted: the result was modified outside of the agent")
+
+	assert.Error(t, printStatus(&out, "yaml", rows, 5, checkedAt))
+}
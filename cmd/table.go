@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// printTable prints data under header as a borderless table with left
+// aligned, tab separated columns.
+func printTable(out io.Writer, header []string, data [][]string) {
+	table := tablewriter.NewWriter(out)
+	table.SetHeader(header)
+	table.SetAutoWrapText(false)
+	table.SetAutoFormatHeaders(true)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(data)
+	table.Render()
+}
@@ -4,8 +4,8 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"net/url"
 	"os"
 	"runtime"
 	"time"
@@ -17,6 +17,7 @@ import (
 	"github.com/ParetoSecurity/agent/claims"
 	"github.com/ParetoSecurity/agent/shared"
 	"github.com/ParetoSecurity/agent/systemd"
+	"github.com/ParetoSecurity/agent/team"
 	"github.com/caarlos0/log"
 	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/browser"
@@ -61,7 +62,7 @@ func addOptions() {
 		for range mlink.ClickedCh {
 			if !shared.IsLinked() {
 				//open browser with help link
-				if err := browser.OpenURL("https://paretosecurity.com/docs/linux/link"); err != nil {
+				if err := browser.OpenURL(shared.Endpoints().LinkURL()); err != nil {
 					log.WithError(err).Error("failed to open help URL")
 				}
 			} else {
@@ -165,7 +166,7 @@ func onReady() {
 		}
 	}()
 
-	for _, claim := range claims.All {
+	for _, claim := range claims.All() {
 		mClaim := systray.AddMenuItem(claim.Title, "")
 		updateClaim(claim, mClaim)
 
@@ -187,15 +188,13 @@ func onReady() {
 			}(chk, mCheck)
 			go func(chk check.Check, mCheck *systray.MenuItem) {
 				for range mCheck.ClickedCh {
-					log.WithField("check", chk.Name()).Info("Opening check URL")
-					arch := "check-linux"
-					if runtime.GOOS == "windows" {
-						arch = "check-windows"
+					if isExternalCheck(chk) {
+						// Plugins and custom checks have no page on the website
+						Notify(fmt.Sprintf("%s: %s", chk.Name(), chk.Status()))
+						continue
 					}
-
-					url := fmt.Sprintf("https://paretosecurity.com/%s/%s?details=%s", arch, chk.UUID(), url.QueryEscape(chk.Status()))
-
-					if err := browser.OpenURL(url); err != nil {
+					log.WithField("check", chk.Name()).Info("Opening check URL")
+					if err := browser.OpenURL(checkURL(chk.UUID(), chk.Status())); err != nil {
 						log.WithError(err).Error("failed to open check URL")
 					}
 				}
@@ -238,6 +237,32 @@ func onReady() {
 		}
 	}()
 
+	go flushQueuedReports(context.Background())
+}
+
+// flushQueuedReports retries the team reports that could not be sent, when
+// their backoff has passed and right away when the network comes back up.
+func flushQueuedReports(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	up := networkUp(ctx)
+	for {
+		force := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-up:
+			log.Info("Network is up, sending queued team reports")
+			force = true
+		}
+		if !shared.IsLinked() {
+			continue
+		}
+		if err := team.FlushQueue(ctx, force); err != nil {
+			log.WithError(err).Debug("Failed to send queued team reports")
+		}
+	}
 }
 
 func updateCheck(chk check.Check, mCheck *systray.MenuItem) {
@@ -246,11 +271,22 @@ func updateCheck(chk check.Check, mCheck *systray.MenuItem) {
 		mCheck.SetTitle(fmt.Sprintf("🚫 %s", chk.Name()))
 		return
 	}
+	if shared.IsCheckExempted(chk.UUID()) {
+		mCheck.Disable()
+		mCheck.SetTitle(fmt.Sprintf("⏸️ %s", chk.Name()))
+		return
+	}
 	mCheck.Enable()
 	checkStatus, found, _ := shared.GetLastState(chk.UUID())
+	if found && checkStatus.Tampered != "" {
+		mCheck.SetTitle(fmt.Sprintf("⚠️ %s", chk.Name()))
+		mCheck.SetTooltip("Result cannot be trusted: " + checkStatus.Tampered)
+		return
+	}
+	mCheck.SetTooltip("")
 	state := chk.Passed()
 	if found {
-		state = checkStatus.State
+		state = checkStatus.Result().Passed()
 	}
 	mCheck.SetTitle(fmt.Sprintf("%s %s", checkStatusToIcon(state), chk.Name()))
 }
@@ -258,7 +294,7 @@ func updateCheck(chk check.Check, mCheck *systray.MenuItem) {
 func updateClaim(claim claims.Claim, mClaim *systray.MenuItem) {
 	for _, chk := range claim.Checks {
 		checkStatus, found, _ := shared.GetLastState(chk.UUID())
-		if found && checkStatus.State == false && chk.IsRunnable() {
+		if found && !checkStatus.Result().Passed() && chk.IsRunnable() && !shared.IsCheckExempted(chk.UUID()) {
 			mClaim.SetTitle(fmt.Sprintf("❌ %s", claim.Title))
 			return
 		}
@@ -268,8 +304,9 @@ func updateClaim(claim claims.Claim, mClaim *systray.MenuItem) {
 }
 
 var trayiconCmd = &cobra.Command{
-	Use:   "trayicon",
-	Short: "Display the status of the checks in the system tray",
+	Use:         "trayicon",
+	Short:       "Display the status of the checks in the system tray",
+	Annotations: needsChecks,
 	Run: func(cc *cobra.Command, args []string) {
 
 		onExit := func() {
@@ -16,6 +16,9 @@ var unlinkCmd = &cobra.Command{
 		log.Info("Unlinking device ...")
 		shared.Config.TeamID = ""
 		shared.Config.AuthToken = ""
+		if err := shared.DeleteDeviceKey(); err != nil {
+			log.WithError(err).Warn("failed to delete device key")
+		}
 		if err := shared.SaveConfig(); err != nil {
 			log.WithError(err).Warn("failed to save config")
 			if testing.Testing() {
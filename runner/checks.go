@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"runtime"
 	"sync"
+	"time"
 
 	"github.com/fatih/color"
 
@@ -16,67 +18,148 @@ import (
 	"github.com/samber/lo"
 )
 
-// wrapStatus formats the check status with color-coded indicators.
-func wrapStatus(chk check.Check) string {
-	if chk.Passed() {
-		return fmt.Sprintf("%s %s", color.GreenString("[OK]"), chk.Status())
+// wrapStatus formats the check result with color-coded indicators.
+// Failures are annotated with the severity of the check.
+func wrapStatus(res check.Result, meta check.Metadata) string {
+	switch res.Outcome {
+	case check.OutcomePass:
+		return fmt.Sprintf("%s %s", color.GreenString("[OK]"), res.Reason)
+	case check.OutcomeError:
+		return fmt.Sprintf("%s [%s] %s", color.RedString("[ERROR]"), meta.Severity, res.Reason)
+	default:
+		return fmt.Sprintf("%s [%s] %s", color.RedString("[FAIL]"), meta.Severity, res.Reason)
 	}
-	return fmt.Sprintf("%s %s", color.RedString("[FAIL]"), chk.Status())
 }
 
 // Check runs a series of checks concurrently for a list of claims.
 //
 // It iterates over each claim provided in claimsTorun and, for each claim,
-// over its associated checks. Each check is executed in its own goroutine.
-func Check(ctx context.Context, claimsTorun []claims.Claim, skipUUIDs []string, onlyUUID string) {
+// over its associated checks. Each check is executed in its own goroutine
+// and is abandoned with the error outcome when its configured timeout passes.
+// A check waits for the checks it depends on, and is skipped unless they
+// are part of the run and pass. The returned results mirror the order of
+// claimsTorun and their checks.
+func Check(ctx context.Context, claimsTorun []claims.Claim, skipUUIDs []string, onlyUUID string) []ClaimResult {
 
-	var checkLogger = log.New(os.Stdout)
+	var checkLogger = log.New(os.Stderr)
 	var wg sync.WaitGroup
+	family := shared.DistroFamily()
+	results := make([]ClaimResult, len(claimsTorun))
 	checkLogger.Info("Starting checks...")
 
-	for _, claim := range claimsTorun {
-		for _, chk := range claim.Checks {
+	// Each check closes its done channel once its result is known, so that
+	// the checks depending on it can proceed.
+	type slot struct {
+		result *CheckResult
+		done   chan struct{}
+	}
+	slots := map[string]slot{}
+	dones := make([][]chan struct{}, len(claimsTorun))
+	for i, claim := range claimsTorun {
+		results[i] = ClaimResult{
+			Title:  claim.Title,
+			Checks: make([]CheckResult, len(claim.Checks)),
+		}
+		dones[i] = make([]chan struct{}, len(claim.Checks))
+		for j, chk := range claim.Checks {
+			dones[i][j] = make(chan struct{})
+			if _, found := slots[chk.UUID()]; !found {
+				slots[chk.UUID()] = slot{result: &results[i].Checks[j], done: dones[i][j]}
+			}
+		}
+	}
+	selected := selectWithDependencies(onlyUUID)
+
+	// Run the checks that need root in one round-trip to the root helper
+	ctx = shared.PrefetchViaHelper(ctx, rootUUIDs(claimsTorun, skipUUIDs, selected))
+
+	for i, claim := range claimsTorun {
+		for j, chk := range claim.Checks {
+			result := &results[i].Checks[j]
+			done := dones[i][j]
+			meta := check.MetadataOf(chk)
+
 			// Skip checks that are skipped
 			if lo.Contains(skipUUIDs, chk.UUID()) {
 				checkLogger.Warn(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), fmt.Sprintf("%s Skipped by the command rule", color.YellowString("[SKIP]"))))
+				*result = newCheckResult(chk, check.Result{Outcome: check.OutcomeSkipped, Reason: "Skipped by the command rule"}, meta, family, 0)
+				close(done)
 				continue
 			}
 			wg.Add(1)
 			go func(claim claims.Claim, chk check.Check) {
 				defer wg.Done()
+				defer close(done)
 				select {
 				case <-ctx.Done():
+					*result = newCheckResult(chk, check.Result{Outcome: check.OutcomeSkipped, Reason: "Check run was cancelled"}, meta, family, 0)
 					return
 				default:
 
 					// Skip checks that are not in the onlyUUID list
-					if onlyUUID != "" && onlyUUID != chk.UUID() {
+					if selected != nil && !selected[chk.UUID()] {
 						checkLogger.Debug(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), fmt.Sprintf("%s Skipped by the command rule", color.YellowString("[SKIP]"))))
+						*result = newCheckResult(chk, check.Result{Outcome: check.OutcomeSkipped, Reason: "Not selected by the command rule"}, meta, family, 0)
 						return
 					}
 
-					// Skip checks that are not runnable
-					if !chk.IsRunnable() {
-						checkLogger.Warn(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), chk.Status()))
+					// Skip checks that are exempted in the config
+					if exemption, ok := shared.GetExemption(chk.UUID()); ok {
+						checkLogger.Warn(fmt.Sprintf("%s: %s > %s %s", claim.Title, chk.Name(), color.YellowString("[SKIP]"), exemption.Reason()))
+						res := check.Result{
+							Outcome: check.OutcomeSkipped,
+							Reason:  exemption.Reason(),
+						}
+						shared.UpdateLastState(shared.NewLastState(chk, res))
+						*result = newCheckResult(chk, res, meta, family, 0)
 						return
 					}
 
-					if err := chk.Run(); err != nil {
-						log.WithError(err).Warnf("%s: %s > %s", claim.Title, chk.Name(), err.Error())
+					// Skip checks whose dependencies did not pass
+					for _, dep := range dependencies(chk.UUID()) {
+						depSlot, found := slots[dep]
+						if !found {
+							reason := fmt.Sprintf("Requires %s, which is not part of this run", dependencyName(dep))
+							checkLogger.Warn(fmt.Sprintf("%s: %s > %s %s", claim.Title, chk.Name(), color.YellowString("[SKIP]"), reason))
+							res := check.Result{Outcome: check.OutcomeSkipped, Reason: reason}
+							shared.UpdateLastState(shared.NewLastState(chk, res))
+							*result = newCheckResult(chk, res, meta, family, 0)
+							return
+						}
+						select {
+						case <-depSlot.done:
+						case <-ctx.Done():
+							*result = newCheckResult(chk, check.Result{Outcome: check.OutcomeSkipped, Reason: "Check run was cancelled"}, meta, family, 0)
+							return
+						}
+						if depResult := depSlot.result; depResult.Outcome != check.OutcomePass {
+							reason := fmt.Sprintf("Requires %s, which did not pass", depResult.Name)
+							checkLogger.Warn(fmt.Sprintf("%s: %s > %s %s", claim.Title, chk.Name(), color.YellowString("[SKIP]"), reason))
+							res := check.Result{Outcome: check.OutcomeSkipped, Reason: reason}
+							shared.UpdateLastState(shared.NewLastState(chk, res))
+							*result = newCheckResult(chk, res, meta, family, 0)
+							return
+						}
 					}
 
-					if chk.Passed() {
-						checkLogger.Info(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), wrapStatus(chk)))
-					} else {
-						checkLogger.Warn(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), wrapStatus(chk)))
+					checkCtx, cancel := context.WithTimeout(ctx, shared.CheckTimeout(chk.UUID()))
+					defer cancel()
+					started := time.Now()
+					res := check.Evaluate(checkCtx, chk)
+					*result = newCheckResult(chk, res, meta, family, time.Since(started))
+					switch res.Outcome {
+					case check.OutcomeNotApplicable:
+						checkLogger.Warn(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), res.Reason))
+					case check.OutcomePass:
+						checkLogger.Info(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), wrapStatus(res, meta)))
+					default:
+						checkLogger.Warn(fmt.Sprintf("%s: %s > %s", claim.Title, chk.Name(), wrapStatus(res, meta)))
+						if result.Remediation != "" {
+							checkLogger.Info(fmt.Sprintf("%s: %s > %s %s", claim.Title, chk.Name(), color.CyanString("[FIX]"), result.Remediation))
+						}
 					}
 
-					shared.UpdateLastState(shared.LastState{
-						UUID:    chk.UUID(),
-						Name:    chk.Name(),
-						State:   chk.Passed(),
-						Details: chk.Status(),
-					})
+					shared.UpdateLastState(shared.NewLastState(chk, res))
 				}
 			}(claim, chk)
 		}
@@ -85,8 +168,128 @@ func Check(ctx context.Context, claimsTorun []claims.Claim, skipUUIDs []string,
 	if err := shared.CommitLastState(); err != nil {
 		log.WithError(err).Warn("failed to commit last state")
 	}
+	if err := shared.AppendHistory(historyEntries(results, time.Now())); err != nil {
+		log.WithError(err).Warn("failed to append check history")
+	}
 
 	checkLogger.Info("Checks completed.")
+	return results
+}
+
+// historyEntries returns the history entries for the checks that ran, once
+// per check even if it belongs to several claims.
+func historyEntries(results []ClaimResult, now time.Time) []shared.HistoryEntry {
+	var entries []shared.HistoryEntry
+	seen := map[string]bool{}
+	for _, claim := range results {
+		for _, res := range claim.Checks {
+			if res.Outcome == check.OutcomeSkipped || seen[res.UUID] {
+				continue
+			}
+			seen[res.UUID] = true
+			entries = append(entries, shared.HistoryEntry{
+				Time:       now,
+				UUID:       res.UUID,
+				Name:       res.Name,
+				Outcome:    res.Outcome,
+				Details:    res.Details,
+				DurationMs: res.DurationMs,
+			})
+		}
+	}
+	return entries
+}
+
+// dependencies returns the UUIDs of the checks the check with uuid depends
+// on, as registered in claims.Registry.
+func dependencies(uuid string) []string {
+	reg, found := claims.Registry.Lookup(uuid, runtime.GOOS)
+	if !found {
+		return nil
+	}
+	return reg.DependsOn
+}
+
+// dependencyName returns the name of the check with uuid, or uuid if it is
+// not registered.
+func dependencyName(uuid string) string {
+	if chk := claims.Find(uuid); chk != nil {
+		return chk.Name()
+	}
+	return uuid
+}
+
+// tags returns the tags of the check with uuid, as registered in
+// claims.Registry.
+func tags(uuid string) []string {
+	reg, found := claims.Registry.Lookup(uuid, runtime.GOOS)
+	if !found {
+		return nil
+	}
+	return reg.Tags
+}
+
+// rootUUIDs returns the UUIDs of the checks in claimsTorun that will be run
+// via the root helper.
+func rootUUIDs(claimsTorun []claims.Claim, skipUUIDs []string, selected map[string]bool) []string {
+	if shared.IsRoot() || !shared.IsSocketServicePresent() {
+		return nil
+	}
+	var uuids []string
+	for _, claim := range claimsTorun {
+		for _, chk := range claim.Checks {
+			uuid := chk.UUID()
+			if !chk.RequiresRoot() || lo.Contains(skipUUIDs, uuid) || selected != nil && !selected[uuid] {
+				continue
+			}
+			if _, exempted := shared.GetExemption(uuid); exempted {
+				continue
+			}
+			uuids = append(uuids, uuid)
+		}
+	}
+	return lo.Uniq(uuids)
+}
+
+// selectWithDependencies returns the set of UUIDs selected by onlyUUID,
+// including the checks it depends on, or nil if every check is selected.
+func selectWithDependencies(onlyUUID string) map[string]bool {
+	if onlyUUID == "" {
+		return nil
+	}
+	selected := map[string]bool{}
+	pending := []string{onlyUUID}
+	for len(pending) > 0 {
+		uuid := pending[0]
+		pending = pending[1:]
+		if selected[uuid] {
+			continue
+		}
+		selected[uuid] = true
+		pending = append(pending, dependencies(uuid)...)
+	}
+	return selected
+}
+
+// HighestFailedSeverity returns the highest severity among the checks in
+// claimsTorun that are listed in failed. It returns an empty severity if
+// none of the failed checks are known.
+func HighestFailedSeverity(claimsTorun []claims.Claim, failed []shared.LastState) check.Severity {
+	var highest check.Severity
+	for _, claim := range claimsTorun {
+		for _, chk := range claim.Checks {
+			isFailed := lo.ContainsBy(failed, func(state shared.LastState) bool {
+				return state.UUID == chk.UUID()
+			})
+			if !isFailed {
+				continue
+			}
+			if severity := check.MetadataOf(chk).Severity; severity.Rank() > highest.Rank() {
+				highest = severity
+			}
+		}
+	}
+	return highest
 }
 
 // PrintSchemaJSON constructs and prints a JSON schema generated from a slice of claims.
@@ -110,3 +313,38 @@ func PrintSchemaJSON(claimsTorun []claims.Claim) {
 	}
 	fmt.Println(string(out))
 }
+
+type schemaEntry struct {
+	Name          string         `json:"name"`
+	PassedMessage string         `json:"passedMessage"`
+	FailedMessage string         `json:"failedMessage"`
+	RequiresRoot  bool           `json:"requiresRoot"`
+	Tags          []string       `json:"tags,omitempty"`
+	Metadata      check.Metadata `json:"metadata"`
+}
+
+// PrintSchemaMetadataJSON prints the schema of all checks, grouped by claim
+// title and check UUID, including their tags, severity, category and
+// remediation.
+func PrintSchemaMetadataJSON(claimsTorun []claims.Claim) {
+	schema := make(map[string]map[string]schemaEntry)
+	for _, claim := range claimsTorun {
+		checks := make(map[string]schemaEntry)
+		for _, chk := range claim.Checks {
+			checks[chk.UUID()] = schemaEntry{
+				Name:          chk.Name(),
+				PassedMessage: chk.PassedMessage(),
+				FailedMessage: chk.FailedMessage(),
+				RequiresRoot:  chk.RequiresRoot(),
+				Tags:          tags(chk.UUID()),
+				Metadata:      check.MetadataOf(chk),
+			}
+		}
+		schema[claim.Title] = checks
+	}
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		log.WithError(err).Warn("cannot marshal schema")
+	}
+	fmt.Println(string(out))
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
+	"github.com/ParetoSecurity/agent/shared"
 )
 
 // captureOutput redirects stdout and returns what was printed.
@@ -47,7 +49,7 @@ type DummyCheck struct {
 
 func (d *DummyCheck) IsRunnable() bool { return d.runnable }
 func (d *DummyCheck) Name() string     { return d.name }
-func (d *DummyCheck) Run() error {
+func (d *DummyCheck) Run(ctx context.Context) error {
 	atomic.StoreInt32(&d.runCalled, 1)
 	return d.runErr
 }
@@ -59,6 +61,7 @@ func (d *DummyCheck) FailedMessage() string { return "failed" }
 func (d *DummyCheck) RequiresRoot() bool    { return false }
 
 func TestCheckSuccess(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
 
 	// Create a dummy check that is runnable and passes.
 	dc := &DummyCheck{
@@ -74,14 +77,57 @@ func TestCheckSuccess(t *testing.T) {
 		}},
 	}
 	ctx := context.Background()
-	Check(ctx, dummyClaims, []string{}, "")
+	results := Check(ctx, dummyClaims, []string{}, "")
 
 	if atomic.LoadInt32(&dc.runCalled) != 1 {
 		t.Errorf("Expected Run to be called on DummyCheck, but it wasn't")
 	}
+	if len(results) != 1 || len(results[0].Checks) != 1 {
+		t.Fatalf("Expected one claim with one check result, got %+v", results)
+	}
+	if res := results[0].Checks[0]; res.UUID != "uuid-pass" || res.Outcome != check.OutcomePass || res.Details != "ok" {
+		t.Errorf("Unexpected check result %+v", res)
+	}
+
+	history, err := shared.ReadHistory("uuid-pass", time.Time{})
+	if err != nil || len(history) != 1 || history[0].Outcome != check.OutcomePass {
+		t.Errorf("Expected the result to be appended to the history, got %+v (%v)", history, err)
+	}
+}
+
+func TestCheckSkipped(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+
+	dc := &DummyCheck{
+		name:      "DummySkipped",
+		runnable:  true,
+		passedVal: true,
+		uuid:      "uuid-skipped",
+	}
+	other := &DummyCheck{
+		name:      "DummyOther",
+		runnable:  true,
+		passedVal: true,
+		uuid:      "uuid-other",
+	}
+	dummyClaims := []claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{dc, other}},
+	}
+	results := Check(context.Background(), dummyClaims, []string{"uuid-skipped"}, "")
+
+	if atomic.LoadInt32(&dc.runCalled) != 0 {
+		t.Errorf("Expected Run NOT to be called on skipped DummyCheck, but it was")
+	}
+	if res := results[0].Checks[0]; res.Outcome != check.OutcomeSkipped || res.Details == "" {
+		t.Errorf("Expected skipped result with a reason, got %+v", res)
+	}
+	if res := results[0].Checks[1]; res.Outcome != check.OutcomePass {
+		t.Errorf("Expected other check to pass, got %+v", res)
+	}
 }
 
 func TestCheckNotRunnable(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
 
 	// Create a dummy check that is not runnable.
 	dc := &DummyCheck{
@@ -103,7 +149,39 @@ func TestCheckNotRunnable(t *testing.T) {
 	}
 }
 
+func TestCheckExempted(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	shared.Config.Checks = map[string]shared.CheckStatus{
+		"uuid-exempted": {Disabled: true, Justification: "not applicable to kiosks"},
+	}
+	defer func() { shared.Config.Checks = nil }()
+
+	dc := &DummyCheck{
+		name:      "DummyExempted",
+		runnable:  true,
+		passedVal: false,
+		statusMsg: "failed",
+		uuid:      "uuid-exempted",
+	}
+	dummyClaims := []claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{dc}},
+	}
+	Check(context.Background(), dummyClaims, []string{}, "")
+
+	if atomic.LoadInt32(&dc.runCalled) != 0 {
+		t.Errorf("Expected Run NOT to be called on exempted DummyCheck, but it was")
+	}
+	state, found, _ := shared.GetLastState("uuid-exempted")
+	if !found || state.Outcome != check.OutcomeSkipped {
+		t.Errorf("Expected exempted check to be recorded as skipped, got %+v", state)
+	}
+	if !shared.AllChecksPassed() {
+		t.Errorf("Expected exempted check not to count as failed")
+	}
+}
+
 func TestCheckContextCanceled(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
 
 	// Create a dummy check that is runnable.
 	dc := &DummyCheck{
@@ -130,6 +208,87 @@ func TestCheckContextCanceled(t *testing.T) {
 	}
 }
 
+// slowCheck blocks until its context is done.
+type slowCheck struct {
+	DummyCheck
+}
+
+func (s *slowCheck) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCheckTimeout(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	shared.Config.Checks = map[string]shared.CheckStatus{
+		"uuid-slow": {Timeout: "20ms"},
+	}
+	defer func() { shared.Config.Checks = nil }()
+
+	slow := &slowCheck{DummyCheck{name: "DummySlow", runnable: true, uuid: "uuid-slow"}}
+	fast := &DummyCheck{name: "DummyFast", runnable: true, passedVal: true, statusMsg: "ok", uuid: "uuid-fast"}
+	dummyClaims := []claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{slow, fast}},
+	}
+	results := Check(context.Background(), dummyClaims, []string{}, "")
+
+	if got := results[0].Checks[0]; got.Outcome != check.OutcomeError || got.Details != "Check timed out" {
+		t.Errorf("Expected slow check to time out with an error, got %+v", got)
+	}
+	if got := results[0].Checks[1]; got.Outcome != check.OutcomePass {
+		t.Errorf("Expected fast check to pass, got %+v", got)
+	}
+
+	state, found, _ := shared.GetLastState("uuid-slow")
+	if !found || state.Outcome != check.OutcomeError {
+		t.Errorf("Expected timed out check to be recorded as error, got %+v", state)
+	}
+	state, found, _ = shared.GetLastState("uuid-fast")
+	if !found || state.Outcome != check.OutcomePass {
+		t.Errorf("Expected finished check to be recorded, got %+v", state)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	registry := claims.Registry
+	claims.Registry = check.NewRegistry()
+	defer func() { claims.Registry = registry }()
+
+	base := &DummyCheck{name: "Base", runnable: true, passedVal: false, statusMsg: "failed", uuid: "uuid-base"}
+	dependent := &DummyCheck{name: "Dependent", runnable: true, passedVal: true, statusMsg: "ok", uuid: "uuid-dependent"}
+	claims.Registry.MustRegister(check.Registration{Claim: "Test Case", New: func() check.Check { return base }})
+	claims.Registry.MustRegister(check.Registration{Claim: "Test Case", DependsOn: []string{"uuid-base"}, New: func() check.Check { return dependent }})
+
+	// The dependent check is listed first, so it has to wait for its dependency
+	dummyClaims := []claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{dependent, base}},
+	}
+	results := Check(context.Background(), dummyClaims, []string{}, "")
+	if got := results[0].Checks[0]; got.Outcome != check.OutcomeSkipped || got.Details != "Requires Base, which did not pass" {
+		t.Errorf("Expected dependent check to be skipped, got %+v", got)
+	}
+	if atomic.LoadInt32(&dependent.runCalled) != 0 {
+		t.Errorf("Expected Run NOT to be called on the dependent check, but it was")
+	}
+
+	// Selecting the dependent check also runs its dependency
+	base.passedVal = true
+	results = Check(context.Background(), dummyClaims, []string{}, "uuid-dependent")
+	if got := results[0].Checks[1]; got.Outcome != check.OutcomePass {
+		t.Errorf("Expected dependency to run and pass, got %+v", got)
+	}
+	if got := results[0].Checks[0]; got.Outcome != check.OutcomePass {
+		t.Errorf("Expected dependent check to run and pass, got %+v", got)
+	}
+
+	// A dependency that is not part of the run does not count as passing
+	results = Check(context.Background(), []claims.Claim{{Title: "Test Case", Checks: []check.Check{dependent}}}, []string{}, "")
+	if got := results[0].Checks[0]; got.Outcome != check.OutcomeSkipped || got.Details != "Requires Base, which is not part of this run" {
+		t.Errorf("Expected dependent check without its dependency to be skipped, got %+v", got)
+	}
+}
+
 func TestPrintSchemaJSON(t *testing.T) {
 	// Create a dummy check that returns known passed/failed messages.
 	dc := &DummyCheck{
@@ -172,3 +331,73 @@ func TestPrintSchemaJSON(t *testing.T) {
 		t.Errorf("PrintSchemaJSON output mismatch.\nExpected:\n%s\nGot:\n%s", expectedOutput, output)
 	}
 }
+
+// DescribedCheck is a DummyCheck that provides metadata.
+type DescribedCheck struct {
+	DummyCheck
+	severity check.Severity
+}
+
+func (d *DescribedCheck) Metadata() check.Metadata {
+	return check.Metadata{
+		Severity:    d.severity,
+		Category:    "test",
+		Remediation: map[string]string{check.FamilyDefault: "fix it"},
+	}
+}
+
+func TestHighestFailedSeverity(t *testing.T) {
+	low := &DescribedCheck{DummyCheck: DummyCheck{uuid: "uuid-low"}, severity: check.SeverityLow}
+	critical := &DescribedCheck{DummyCheck: DummyCheck{uuid: "uuid-critical"}, severity: check.SeverityCritical}
+	plain := &DummyCheck{uuid: "uuid-plain"}
+	testClaims := []claims.Claim{
+		{Title: "Test Claim", Checks: []check.Check{low, critical, plain}},
+	}
+
+	tests := []struct {
+		name     string
+		failed   []shared.LastState
+		expected check.Severity
+	}{
+		{name: "nothing failed", failed: nil, expected: ""},
+		{name: "low failed", failed: []shared.LastState{{UUID: "uuid-low"}}, expected: check.SeverityLow},
+		{name: "check without metadata failed", failed: []shared.LastState{{UUID: "uuid-low"}, {UUID: "uuid-plain"}}, expected: check.SeverityMedium},
+		{name: "critical failed", failed: []shared.LastState{{UUID: "uuid-low"}, {UUID: "uuid-critical"}}, expected: check.SeverityCritical},
+		{name: "unknown check failed", failed: []shared.LastState{{UUID: "uuid-unknown"}}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HighestFailedSeverity(testClaims, tt.failed); got != tt.expected {
+				t.Errorf("HighestFailedSeverity() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrintSchemaMetadataJSON(t *testing.T) {
+	dc := &DescribedCheck{
+		DummyCheck: DummyCheck{name: "DummySchema", uuid: "uuid-schema"},
+		severity:   check.SeverityHigh,
+	}
+	claimsTorun := []claims.Claim{{Title: "Test Claim", Checks: []check.Check{dc}}}
+
+	output := captureOutput(func() {
+		PrintSchemaMetadataJSON(claimsTorun)
+	})
+
+	var schema map[string]map[string]schemaEntry
+	if err := json.Unmarshal([]byte(output), &schema); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+	entry, ok := schema["Test Claim"]["uuid-schema"]
+	if !ok {
+		t.Fatalf("expected check uuid-schema in schema, got %v", schema)
+	}
+	if entry.Name != "DummySchema" || entry.PassedMessage != "passed" || entry.FailedMessage != "failed" {
+		t.Errorf("unexpected schema entry: %+v", entry)
+	}
+	if entry.Metadata.Severity != check.SeverityHigh || entry.Metadata.RemediationFor(check.FamilyDebian) != "fix it" {
+		t.Errorf("unexpected schema metadata: %+v", entry.Metadata)
+	}
+}
@@ -0,0 +1,372 @@
+package runner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/ParetoSecurity/agent/shared"
+)
+
+// Formats lists the structured output formats supported by Render.
+var Formats = []string{"json", "junit", "sarif", "markdown", "tap"}
+
+// CheckResult is the outcome of a single check in a run.
+type CheckResult struct {
+	UUID        string            `json:"uuid"`
+	Name        string            `json:"name"`
+	Outcome     check.Outcome     `json:"outcome"`
+	Details     string            `json:"details"`
+	Evidence    map[string]string `json:"evidence,omitempty"`
+	Severity    check.Severity    `json:"severity"`
+	Category    string            `json:"category,omitempty"`
+	Remediation string            `json:"remediation,omitempty"`
+	DurationMs  int64             `json:"durationMs"`
+	Duration    time.Duration     `json:"-"`
+}
+
+// ClaimResult groups the results of the checks of a claim.
+type ClaimResult struct {
+	Title  string        `json:"title"`
+	Checks []CheckResult `json:"checks"`
+}
+
+func newCheckResult(chk check.Check, res check.Result, meta check.Metadata, family string, duration time.Duration) CheckResult {
+	return CheckResult{
+		UUID:        chk.UUID(),
+		Name:        chk.Name(),
+		Outcome:     res.Outcome,
+		Details:     res.Reason,
+		Evidence:    res.Evidence,
+		Severity:    meta.Severity,
+		Category:    meta.Category,
+		Remediation: meta.RemediationFor(family),
+		DurationMs:  duration.Milliseconds(),
+		Duration:    duration,
+	}
+}
+
+// Render writes results to w in the given format, one of Formats.
+func Render(w io.Writer, format string, results []ClaimResult) error {
+	switch format {
+	case "json":
+		return renderJSON(w, results)
+	case "junit":
+		return renderJUnit(w, results)
+	case "sarif":
+		return renderSARIF(w, results)
+	case "markdown":
+		return renderMarkdown(w, results)
+	case "tap":
+		return renderTAP(w, results)
+	}
+	return fmt.Errorf("unknown output format %q, use one of: %s", format, strings.Join(Formats, ", "))
+}
+
+func renderJSON(w io.Writer, results []ClaimResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Version string        `json:"version"`
+		Claims  []ClaimResult `json:"claims"`
+	}{
+		Version: shared.Version,
+		Claims:  results,
+	})
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Error      *junitMessage   `xml:"error,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func renderJUnit(w io.Writer, results []ClaimResult) error {
+	suites := junitTestSuites{Name: "paretosecurity"}
+	var total time.Duration
+	for _, claim := range results {
+		suite := junitTestSuite{Name: claim.Title}
+		var elapsed time.Duration
+		for _, res := range claim.Checks {
+			testCase := junitTestCase{
+				Name:      res.Name,
+				Classname: claim.Title,
+				Time:      junitSeconds(res.Duration),
+				Properties: []junitProperty{
+					{Name: "uuid", Value: res.UUID},
+					{Name: "severity", Value: string(res.Severity)},
+					{Name: "outcome", Value: string(res.Outcome)},
+				},
+			}
+			switch res.Outcome {
+			case check.OutcomePass:
+				testCase.SystemOut = res.Details
+			case check.OutcomeFail:
+				testCase.Failure = &junitMessage{Message: res.Details, Type: string(res.Severity), Text: res.Remediation}
+				suite.Failures++
+			case check.OutcomeError:
+				testCase.Error = &junitMessage{Message: res.Details, Type: string(res.Severity), Text: res.Remediation}
+				suite.Errors++
+			default:
+				testCase.Skipped = &junitMessage{Message: res.Details}
+				suite.Skipped++
+			}
+			suite.Tests++
+			elapsed += res.Duration
+			suite.TestCases = append(suite.TestCases, testCase)
+		}
+		suite.Time = junitSeconds(elapsed)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		total += elapsed
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string         `json:"id"`
+	Name             string         `json:"name"`
+	ShortDescription sarifText      `json:"shortDescription"`
+	Help             *sarifText     `json:"help,omitempty"`
+	Properties       map[string]any `json:"properties"`
+}
+
+type sarifResult struct {
+	RuleID     string         `json:"ruleId"`
+	RuleIndex  int            `json:"ruleIndex"`
+	Kind       string         `json:"kind"`
+	Level      string         `json:"level"`
+	Message    sarifText      `json:"message"`
+	Properties map[string]any `json:"properties"`
+}
+
+// sarifLevel maps the severity of a failed check to a SARIF level.
+func sarifLevel(severity check.Severity) string {
+	switch severity {
+	case check.SeverityCritical, check.SeverityHigh:
+		return "error"
+	case check.SeverityLow:
+		return "note"
+	}
+	return "warning"
+}
+
+func renderSARIF(w io.Writer, results []ClaimResult) error {
+	rules := []sarifRule{}
+	sarifResults := []sarifResult{}
+	for _, claim := range results {
+		for _, res := range claim.Checks {
+			rule := sarifRule{
+				ID:               res.UUID,
+				Name:             res.Name,
+				ShortDescription: sarifText{Text: res.Name},
+				Properties: map[string]any{
+					"claim":    claim.Title,
+					"category": res.Category,
+					"severity": string(res.Severity),
+				},
+			}
+			if res.Remediation != "" {
+				rule.Help = &sarifText{Text: res.Remediation}
+			}
+			rules = append(rules, rule)
+
+			result := sarifResult{
+				RuleID:    res.UUID,
+				RuleIndex: len(rules) - 1,
+				Kind:      "notApplicable",
+				Level:     "none",
+				Message:   sarifText{Text: res.Details},
+				Properties: map[string]any{
+					"outcome":    string(res.Outcome),
+					"durationMs": res.DurationMs,
+				},
+			}
+			if len(res.Evidence) > 0 {
+				result.Properties["evidence"] = res.Evidence
+			}
+			switch res.Outcome {
+			case check.OutcomePass:
+				result.Kind = "pass"
+			case check.OutcomeFail, check.OutcomeError:
+				result.Kind = "fail"
+				result.Level = sarifLevel(res.Severity)
+			}
+			if result.Message.Text == "" {
+				result.Message.Text = res.Name
+			}
+			sarifResults = append(sarifResults, result)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "Pareto Security",
+					"version":        shared.Version,
+					"informationUri": "https://paretosecurity.com",
+					"rules":          rules,
+				},
+			},
+			"results": sarifResults,
+		}},
+	})
+}
+
+// markdownEscape escapes text for use inside a markdown table cell.
+func markdownEscape(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", " ")
+}
+
+func markdownStatus(outcome check.Outcome) string {
+	switch outcome {
+	case check.OutcomePass:
+		return "✅ Pass"
+	case check.OutcomeFail:
+		return "❌ Fail"
+	case check.OutcomeError:
+		return "⚠️ Error"
+	case check.OutcomeSkipped:
+		return "⏭️ Skipped"
+	}
+	return "🚫 Not applicable"
+}
+
+func renderMarkdown(w io.Writer, results []ClaimResult) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Pareto Security report\n")
+	for _, claim := range results {
+		fmt.Fprintf(&b, "\n## %s\n\n", claim.Title)
+		fmt.Fprintf(&b, "| Status | Check | Severity | Details | Duration | UUID |\n")
+		fmt.Fprintf(&b, "|---|---|---|---|---|---|\n")
+		for _, res := range claim.Checks {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %dms | `%s` |\n",
+				markdownStatus(res.Outcome),
+				markdownEscape(res.Name),
+				res.Severity,
+				markdownEscape(res.Details),
+				res.DurationMs,
+				res.UUID,
+			)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapString quotes s so it can be used as a YAML scalar in TAP diagnostics.
+func tapString(s string) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func renderTAP(w io.Writer, results []ClaimResult) error {
+	var b strings.Builder
+	total := 0
+	for _, claim := range results {
+		total += len(claim.Checks)
+	}
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", total)
+
+	n := 0
+	for _, claim := range results {
+		for _, res := range claim.Checks {
+			n++
+			description := fmt.Sprintf("%s: %s [%s]", claim.Title, res.Name, res.UUID)
+			description = strings.ReplaceAll(description, "#", `\#`)
+			switch res.Outcome {
+			case check.OutcomePass:
+				fmt.Fprintf(&b, "ok %d - %s\n", n, description)
+			case check.OutcomeFail, check.OutcomeError:
+				fmt.Fprintf(&b, "not ok %d - %s\n", n, description)
+				fmt.Fprintf(&b, "  ---\n")
+				fmt.Fprintf(&b, "  outcome: %s\n", res.Outcome)
+				fmt.Fprintf(&b, "  severity: %s\n", res.Severity)
+				fmt.Fprintf(&b, "  message: %s\n", tapString(res.Details))
+				if res.Remediation != "" {
+					fmt.Fprintf(&b, "  remediation: %s\n", tapString(res.Remediation))
+				}
+				fmt.Fprintf(&b, "  duration_ms: %d\n", res.DurationMs)
+				fmt.Fprintf(&b, "  ...\n")
+			default:
+				fmt.Fprintf(&b, "ok %d - %s # SKIP %s\n", n, description, res.Details)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResults() []ClaimResult {
+	return []ClaimResult{
+		{Title: "Access Security", Checks: []CheckResult{
+			{UUID: "uuid-pass", Name: "Passing check", Outcome: check.OutcomePass, Details: "all good", Severity: check.SeverityLow, DurationMs: 12, Duration: 12 * time.Millisecond},
+			{UUID: "uuid-fail", Name: "Failing check", Outcome: check.OutcomeFail, Details: "port | open", Severity: check.SeverityCritical, Remediation: "close it", Evidence: map[string]string{"tcp/22": "SSH"}},
+		}},
+		{Title: "System Integrity", Checks: []CheckResult{
+			{UUID: "uuid-error", Name: "Erroring check", Outcome: check.OutcomeError, Details: "timed out", Severity: check.SeverityMedium},
+			{UUID: "uuid-skip", Name: "Skipped check", Outcome: check.OutcomeSkipped, Details: "Disabled by exemption", Severity: check.SeverityMedium},
+		}},
+	}
+}
+
+func TestRender_UnknownFormat(t *testing.T) {
+	var b bytes.Buffer
+	err := Render(&b, "yaml", testResults())
+	assert.Error(t, err)
+}
+
+func TestRender_JSON(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, Render(&b, "json", testResults()))
+
+	var out struct {
+		Claims []ClaimResult `json:"claims"`
+	}
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &out))
+	assert.Len(t, out.Claims, 2)
+	assert.Equal(t, "uuid-fail", out.Claims[0].Checks[1].UUID)
+	assert.Equal(t, check.OutcomeFail, out.Claims[0].Checks[1].Outcome)
+	assert.Equal(t, int64(12), out.Claims[0].Checks[0].DurationMs)
+	assert.Equal(t, "SSH", out.Claims[0].Checks[1].Evidence["tcp/22"])
+}
+
+func TestRender_JUnit(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, Render(&b, "junit", testResults()))
+
+	var out junitTestSuites
+	assert.NoError(t, xml.Unmarshal(b.Bytes(), &out))
+	assert.Equal(t, 4, out.Tests)
+	assert.Equal(t, 1, out.Failures)
+	assert.Equal(t, 1, out.Errors)
+	assert.Equal(t, 1, out.Skipped)
+	assert.Len(t, out.Suites, 2)
+	assert.Equal(t, "port | open", out.Suites[0].TestCases[1].Failure.Message)
+	assert.Equal(t, "0.012", out.Suites[0].TestCases[0].Time)
+}
+
+func TestRender_SARIF(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, Render(&b, "sarif", testResults()))
+
+	var out struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []sarifRule `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []sarifResult `json:"results"`
+		} `json:"runs"`
+	}
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &out))
+	assert.Equal(t, "2.1.0", out.Version)
+	assert.Len(t, out.Runs[0].Tool.Driver.Rules, 4)
+
+	results := out.Runs[0].Results
+	assert.Equal(t, "pass", results[0].Kind)
+	assert.Equal(t, "fail", results[1].Kind)
+	assert.Equal(t, "error", results[1].Level)
+	assert.Equal(t, "fail", results[2].Kind)
+	assert.Equal(t, "warning", results[2].Level)
+	assert.Equal(t, "notApplicable", results[3].Kind)
+}
+
+func TestRender_Markdown(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, Render(&b, "markdown", testResults()))
+
+	out := b.String()
+	assert.Contains(t, out, "## Access Security")
+	assert.Contains(t, out, "| ❌ Fail | Failing check | critical | port \\| open | 0ms | `uuid-fail` |")
+}
+
+func TestRender_TAP(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, Render(&b, "tap", testResults()))
+
+	lines := strings.Split(b.String(), "\n")
+	assert.Equal(t, "TAP version 13", lines[0])
+	assert.Equal(t, "1..4", lines[1])
+	assert.Equal(t, "ok 1 - Access Security: Passing check [uuid-pass]", lines[2])
+	assert.Equal(t, "not ok 2 - Access Security: Failing check [uuid-fail]", lines[3])
+	assert.Contains(t, b.String(), `  remediation: "close it"`)
+	assert.Contains(t, b.String(), `  message: "port | open"`)
+	assert.Contains(t, b.String(), "ok 4 - System Integrity: Skipped check [uuid-skip] # SKIP Disabled by exemption")
+}
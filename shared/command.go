@@ -1,11 +1,12 @@
 package shared
 
 import (
+	"context"
 	"errors"
-
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/caarlos0/log"
 )
@@ -21,13 +22,26 @@ type RunCommandMock struct {
 // RunCommandMocks is a slice that stores mock command outputs.
 var RunCommandMocks []RunCommandMock
 
+// commandWaitDelay bounds how long a command may keep its output pipes open
+// after it was killed because its context was done.
+const commandWaitDelay = 2 * time.Second
+
 // RunCommand executes a command with the given name and arguments, and returns
 // the combined standard output and standard error as a string. If testing is
 // enabled, it returns a predefined fixture instead of executing the command.
 func RunCommand(name string, arg ...string) (string, error) {
+	return RunCommandContext(context.Background(), name, arg...)
+}
+
+// RunCommandContext is like RunCommand, but kills the command when ctx is
+// done before the command exits.
+func RunCommandContext(ctx context.Context, name string, arg ...string) (string, error) {
 
 	// Check if testing is enabled and enable harnessing
 	if testing.Testing() {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
 		for _, mock := range RunCommandMocks {
 			isCmd := mock.Command == name
 			isArg := strings.TrimSpace(strings.Join(mock.Args, " ")) == strings.TrimSpace(strings.Join(arg, " "))
@@ -38,8 +52,12 @@ func RunCommand(name string, arg ...string) (string, error) {
 		return "", errors.New("RunCommand fixture not found: " + name + " " + strings.TrimSpace(strings.Join(arg, " ")))
 	}
 
-	cmd := exec.Command(name, arg...)
+	cmd := exec.CommandContext(ctx, name, arg...)
+	cmd.WaitDelay = commandWaitDelay
 	output, err := cmd.CombinedOutput()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
 	log.WithField("cmd", string(name+" "+strings.TrimSpace(strings.Join(arg, " ")))).WithError(err).Debug(string(output))
 	return string(output), err
 }
@@ -0,0 +1,22 @@
+package shared
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommandContext_Cancelled(t *testing.T) {
+	RunCommandMocks = []RunCommandMock{{Command: "echo", Args: []string{"hi"}, Out: "hi"}}
+	defer func() { RunCommandMocks = nil }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := RunCommandContext(ctx, "echo", "hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", out)
+
+	cancel()
+	_, err = RunCommandContext(ctx, "echo", "hi")
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -1,8 +1,12 @@
 package shared
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 
 	"github.com/caarlos0/log"
 	"github.com/pelletier/go-toml"
@@ -11,13 +15,46 @@ import (
 var Config ParetoConfig
 var configPath string
 
+// configOwner is the user the config is written for when root links the
+// device on their behalf, see UseConfigOf. It is nil when the config belongs
+// to the user running the agent.
+var configOwner *user.User
+
+// CheckStatus holds the user's settings for a single check, such as its
+// exemption and timeout.
 type CheckStatus struct {
-	Disabled bool
+	Disabled      bool
+	Justification string `toml:",omitempty"`
+	// Expires is the date (YYYY-MM-DD) or RFC3339 time after which the
+	// exemption no longer applies. An empty value never expires.
+	Expires string `toml:",omitempty"`
+	// Timeout is the duration (such as "45s") after which a run of the
+	// check is abandoned. An empty value uses DefaultCheckTimeout.
+	Timeout string `toml:",omitempty"`
+}
+
+// Profile selects the checks that have any of its tags or UUIDs.
+type Profile struct {
+	Description string   `toml:",omitempty"`
+	Tags        []string `toml:",omitempty"`
+	Checks      []string `toml:",omitempty"`
 }
 
 type ParetoConfig struct {
 	TeamID    string
 	AuthToken string
+	Checks    map[string]CheckStatus `toml:",omitempty"`
+	// DeviceID is the ID the device is known by, chosen once by SystemUUID.
+	DeviceID string `toml:",omitempty"`
+	// Profile is the name of the profile used when none is given on the
+	// command line. An empty value runs all checks.
+	Profile  string             `toml:",omitempty"`
+	Profiles map[string]Profile `toml:",omitempty"`
+	// Notifications configures the notifications about checks that
+	// started failing.
+	Notifications *NotificationSettings `toml:",omitempty"`
+	// Endpoints configures a self-hosted dashboard and how it is reached.
+	Endpoints *EndpointSettings `toml:",omitempty"`
 }
 
 func init() {
@@ -28,10 +65,18 @@ func init() {
 		homeDir = "."
 	}
 	configPath = filepath.Join(homeDir, ".config", "pareto.toml")
+	DeviceKeyPath = filepath.Join(homeDir, ".config", "pareto-device.key")
 	log.Debugf("configPath: %s", configPath)
 }
 
 func SaveConfig() error {
+	if configOwner != nil {
+		var content bytes.Buffer
+		if err := toml.NewEncoder(&content).Encode(Config); err != nil {
+			return err
+		}
+		return writeConfigFile(configPath, content.Bytes(), 0o644)
+	}
 
 	file, err := os.Create(configPath)
 	if err != nil {
@@ -40,23 +85,101 @@ func SaveConfig() error {
 	defer file.Close()
 
 	encoder := toml.NewEncoder(file)
-	return encoder.Encode(Config)
+	if err := encoder.Encode(Config); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UseConfigOf points the config and the device key at the files of the user
+// with the given name and loads their config, so that root can link the
+// device on their behalf. The files written afterwards are owned by that
+// user, and the device key is kept in a file, as their keyring cannot be
+// reached from root. The files are only read and written in a ~/.config
+// directory owned by that user, and never through symlinks.
+func UseConfigOf(name string) error {
+	owner, err := user.Lookup(name)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := ownerIDs(owner)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(owner.HomeDir, ".config")
+	if err := os.Mkdir(dir, 0o755); err == nil {
+		if err := os.Lchown(dir, uid, gid); err != nil {
+			return err
+		}
+	} else if !os.IsExist(err) {
+		return err
+	}
+	if err := checkOwnedDir(dir, uid); err != nil {
+		return err
+	}
+
+	configOwner = owner
+	configPath = filepath.Join(dir, "pareto.toml")
+	DeviceKeyPath = filepath.Join(dir, "pareto-device.key")
+	Config = ParetoConfig{}
+	return LoadConfig()
+}
+
+// readConfigFile reads a file next to the config, only from a directory
+// owned by the user set by UseConfigOf, if any.
+func readConfigFile(path string) ([]byte, error) {
+	if configOwner == nil {
+		return os.ReadFile(path)
+	}
+	uid, _, err := ownerIDs(configOwner)
+	if err != nil {
+		return nil, err
+	}
+	return readOwnedFile(path, uid)
+}
+
+// writeConfigFile atomically replaces a file next to the config with
+// content readable only by the user running the agent, or owned by the user
+// set by UseConfigOf, if any.
+func writeConfigFile(path string, content []byte, perm os.FileMode) error {
+	if configOwner == nil {
+		return WriteFileAtomic(path, content)
+	}
+	uid, gid, err := ownerIDs(configOwner)
+	if err != nil {
+		return err
+	}
+	return writeOwnedFile(path, content, perm, uid, gid)
+}
+
+// ownerIDs returns the numeric user and group IDs of owner, which only
+// exist on Unix.
+func ownerIDs(owner *user.User) (int, int, error) {
+	uid, err := strconv.Atoi(owner.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot write the config of %s on this platform", owner.Username)
+	}
+	gid, err := strconv.Atoi(owner.Gid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot write the config of %s on this platform", owner.Username)
+	}
+	return uid, gid, nil
 }
 
 func LoadConfig() error {
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+	content, err := readConfigFile(configPath)
+	if os.IsNotExist(err) {
 		if err := SaveConfig(); err != nil {
 			return err
 		}
 		return nil
 	}
-	file, err := os.Open(configPath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	decoder := toml.NewDecoder(file)
+	decoder := toml.NewDecoder(bytes.NewReader(content))
 	err = decoder.Decode(&Config)
 	if err != nil {
 		return err
@@ -0,0 +1,126 @@
+//go:build linux
+// +build linux
+
+package shared
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// openOwnedDir opens dir without following symlinks and returns its file
+// descriptor, or an error unless it is a directory owned by uid that other
+// users cannot modify.
+func openOwnedDir(dir string, uid int) (int, error) {
+	fd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Fstat(fd, &stat); err != nil {
+		syscall.Close(fd)
+		return -1, &os.PathError{Op: "stat", Path: dir, Err: err}
+	}
+	if int(stat.Uid) != uid {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("%s is not owned by user %d", dir, uid)
+	}
+	if stat.Mode&0o022 != 0 {
+		syscall.Close(fd)
+		return -1, fmt.Errorf("%s is writable by other users", dir)
+	}
+	return fd, nil
+}
+
+// checkOwnedDir returns an error unless dir is a directory owned by uid that
+// other users cannot modify.
+func checkOwnedDir(dir string, uid int) error {
+	fd, err := openOwnedDir(dir, uid)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+// readOwnedFile reads the regular file at path, which must be in a
+// directory owned by uid, without following symlinks.
+func readOwnedFile(path string, uid int) ([]byte, error) {
+	dirfd, err := openOwnedDir(filepath.Dir(path), uid)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(dirfd)
+
+	// O_NONBLOCK keeps a FIFO planted at path from blocking the open
+	fd, err := syscall.Openat(dirfd, filepath.Base(path), syscall.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	file := os.NewFile(uintptr(fd), path)
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	return io.ReadAll(file)
+}
+
+// writeOwnedFile atomically replaces the file at path, which must be in a
+// directory owned by uid, with content owned by uid and gid. The file is
+// written to a new temporary file that is renamed over path, so that a
+// symlink at path is replaced rather than followed.
+func writeOwnedFile(path string, content []byte, perm os.FileMode, uid, gid int) error {
+	dirfd, err := openOwnedDir(filepath.Dir(path), uid)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(dirfd)
+
+	tmp := filepath.Base(path) + "." + strconv.FormatUint(rand.Uint64(), 36) + ".tmp"
+	fd, err := syscall.Openat(dirfd, tmp, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_EXCL|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, uint32(perm.Perm()))
+	if err != nil {
+		return &os.PathError{Op: "create", Path: filepath.Join(filepath.Dir(path), tmp), Err: err}
+	}
+	file := os.NewFile(uintptr(fd), filepath.Join(filepath.Dir(path), tmp))
+	renamed := false
+	defer func() {
+		if !renamed {
+			_ = syscall.Unlinkat(dirfd, tmp)
+		}
+	}()
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	// Chown and Chmod act on the open file, not on whatever path points at
+	if err := file.Chown(uid, gid); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := syscall.Renameat(dirfd, tmp, dirfd, filepath.Base(path)); err != nil {
+		return &os.LinkError{Op: "rename", Old: tmp, New: path, Err: err}
+	}
+	renamed = true
+	_ = syscall.Fsync(dirfd)
+	return nil
+}
@@ -0,0 +1,79 @@
+//go:build linux
+// +build linux
+
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ownedTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	assert.NoError(t, os.Chmod(dir, 0o755))
+	return dir
+}
+
+func TestWriteOwnedFile_ReplacesSymlink(t *testing.T) {
+	dir := ownedTempDir(t)
+	target := filepath.Join(t.TempDir(), "shadow")
+	assert.NoError(t, os.WriteFile(target, []byte("secret"), 0o600))
+	path := filepath.Join(dir, "pareto.toml")
+	assert.NoError(t, os.Symlink(target, path))
+
+	err := writeOwnedFile(path, []byte("TeamID = \"team\"\n"), 0o644, os.Getuid(), os.Getgid())
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", string(content), "the symlink target must not be written")
+	info, err := os.Lstat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+	assert.Equal(t, uint32(os.Getuid()), info.Sys().(*syscall.Stat_t).Uid)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
+
+func TestReadOwnedFile(t *testing.T) {
+	dir := ownedTempDir(t)
+	path := filepath.Join(dir, "pareto.toml")
+	assert.NoError(t, os.WriteFile(path, []byte("TeamID = \"team\"\n"), 0o644))
+
+	content, err := readOwnedFile(path, os.Getuid())
+	assert.NoError(t, err)
+	assert.Equal(t, "TeamID = \"team\"\n", string(content))
+
+	_, err = readOwnedFile(filepath.Join(dir, "missing"), os.Getuid())
+	assert.True(t, os.IsNotExist(err))
+
+	link := filepath.Join(dir, "link")
+	assert.NoError(t, os.Symlink(path, link))
+	_, err = readOwnedFile(link, os.Getuid())
+	assert.Error(t, err, "symlinks are not followed")
+}
+
+func TestOwnedDir_Refused(t *testing.T) {
+	dir := ownedTempDir(t)
+	path := filepath.Join(dir, "pareto.toml")
+
+	assert.ErrorContains(t, checkOwnedDir(dir, os.Getuid()+1), "is not owned by")
+	assert.Error(t, writeOwnedFile(path, []byte("x"), 0o644, os.Getuid()+1, os.Getgid()))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, os.Chmod(dir, 0o777))
+	assert.ErrorContains(t, checkOwnedDir(dir, os.Getuid()), "writable by other users")
+
+	link := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.Symlink(ownedTempDir(t), link))
+	assert.Error(t, checkOwnedDir(link, os.Getuid()), "a symlinked directory is refused")
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package shared
+
+import (
+	"errors"
+	"os"
+)
+
+// errOwnedFiles is returned when root writes the config of another user,
+// which is only supported on Linux.
+var errOwnedFiles = errors.New("writing the config of another user is not supported on this platform")
+
+func checkOwnedDir(dir string, uid int) error {
+	return errOwnedFiles
+}
+
+func readOwnedFile(path string, uid int) ([]byte, error) {
+	return nil, errOwnedFiles
+}
+
+func writeOwnedFile(path string, content []byte, perm os.FileMode, uid, gid int) error {
+	return errOwnedFiles
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/pelletier/go-toml"
@@ -24,6 +25,17 @@ func TestSaveConfig_Success(t *testing.T) {
 	Config = ParetoConfig{
 		TeamID:    "team1",
 		AuthToken: "token1",
+		Checks: map[string]CheckStatus{
+			"2e46c89a-5461-4865-a92e-3b799c12034a": {
+				Disabled:      true,
+				Justification: "managed by the network firewall",
+				Expires:       "2030-01-01",
+			},
+		},
+		Profile: "kiosk",
+		Profiles: map[string]Profile{
+			"kiosk": {Description: "Public kiosks", Tags: []string{"network"}, Checks: []string{"37dee029-605b-4aab-96b9-5438e5aa44d8"}},
+		},
 	}
 
 	// Call SaveConfig.
@@ -50,6 +62,12 @@ func TestSaveConfig_Success(t *testing.T) {
 	if loadedConfig.AuthToken != Config.AuthToken {
 		t.Errorf("expected AuthToken %q, got %q", Config.AuthToken, loadedConfig.AuthToken)
 	}
+	if !reflect.DeepEqual(loadedConfig.Checks, Config.Checks) {
+		t.Errorf("expected Checks %+v, got %+v", Config.Checks, loadedConfig.Checks)
+	}
+	if loadedConfig.Profile != Config.Profile || !reflect.DeepEqual(loadedConfig.Profiles, Config.Profiles) {
+		t.Errorf("expected Profiles %+v, got %+v", Config.Profiles, loadedConfig.Profiles)
+	}
 
 }
 
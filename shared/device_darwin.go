@@ -10,4 +10,7 @@ type ReportingDevice struct {
 	OSVersion   string `json:"macOSVersion"` // e.g. Ubuntu 20.04
 	ModelName   string `json:"modelName"`    // e.g. MacBook Pro
 	ModelSerial string `json:"modelSerial"`  // e.g. C02C1234
+	// PublicKey is the base64 Ed25519 key the device signs its reports with,
+	// registered when the device is linked.
+	PublicKey string `json:"publicKey,omitempty"`
 }
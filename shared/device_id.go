@@ -0,0 +1,146 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caarlos0/log"
+	"github.com/google/uuid"
+)
+
+// The device ID is what the team dashboard tells devices apart by, so it must
+// not change while the device stays the same. It is chosen once, from the
+// first of these sources that is available, and kept in the config as
+// DeviceID:
+//
+//  1. the ID a device linked to a team is already known by, which is derived
+//     from the MAC address of its first network interface, so that linked
+//     devices do not show up twice on the dashboard;
+//  2. /etc/machine-id;
+//  3. the DMI product UUID, which only root can read and is thus read by
+//     the root helper;
+//  4. a random UUID.
+//
+// Machine and product IDs are hashed, so that the ID cannot be traced back to
+// them.
+const (
+	machineIDPath   = "/etc/machine-id"
+	productUUIDPath = "/sys/devices/virtual/dmi/id/product_uuid"
+)
+
+// deviceIDMu serializes choosing the device ID.
+var deviceIDMu sync.Mutex
+
+var machineIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// SystemUUID returns the ID of the device, choosing and saving it to the
+// config if it has none yet.
+func SystemUUID() (string, error) {
+	deviceIDMu.Lock()
+	defer deviceIDMu.Unlock()
+	if Config.DeviceID != "" {
+		return Config.DeviceID, nil
+	}
+
+	id, source := chooseDeviceID()
+	log.WithField("id", id).WithField("source", source).Info("Chose the device ID")
+	Config.DeviceID = id
+	if !testing.Testing() {
+		if err := SaveConfig(); err != nil {
+			log.WithError(err).Warn("Failed to save the device ID, it will be chosen again")
+		}
+	}
+	return id, nil
+}
+
+// chooseDeviceID returns the ID from the first available source and the name
+// of that source.
+func chooseDeviceID() (string, string) {
+	if Config.TeamID != "" {
+		if id, err := interfaceDeviceID(); err == nil {
+			return id, "network interface"
+		}
+	}
+	if id, err := ReadFile(machineIDPath); err == nil {
+		if id := strings.TrimSpace(string(id)); machineIDPattern.MatchString(id) {
+			return hashedDeviceID("machine-id", id), "machine-id"
+		}
+	}
+	id, err := readProductUUID()
+	if err == nil {
+		return hashedDeviceID("product_uuid", id), "product_uuid"
+	}
+	log.WithError(err).Debug("Failed to read the product UUID")
+	return uuid.NewString(), "generated"
+}
+
+// hashedDeviceID derives a device ID from id, read from source.
+func hashedDeviceID(source, id string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte("paretosecurity:"+source+":"+id)).String()
+}
+
+// readProductUUID returns the DMI product UUID, asking the root helper for
+// it unless the agent runs as root.
+func readProductUUID() (string, error) {
+	if IsRoot() {
+		return ProductUUID()
+	}
+	if runtime.GOOS != "linux" {
+		return "", errors.New("the product UUID is only read on Linux")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return ProductUUIDViaHelper(ctx)
+}
+
+// ProductUUID reads the DMI product UUID. Placeholders that firmware fills in
+// instead of a real UUID are rejected.
+func ProductUUID() (string, error) {
+	content, err := ReadFile(productUUIDPath)
+	if err != nil {
+		return "", err
+	}
+	id, err := uuid.Parse(strings.TrimSpace(string(content)))
+	if err != nil {
+		return "", fmt.Errorf("invalid product UUID: %w", err)
+	}
+	if id == uuid.Nil || id == uuid.Max {
+		return "", errors.New("the product UUID is not set")
+	}
+	return id.String(), nil
+}
+
+// interfaceDeviceID returns the ID derived from the MAC address of the first
+// network interface, which devices were known by before the ID was kept in
+// the config. It changes with the order of the interfaces.
+func interfaceDeviceID() (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	for _, iface := range interfaces {
+
+		// Skip loopback interfaces
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		if len(iface.HardwareAddr) >= 6 {
+			hwAddr := iface.HardwareAddr
+			// Create a namespace UUID from hardware address
+			nsUUID := uuid.NewSHA1(uuid.NameSpaceOID, hwAddr)
+			return nsUUID.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no network interface found")
+}
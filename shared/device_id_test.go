@@ -0,0 +1,90 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func resetDeviceID(t *testing.T) {
+	config := Config
+	Config = ParetoConfig{}
+	mocks := ReadFileMocks
+	t.Cleanup(func() {
+		Config = config
+		ReadFileMocks = mocks
+	})
+}
+
+func TestSystemUUID_Precedence(t *testing.T) {
+	resetDeviceID(t)
+	ReadFileMocks = map[string]string{
+		machineIDPath:   "0123456789abcdef0123456789abcdef\n",
+		productUUIDPath: "4c4c4544-0042-3510-8052-b4c04f384d32\n",
+	}
+
+	// The machine ID wins over the product UUID and is not sent as is
+	id, err := SystemUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, hashedDeviceID("machine-id", "0123456789abcdef0123456789abcdef"), id)
+	assert.NotContains(t, id, "0123456789abcdef")
+	assert.Equal(t, id, Config.DeviceID)
+
+	// Once chosen, the ID does not change with its sources
+	ReadFileMocks = map[string]string{}
+	again, err := SystemUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+
+	// The product UUID is used without a machine ID
+	Config.DeviceID = ""
+	ReadFileMocks = map[string]string{
+		machineIDPath:   "uninitialized\n",
+		productUUIDPath: "4C4C4544-0042-3510-8052-B4C04F384D32\n",
+	}
+	id, err = SystemUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, hashedDeviceID("product_uuid", "4c4c4544-0042-3510-8052-b4c04f384d32"), id)
+
+	// Placeholder product UUIDs fall back to a generated ID
+	Config.DeviceID = ""
+	ReadFileMocks = map[string]string{
+		productUUIDPath: "00000000-0000-0000-0000-000000000000",
+	}
+	id, err = SystemUUID()
+	assert.NoError(t, err)
+	_, err = uuid.Parse(id)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hashedDeviceID("product_uuid", uuid.Nil.String()), id)
+}
+
+func TestSystemUUID_LinkedDevice(t *testing.T) {
+	resetDeviceID(t)
+	legacy, err := interfaceDeviceID()
+	if err != nil {
+		t.Skip("no network interface with a hardware address")
+	}
+	ReadFileMocks = map[string]string{
+		machineIDPath: "0123456789abcdef0123456789abcdef",
+	}
+
+	// Devices linked before the ID was kept keep the ID the team knows
+	Config.TeamID = "team"
+	id, err := SystemUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, legacy, id)
+}
+
+func TestProductUUID(t *testing.T) {
+	resetDeviceID(t)
+	ReadFileMocks = map[string]string{
+		productUUIDPath: "not a uuid",
+	}
+	_, err := ProductUUID()
+	assert.Error(t, err)
+
+	ReadFileMocks[productUUIDPath] = "ffffffff-ffff-ffff-ffff-ffffffffffff\n"
+	_, err = ProductUUID()
+	assert.EqualError(t, err, "the product UUID is not set")
+}
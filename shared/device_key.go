@@ -0,0 +1,107 @@
+package shared
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/caarlos0/log"
+)
+
+// DeviceKeyPath is the file the device key is kept in when the Secret
+// Service keyring is not available.
+var DeviceKeyPath string
+
+// ErrNoDeviceKey is returned when the device has no key, such as when it was
+// linked before reports were signed.
+var ErrNoDeviceKey = errors.New("no device key, link the device again to sign reports")
+
+// ErrDeviceKeyLocked is returned when the device key is in a keyring that is
+// locked, until the user unlocks it.
+var ErrDeviceKeyLocked = errors.New("the keyring holding the device key is locked")
+
+// errNoKeyring is returned by the keyring functions when there is no Secret
+// Service to keep the key in.
+var errNoKeyring = errors.New("secret service keyring is not available")
+
+// GenerateDeviceKey creates the key the device signs its reports with,
+// replacing any previous one, and returns its public half. The key is kept
+// in the Secret Service keyring if available, or else in DeviceKeyPath,
+// readable only by the user. The keyring is not used when root links the
+// device for another user.
+func GenerateDeviceKey() (ed25519.PublicKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	err = errNoKeyring
+	if configOwner == nil {
+		err = keyringStore(encoded)
+	}
+	if err == nil {
+		// A key left over in the file would shadow the new one
+		if err := os.Remove(DeviceKeyPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		log.Debug("Stored the device key in the keyring")
+		return public, nil
+	}
+	if !errors.Is(err, errNoKeyring) {
+		log.WithError(err).Warn("Failed to store the device key in the keyring, using a file instead")
+	}
+	if err := writeConfigFile(DeviceKeyPath, encoded, 0o600); err != nil {
+		return nil, err
+	}
+	return public, nil
+}
+
+// LoadDeviceKey returns the key the device signs its reports with, or
+// ErrNoDeviceKey if there is none.
+func LoadDeviceKey() (ed25519.PrivateKey, error) {
+	encoded, err := readConfigFile(DeviceKeyPath)
+	if os.IsNotExist(err) {
+		encoded, err = keyringLoad()
+		if errors.Is(err, errNoKeyring) || errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoDeviceKey
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, errors.New("invalid device key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("device key is a %T, not an Ed25519 key", key)
+	}
+	return private, nil
+}
+
+// DeleteDeviceKey removes the device key, such as when the device is
+// unlinked.
+func DeleteDeviceKey() error {
+	if err := os.Remove(DeviceKeyPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := keyringDelete(); err != nil && !errors.Is(err, errNoKeyring) {
+		return err
+	}
+	return nil
+}
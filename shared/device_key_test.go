@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceKey(t *testing.T) {
+	DeviceKeyPath = filepath.Join(t.TempDir(), "device.key")
+
+	_, err := LoadDeviceKey()
+	assert.ErrorIs(t, err, ErrNoDeviceKey)
+
+	public, err := GenerateDeviceKey()
+	assert.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(DeviceKeyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+	private, err := LoadDeviceKey()
+	assert.NoError(t, err)
+	assert.True(t, public.Equal(private.Public()))
+	assert.True(t, ed25519.Verify(public, []byte("report"), ed25519.Sign(private, []byte("report"))))
+
+	// Linking again replaces the key
+	replaced, err := GenerateDeviceKey()
+	assert.NoError(t, err)
+	assert.False(t, public.Equal(replaced))
+
+	assert.NoError(t, DeleteDeviceKey())
+	_, err = LoadDeviceKey()
+	assert.ErrorIs(t, err, ErrNoDeviceKey)
+	assert.NoError(t, DeleteDeviceKey())
+
+	assert.NoError(t, os.WriteFile(DeviceKeyPath, []byte("not a key"), 0o600))
+	_, err = LoadDeviceKey()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNoDeviceKey)
+}
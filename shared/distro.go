@@ -0,0 +1,52 @@
+package shared
+
+import (
+	"bufio"
+	"runtime"
+	"strings"
+
+	"github.com/ParetoSecurity/agent/check"
+)
+
+// DistroFamily returns the OS family used to pick remediation text, such as
+// debian or fedora. It falls back to check.FamilyDefault when the Linux
+// distribution cannot be recognized.
+func DistroFamily() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return check.FamilyMacOS
+	case "windows":
+		return check.FamilyWindows
+	}
+
+	content, err := ReadFile("/etc/os-release")
+	if err != nil {
+		return check.FamilyDefault
+	}
+
+	ids := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found || (key != "ID" && key != "ID_LIKE") {
+			continue
+		}
+		ids = append(ids, strings.Fields(strings.Trim(value, `"'`))...)
+	}
+
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return check.FamilyDebian
+		case "fedora", "rhel", "centos":
+			return check.FamilyFedora
+		case "arch":
+			return check.FamilyArch
+		case "suse", "opensuse":
+			return check.FamilySUSE
+		case "nixos":
+			return check.FamilyNixOS
+		}
+	}
+	return check.FamilyDefault
+}
@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package shared
+
+import (
+	"testing"
+
+	"github.com/ParetoSecurity/agent/check"
+)
+
+func TestDistroFamily(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		expected  string
+	}{
+		{
+			name:      "ubuntu",
+			osRelease: "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\n",
+			expected:  check.FamilyDebian,
+		},
+		{
+			name:      "rocky",
+			osRelease: "NAME=\"Rocky Linux\"\nID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n",
+			expected:  check.FamilyFedora,
+		},
+		{
+			name:      "arch",
+			osRelease: "NAME=\"Arch Linux\"\nID=arch\n",
+			expected:  check.FamilyArch,
+		},
+		{
+			name:      "nixos",
+			osRelease: "NAME=NixOS\nID=nixos\n",
+			expected:  check.FamilyNixOS,
+		},
+		{
+			name:      "unknown",
+			osRelease: "NAME=Other\nID=other\n",
+			expected:  check.FamilyDefault,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFileMocks = map[string]string{"/etc/os-release": tt.osRelease}
+			if got := DistroFamily(); got != tt.expected {
+				t.Errorf("DistroFamily() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("missing os-release", func(t *testing.T) {
+		ReadFileMocks = map[string]string{}
+		if got := DistroFamily(); got != check.FamilyDefault {
+			t.Errorf("DistroFamily() = %q, want %q", got, check.FamilyDefault)
+		}
+	})
+}
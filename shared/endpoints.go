@@ -0,0 +1,176 @@
+package shared
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const (
+	// DefaultReportURL is the base URL of the team dashboard.
+	DefaultReportURL = "https://dash.paretosecurity.com"
+	// DefaultUpdatesURL returns the latest release for linked devices.
+	DefaultUpdatesURL = "https://paretosecurity.com/api/updates"
+	// DefaultReleasesURL lists the releases for devices that are not linked.
+	DefaultReleasesURL = "https://api.github.com/repos/ParetoSecurity/agent/releases"
+	// DefaultLinkURL explains how to link a device to a team.
+	DefaultLinkURL = "https://paretosecurity.com/docs/linux/link"
+)
+
+// EndpointSettings points the agent at a self-hosted dashboard and
+// configures how it is reached, for networks without access to
+// paretosecurity.com.
+type EndpointSettings struct {
+	// Report is the base URL of the team dashboard devices are linked to
+	// and report to. An empty value uses DefaultReportURL.
+	Report string `toml:",omitempty"`
+	// Updates is the URL linked devices check for updates at. An empty
+	// value uses DefaultUpdatesURL.
+	Updates string `toml:",omitempty"`
+	// Releases is the URL devices that are not linked check for updates
+	// at. An empty value uses DefaultReleasesURL.
+	Releases string `toml:",omitempty"`
+	// Link is the page opened from the tray to link the device. An empty
+	// value uses DefaultLinkURL.
+	Link string `toml:",omitempty"`
+	// CABundle is a PEM file with certificate authorities trusted in
+	// addition to the system ones.
+	CABundle string `toml:",omitempty"`
+	// ClientCert and ClientKey are PEM files with the client certificate
+	// presented to endpoints that require mutual TLS.
+	ClientCert string `toml:",omitempty"`
+	ClientKey  string `toml:",omitempty"`
+	// Proxy is the URL of the HTTP proxy requests go through. An empty
+	// value uses the HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string `toml:",omitempty"`
+	// InviteKey is a PEM file with the public key of the dashboard that
+	// issues invites. Invites are then only accepted if they are signed with
+	// it.
+	InviteKey string `toml:",omitempty"`
+}
+
+// Endpoints returns the endpoint settings from pareto.toml.
+func Endpoints() EndpointSettings {
+	if Config.Endpoints == nil {
+		return EndpointSettings{}
+	}
+	return *Config.Endpoints
+}
+
+// ReportURL returns the configured dashboard URL or the default.
+func (e EndpointSettings) ReportURL() string {
+	return endpointOr(e.Report, DefaultReportURL)
+}
+
+// UpdatesURL returns the configured update URL or the default.
+func (e EndpointSettings) UpdatesURL() string {
+	return endpointOr(e.Updates, DefaultUpdatesURL)
+}
+
+// ReleasesURL returns the configured releases URL or the default.
+func (e EndpointSettings) ReleasesURL() string {
+	return endpointOr(e.Releases, DefaultReleasesURL)
+}
+
+// LinkURL returns the configured link page or the default.
+func (e EndpointSettings) LinkURL() string {
+	return endpointOr(e.Link, DefaultLinkURL)
+}
+
+func endpointOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// InvitePublicKey returns the public key in the InviteKey file, an RSA,
+// ECDSA or Ed25519 key in PKIX form.
+func (e EndpointSettings) InvitePublicKey() (crypto.PublicKey, error) {
+	content, err := os.ReadFile(e.InviteKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invite key: %w", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no public key found in invite key %s", e.InviteKey)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invite key %s: %w", e.InviteKey, err)
+	}
+	return key, nil
+}
+
+// ValidateEndpoint returns an error if value is not an absolute http or
+// https URL.
+func ValidateEndpoint(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("endpoint %q is not an http or https URL", value)
+	}
+	return nil
+}
+
+// ValidateSecureEndpoint returns an error if value is not an absolute https
+// URL, as required of endpoints the device is pointed at by an invite, which
+// are sent the team token.
+func ValidateSecureEndpoint(value string) error {
+	if err := ValidateEndpoint(value); err != nil {
+		return err
+	}
+	if parsed, _ := url.Parse(value); parsed.Scheme != "https" {
+		return fmt.Errorf("endpoint %q is not an https URL", value)
+	}
+	return nil
+}
+
+// HTTPClient returns the client for requests to the endpoints, using the CA
+// bundle, client certificate and proxy from the settings. Without any of
+// them it returns http.DefaultClient.
+func (e EndpointSettings) HTTPClient() (*http.Client, error) {
+	if e.CABundle == "" && e.ClientCert == "" && e.ClientKey == "" && e.Proxy == "" {
+		return http.DefaultClient, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if e.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		bundle, err := os.ReadFile(e.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", e.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if e.ClientCert != "" || e.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(e.ClientCert, e.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+	if e.Proxy != "" {
+		proxy, err := url.Parse(e.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &http.Client{Transport: transport}, nil
+}
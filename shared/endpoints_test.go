@@ -0,0 +1,138 @@
+package shared
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	stdlog "log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointsDefaults(t *testing.T) {
+	Config.Endpoints = nil
+	endpoints := Endpoints()
+	assert.Equal(t, DefaultReportURL, endpoints.ReportURL())
+	assert.Equal(t, DefaultUpdatesURL, endpoints.UpdatesURL())
+	assert.Equal(t, DefaultReleasesURL, endpoints.ReleasesURL())
+	assert.Equal(t, DefaultLinkURL, endpoints.LinkURL())
+
+	client, err := endpoints.HTTPClient()
+	assert.NoError(t, err)
+	assert.Same(t, http.DefaultClient, client)
+
+	Config.Endpoints = &EndpointSettings{Report: "https://dash.internal"}
+	defer func() { Config.Endpoints = nil }()
+	assert.Equal(t, "https://dash.internal", Endpoints().ReportURL())
+	assert.Equal(t, DefaultUpdatesURL, Endpoints().UpdatesURL())
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	assert.NoError(t, ValidateEndpoint("https://dash.internal"))
+	assert.NoError(t, ValidateEndpoint("http://127.0.0.1:8080"))
+	assert.Error(t, ValidateEndpoint("dash.internal"))
+	assert.Error(t, ValidateEndpoint("ftp://dash.internal"))
+	assert.Error(t, ValidateEndpoint("https://"))
+
+	assert.NoError(t, ValidateSecureEndpoint("https://dash.internal"))
+	assert.Error(t, ValidateSecureEndpoint("http://dash.internal"))
+	assert.Error(t, ValidateSecureEndpoint("https://"))
+}
+
+func writePEM(t *testing.T, name, kind string, content []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: content}), 0o600))
+	return path
+}
+
+func TestHTTPClientCABundle(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	_, err := http.DefaultClient.Get(server.URL)
+	assert.Error(t, err, "the test certificate is not trusted by default")
+
+	bundle := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	client, err := EndpointSettings{CABundle: bundle}.HTTPClient()
+	assert.NoError(t, err)
+	res, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	_, err = EndpointSettings{CABundle: filepath.Join(t.TempDir(), "missing.pem")}.HTTPClient()
+	assert.Error(t, err)
+	_, err = EndpointSettings{CABundle: writePEM(t, "empty.pem", "NOTHING", nil)}.HTTPClient()
+	assert.Error(t, err)
+}
+
+func TestHTTPClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "device"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	clientCAs.AddCert(cert)
+
+	// The server only accepts clients presenting the certificate
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ErrorLog = stdlog.New(io.Discard, "", 0)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	settings := EndpointSettings{CABundle: writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)}
+	client, err := settings.HTTPClient()
+	assert.NoError(t, err)
+	_, err = client.Get(server.URL)
+	assert.Error(t, err, "a client without the certificate is rejected")
+
+	settings.ClientCert = writePEM(t, "client.pem", "CERTIFICATE", der)
+	settings.ClientKey = writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+	client, err = settings.HTTPClient()
+	assert.NoError(t, err)
+	res, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	settings.ClientKey = ""
+	_, err = settings.HTTPClient()
+	assert.Error(t, err)
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	client, err := EndpointSettings{Proxy: "http://proxy.internal:3128"}.HTTPClient()
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodGet, "https://dash.internal", nil)
+	assert.NoError(t, err)
+	proxy, err := client.Transport.(*http.Transport).Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.internal:3128", proxy.Host)
+
+	_, err = EndpointSettings{Proxy: "http://[::1"}.HTTPClient()
+	assert.Error(t, err)
+}
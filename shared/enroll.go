@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pelletier/go-toml"
+)
+
+// EnrollPath is the root-owned file that provisions the invite a device is
+// linked with by `paretosecurity link --headless`, for example:
+//
+//	Token = "eyJhbGciOi..."
+//
+// It lets configuration management link devices without passing the token
+// on the command line.
+var EnrollPath = "/etc/paretosecurity/enroll.toml"
+
+// EnrollSettings holds the invite provisioned in EnrollPath.
+type EnrollSettings struct {
+	Token string
+}
+
+// LoadEnrollSettings reads the invite from EnrollPath. It returns nil if the
+// file does not exist, and an error if it can be modified by anyone but
+// root, as anyone able to replace it could link the device to their team.
+func LoadEnrollSettings() (*EnrollSettings, error) {
+	info, err := os.Stat(EnrollPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := isRootOwned(info); err != nil {
+		return nil, fmt.Errorf("refusing to use %s: %w", EnrollPath, err)
+	}
+
+	content, err := os.ReadFile(EnrollPath)
+	if err != nil {
+		return nil, err
+	}
+	settings := &EnrollSettings{}
+	if err := toml.Unmarshal(content, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", EnrollPath, err)
+	}
+	return settings, nil
+}
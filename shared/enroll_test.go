@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEnrollSettings(t *testing.T) {
+	path := EnrollPath
+	defer func() { EnrollPath = path }()
+
+	EnrollPath = filepath.Join(t.TempDir(), "enroll.toml")
+	settings, err := LoadEnrollSettings()
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+
+	assert.NoError(t, os.WriteFile(EnrollPath, []byte(`Token = "invite"`), 0o600))
+	settings, err = LoadEnrollSettings()
+	if os.Getuid() == 0 {
+		assert.NoError(t, err)
+		assert.Equal(t, "invite", settings.Token)
+	} else {
+		assert.Error(t, err)
+	}
+
+	// A file anyone can write to is never trusted
+	assert.NoError(t, os.Chmod(EnrollPath, 0o666))
+	_, err = LoadEnrollSettings()
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/caarlos0/log"
+)
+
+// ExpiresAt parses the expiry of the exemption. The second return value is
+// false if the exemption does not expire.
+func (c CheckStatus) ExpiresAt() (time.Time, bool, error) {
+	if c.Expires == "" {
+		return time.Time{}, false, nil
+	}
+	if date, err := time.ParseInLocation(time.DateOnly, c.Expires, time.Local); err == nil {
+		// A date-only expiry is valid until the end of that day.
+		return date.AddDate(0, 0, 1), true, nil
+	}
+	at, err := time.Parse(time.RFC3339, c.Expires)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid expiry %q, use YYYY-MM-DD", c.Expires)
+	}
+	return at, true, nil
+}
+
+// IsExpired returns true if the exemption has an expiry that is not after now.
+// Exemptions with an invalid expiry do not expire, as that is a mistake in
+// the config for ValidateExemptions to report.
+func (c CheckStatus) IsExpired(now time.Time) bool {
+	at, expires, err := c.ExpiresAt()
+	return err == nil && expires && !now.Before(at)
+}
+
+// IsActive returns true if the exemption disables the check at now.
+// Exemptions with an invalid expiry are ignored until it is fixed.
+func (c CheckStatus) IsActive(now time.Time) bool {
+	if _, _, err := c.ExpiresAt(); err != nil {
+		return false
+	}
+	return c.Disabled && !c.IsExpired(now)
+}
+
+// Reason returns a human readable description of the exemption.
+func (c CheckStatus) Reason() string {
+	reason := "Disabled by exemption"
+	if c.Justification != "" {
+		reason += ": " + c.Justification
+	}
+	if c.Expires != "" {
+		reason += fmt.Sprintf(" (expires %s)", c.Expires)
+	}
+	return reason
+}
+
+// GetExemption returns the active exemption for the check with the given
+// UUID, if there is one.
+func GetExemption(uuid string) (CheckStatus, bool) {
+	status, ok := Config.Checks[uuid]
+	if !ok || !status.IsActive(time.Now()) {
+		return CheckStatus{}, false
+	}
+	return status, true
+}
+
+// IsCheckExempted returns true if the check with the given UUID is disabled
+// by an active exemption in the config.
+func IsCheckExempted(uuid string) bool {
+	_, ok := GetExemption(uuid)
+	return ok
+}
+
+// ValidateExemptions returns an error naming each exemption in the config
+// with an invalid expiry, or nil if there are none.
+func ValidateExemptions() error {
+	var errs []error
+	for _, uuid := range slices.Sorted(maps.Keys(Config.Checks)) {
+		status := Config.Checks[uuid]
+		if _, _, err := status.ExpiresAt(); status.Disabled && err != nil {
+			errs = append(errs, fmt.Errorf("exemption of check %s: %w", uuid, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ExpireExemptions turns exemptions that expired before now back into normal
+// checks and returns the UUIDs of the checks that were affected. The caller
+// is responsible for saving the config.
+func ExpireExemptions(now time.Time) []string {
+	expired := []string{}
+	for uuid, status := range Config.Checks {
+		if status.Disabled && status.IsExpired(now) {
+			log.WithField("uuid", uuid).
+				WithField("expires", status.Expires).
+				Info("Exemption expired, check is enabled again")
+			status.Disabled = false
+			Config.Checks[uuid] = status
+			expired = append(expired, uuid)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,95 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckStatus_IsExpired(t *testing.T) {
+	// Dates are parsed in the local time zone
+	now := time.Date(2025, 3, 15, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		expires  string
+		expected bool
+	}{
+		{name: "no expiry", expires: "", expected: false},
+		{name: "date in the future", expires: "2025-03-16", expected: false},
+		{name: "expires at the end of today", expires: "2025-03-15", expected: false},
+		{name: "date in the past", expires: "2025-03-14", expected: true},
+		{name: "RFC3339 in the past", expires: now.Add(-time.Hour).Format(time.RFC3339), expected: true},
+		{name: "RFC3339 in the future", expires: now.Add(time.Hour).Format(time.RFC3339), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := CheckStatus{Disabled: true, Expires: tt.expires}
+			assert.Equal(t, tt.expected, status.IsExpired(now))
+			assert.Equal(t, !tt.expected, status.IsActive(now))
+		})
+	}
+
+	// An invalid expiry neither expires nor applies the exemption
+	invalid := CheckStatus{Disabled: true, Expires: "next week"}
+	assert.False(t, invalid.IsExpired(now))
+	assert.False(t, invalid.IsActive(now))
+}
+
+func TestValidateExemptions(t *testing.T) {
+	Config.Checks = map[string]CheckStatus{
+		"valid":   {Disabled: true, Expires: "2999-01-01"},
+		"invalid": {Disabled: true, Expires: "next week"},
+		"enabled": {Disabled: false, Expires: "someday"},
+	}
+	defer func() { Config.Checks = nil }()
+
+	err := ValidateExemptions()
+	assert.ErrorContains(t, err, "exemption of check invalid")
+	assert.NotContains(t, err.Error(), "enabled")
+
+	// Invalid exemptions are left for the user to fix
+	assert.Empty(t, ExpireExemptions(time.Now()))
+	assert.True(t, Config.Checks["invalid"].Disabled)
+
+	Config.Checks = map[string]CheckStatus{"valid": {Disabled: true}}
+	assert.NoError(t, ValidateExemptions())
+}
+
+func TestGetExemption(t *testing.T) {
+	Config.Checks = map[string]CheckStatus{
+		"active":   {Disabled: true, Justification: "managed firewall"},
+		"expired":  {Disabled: true, Expires: "2000-01-01"},
+		"enabled":  {Disabled: false, Justification: "not disabled"},
+		"upcoming": {Disabled: true, Expires: time.Now().AddDate(1, 0, 0).Format(time.DateOnly)},
+	}
+	defer func() { Config.Checks = nil }()
+
+	status, ok := GetExemption("active")
+	assert.True(t, ok)
+	assert.Equal(t, "Disabled by exemption: managed firewall", status.Reason())
+
+	assert.True(t, IsCheckExempted("upcoming"))
+	assert.False(t, IsCheckExempted("expired"))
+	assert.False(t, IsCheckExempted("enabled"))
+	assert.False(t, IsCheckExempted("missing"))
+}
+
+func TestExpireExemptions(t *testing.T) {
+	Config.Checks = map[string]CheckStatus{
+		"active":  {Disabled: true, Expires: "2999-01-01"},
+		"expired": {Disabled: true, Expires: "2000-01-01", Justification: "temporary"},
+	}
+	defer func() { Config.Checks = nil }()
+
+	expired := ExpireExemptions(time.Now())
+	assert.Equal(t, []string{"expired"}, expired)
+	assert.False(t, Config.Checks["expired"].Disabled)
+	assert.Equal(t, "temporary", Config.Checks["expired"].Justification)
+	assert.True(t, Config.Checks["active"].Disabled)
+
+	// Running again does not report the same exemption twice.
+	assert.Empty(t, ExpireExemptions(time.Now()))
+}
@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"slices"
+	"strconv"
+
+	"github.com/pelletier/go-toml"
+)
+
+// HelperAllowlistPath is the root-owned file listing who may use the root
+// helper, and who may have it apply fixes, for example:
+//
+//	Users = ["alice"]
+//	Groups = ["paretosecurity"]
+//	Admins = ["alice"]
+//	AdminGroups = ["wheel"]
+//
+// Entries are names or numeric IDs. Root is always allowed.
+var HelperAllowlistPath = "/etc/paretosecurity/helper.toml"
+
+// Regular users get UIDs in this range by default, see login.defs(5). Without
+// an allowlist, the helper serves root and these users, but not system
+// accounts such as nobody or the users of network services.
+const (
+	defaultMinUID = 1000
+	defaultMaxUID = 60000
+)
+
+// PeerCred identifies the process on the other end of a Unix socket.
+type PeerCred struct {
+	UID int
+	GID int
+	PID int
+	Exe string
+}
+
+// HelperAllowlist lists the users and groups allowed to use the root helper.
+// Fixes change the configuration of the system, so only root and the users
+// and groups listed as admins may apply them.
+type HelperAllowlist struct {
+	Users       []string
+	Groups      []string
+	Admins      []string `toml:",omitempty"`
+	AdminGroups []string `toml:",omitempty"`
+}
+
+// LoadHelperAllowlist reads the allowlist from HelperAllowlistPath. It returns
+// nil if the file does not exist, and an error if it can be modified by
+// anyone but root, as the helper must not trust such a file.
+func LoadHelperAllowlist() (*HelperAllowlist, error) {
+	info, err := os.Stat(HelperAllowlistPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := isRootOwned(info); err != nil {
+		return nil, fmt.Errorf("refusing to use %s: %w", HelperAllowlistPath, err)
+	}
+
+	content, err := os.ReadFile(HelperAllowlistPath)
+	if err != nil {
+		return nil, err
+	}
+	allowlist := &HelperAllowlist{}
+	if err := toml.Unmarshal(content, allowlist); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", HelperAllowlistPath, err)
+	}
+	return allowlist, nil
+}
+
+// AuthorizeHelperClient returns an error unless the caller described by cred
+// may use the root helper. A nil allowlist allows root and regular users.
+func AuthorizeHelperClient(cred PeerCred, allowlist *HelperAllowlist) error {
+	if cred.UID == 0 {
+		return nil
+	}
+	if allowlist == nil {
+		if cred.UID >= defaultMinUID && cred.UID < defaultMaxUID {
+			return nil
+		}
+		return fmt.Errorf("uid %d is not a regular user", cred.UID)
+	}
+
+	// Admins may use the helper without being listed twice
+	users := append(slices.Clone(allowlist.Users), allowlist.Admins...)
+	groups := append(slices.Clone(allowlist.Groups), allowlist.AdminGroups...)
+	if isListed(cred, users, groups) {
+		return nil
+	}
+	return fmt.Errorf("uid %d is not in %s", cred.UID, HelperAllowlistPath)
+}
+
+// AuthorizeHelperFix returns an error unless the caller described by cred
+// may have the root helper apply fixes. Without an allowlist, only root may.
+func AuthorizeHelperFix(cred PeerCred, allowlist *HelperAllowlist) error {
+	if cred.UID == 0 {
+		return nil
+	}
+	if allowlist != nil && isListed(cred, allowlist.Admins, allowlist.AdminGroups) {
+		return nil
+	}
+	return fmt.Errorf("uid %d is not an admin in %s", cred.UID, HelperAllowlistPath)
+}
+
+// isListed returns whether the caller described by cred is one of users or
+// a member of one of groups.
+func isListed(cred PeerCred, users, groups []string) bool {
+	uid := strconv.Itoa(cred.UID)
+	if slices.Contains(users, uid) {
+		return true
+	}
+	gids := []string{strconv.Itoa(cred.GID)}
+	if u, err := user.LookupId(uid); err == nil {
+		if slices.Contains(users, u.Username) {
+			return true
+		}
+		if groupIDs, err := u.GroupIds(); err == nil {
+			gids = append(gids, groupIDs...)
+		}
+	}
+	for _, gid := range gids {
+		if slices.Contains(groups, gid) {
+			return true
+		}
+		if g, err := user.LookupGroupId(gid); err == nil && slices.Contains(groups, g.Name) {
+			return true
+		}
+	}
+	return false
+}
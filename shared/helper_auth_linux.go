@@ -0,0 +1,59 @@
+//go:build linux
+// +build linux
+
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// PeerCredentials returns the credentials of the process on the other end of
+// conn, as reported by the kernel through SO_PEERCRED.
+func PeerCredentials(conn net.Conn) (PeerCred, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return PeerCred{}, errors.New("not a Unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return PeerCred{}, err
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCred{}, err
+	}
+	if credErr != nil {
+		return PeerCred{}, credErr
+	}
+
+	cred := PeerCred{UID: int(ucred.Uid), GID: int(ucred.Gid), PID: int(ucred.Pid)}
+	// Only used for logging, the process may already be gone
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", cred.PID)); err == nil {
+		cred.Exe = exe
+	}
+	return cred, nil
+}
+
+// isRootOwned returns an error unless the file is owned by root and cannot
+// be modified by other users.
+func isRootOwned(info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("file owner is not available")
+	}
+	if stat.Uid != 0 {
+		return fmt.Errorf("%s is not owned by root", info.Name())
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s is writable by other users", info.Name())
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package shared
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// PeerCredentials is only supported on Linux, where the root helper runs.
+func PeerCredentials(conn net.Conn) (PeerCred, error) {
+	return PeerCred{}, errors.New("peer credentials are not supported on this platform")
+}
+
+// isRootOwned is only supported on Linux, where the root helper runs.
+func isRootOwned(info os.FileInfo) error {
+	return errors.New("file owner is not available on this platform")
+}
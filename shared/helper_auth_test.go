@@ -0,0 +1,139 @@
+//go:build linux
+// +build linux
+
+package shared
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// socketpair returns both ends of a connected pair of Unix sockets.
+func socketpair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("failed to create socketpair: %v", err)
+	}
+	conns := make([]net.Conn, 2)
+	for i, fd := range fds {
+		file := os.NewFile(uintptr(fd), "socketpair")
+		conn, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("failed to create connection: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conns[i] = conn
+	}
+	return conns[0], conns[1]
+}
+
+func TestPeerCredentials_Socketpair(t *testing.T) {
+	server, _ := socketpair(t)
+
+	cred, err := PeerCredentials(server)
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getuid(), cred.UID)
+	assert.Equal(t, os.Getgid(), cred.GID)
+	assert.Equal(t, os.Getpid(), cred.PID)
+	exe, _ := os.Executable()
+	assert.Equal(t, exe, cred.Exe)
+
+	uid := strconv.Itoa(cred.UID)
+	gid := strconv.Itoa(cred.GID)
+	tests := []struct {
+		name      string
+		allowlist *HelperAllowlist
+		allowed   bool
+	}{
+		{"user in allowlist", &HelperAllowlist{Users: []string{uid}}, true},
+		{"group in allowlist", &HelperAllowlist{Groups: []string{gid}}, true},
+		{"empty allowlist", &HelperAllowlist{}, cred.UID == 0},
+		{"other users only", &HelperAllowlist{Users: []string{"nonexistent-user"}, Groups: []string{"nonexistent-group"}}, cred.UID == 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := AuthorizeHelperClient(cred, tt.allowlist)
+			assert.Equal(t, tt.allowed, err == nil, "error: %v", err)
+		})
+	}
+}
+
+func TestPeerCredentials_NotUnixSocket(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	_, err := PeerCredentials(server)
+	assert.Error(t, err)
+}
+
+func TestAuthorizeHelperClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		cred      PeerCred
+		allowlist *HelperAllowlist
+		allowed   bool
+	}{
+		{"root without allowlist", PeerCred{UID: 0}, nil, true},
+		{"root with empty allowlist", PeerCred{UID: 0}, &HelperAllowlist{}, true},
+		{"regular user without allowlist", PeerCred{UID: 1000, GID: 1000}, nil, true},
+		{"system user without allowlist", PeerCred{UID: 33, GID: 33}, nil, false},
+		{"nobody without allowlist", PeerCred{UID: 65534, GID: 65534}, nil, false},
+		{"regular user not in allowlist", PeerCred{UID: 1000, GID: 1000}, &HelperAllowlist{Users: []string{"1001"}}, false},
+		{"user ID in allowlist", PeerCred{UID: 1234, GID: 1234}, &HelperAllowlist{Users: []string{"1234"}}, true},
+		{"primary group ID in allowlist", PeerCred{UID: 1234, GID: 4321}, &HelperAllowlist{Groups: []string{"4321"}}, true},
+		{"system user in allowlist", PeerCred{UID: 33, GID: 33}, &HelperAllowlist{Users: []string{"33"}}, true},
+		{"admin", PeerCred{UID: 1234, GID: 1234}, &HelperAllowlist{Admins: []string{"1234"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := AuthorizeHelperClient(tt.cred, tt.allowlist)
+			assert.Equal(t, tt.allowed, err == nil, "error: %v", err)
+		})
+	}
+}
+
+func TestAuthorizeHelperFix(t *testing.T) {
+	tests := []struct {
+		name      string
+		cred      PeerCred
+		allowlist *HelperAllowlist
+		allowed   bool
+	}{
+		{"root without allowlist", PeerCred{UID: 0}, nil, true},
+		{"regular user without allowlist", PeerCred{UID: 1000, GID: 1000}, nil, false},
+		{"user allowed to run checks", PeerCred{UID: 1234, GID: 1234}, &HelperAllowlist{Users: []string{"1234"}}, false},
+		{"admin", PeerCred{UID: 1234, GID: 1234}, &HelperAllowlist{Admins: []string{"1234"}}, true},
+		{"admin group", PeerCred{UID: 1234, GID: 4321}, &HelperAllowlist{AdminGroups: []string{"4321"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := AuthorizeHelperFix(tt.cred, tt.allowlist)
+			assert.Equal(t, tt.allowed, err == nil, "error: %v", err)
+		})
+	}
+}
+
+func TestLoadHelperAllowlist(t *testing.T) {
+	path := HelperAllowlistPath
+	defer func() { HelperAllowlistPath = path }()
+
+	HelperAllowlistPath = filepath.Join(t.TempDir(), "helper.toml")
+	allowlist, err := LoadHelperAllowlist()
+	assert.NoError(t, err)
+	assert.Nil(t, allowlist)
+
+	// A file anyone can write to is never trusted
+	assert.NoError(t, os.WriteFile(HelperAllowlistPath, []byte(`Users = ["alice"]`), 0o644))
+	assert.NoError(t, os.Chmod(HelperAllowlistPath, 0o666))
+	_, err = LoadHelperAllowlist()
+	assert.Error(t, err)
+}
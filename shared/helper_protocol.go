@@ -0,0 +1,106 @@
+package shared
+
+import (
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+)
+
+// HelperProtocolVersion is the newest version of the root helper protocol.
+//
+// Version 1 is a single request, {"uuid": "..."}, answered with a map of the
+// UUID to whether the check passed.
+//
+// Since version 2, the client starts with a handshake, {"version": 2}, that
+// the helper answers with the version both sides speak. The client then
+// sends a HelperRequest for a batch of UUIDs and the helper answers with a
+// HelperResponse holding a HelperResult for each of them. Helpers that only
+// speak version 1 close the connection on the handshake, which tells the
+// client to fall back to one version 1 request per UUID.
+//
+// A HelperRequest with the product-uuid action carries no UUIDs and is
+// answered with a HelperResponse holding the DMI product UUID instead of
+// results. Helpers that predate it answer without one.
+//
+// Fixes are requested with the plan action first, whose results carry the
+// plan the helper would apply. The fix action then carries the plan the user
+// confirmed, and the helper only applies a fix whose plan is still the same.
+const HelperProtocolVersion = 2
+
+// HelperActionRun asks the root helper to run checks. It is the default.
+const HelperActionRun = "run"
+
+// HelperActionFix asks the root helper to fix a check instead of running it.
+const HelperActionFix = "fix"
+
+// HelperActionPlan asks the root helper to run checks and return the plans
+// of their fixes, which the user confirms before asking for the fix.
+const HelperActionPlan = "plan"
+
+// HelperActionProductUUID asks the root helper for the DMI product UUID,
+// which only root can read.
+const HelperActionProductUUID = "product-uuid"
+
+// HelperHello is the handshake sent by the client, and answered by the
+// helper with the protocol version the connection uses. The helper sets
+// Error instead if it refuses to serve the client.
+type HelperHello struct {
+	Version int    `json:"version"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HelperRequest asks the root helper to run or fix a batch of checks. With
+// Fresh set, the checks are run even if the helper has cached results. Plan
+// is the plan of the fix the user confirmed, the helper refuses the fix if
+// its own plan differs. Timeouts are the timeouts the user configured for
+// the checks, as the helper does not read the config of the user; checks
+// without one use DefaultCheckTimeout. Checks the user exempted are not sent
+// at all.
+type HelperRequest struct {
+	Action   string                   `json:"action,omitempty"`
+	UUIDs    []string                 `json:"uuids"`
+	Fresh    bool                     `json:"fresh,omitempty"`
+	Plan     []string                 `json:"plan,omitempty"`
+	Timeouts map[string]time.Duration `json:"timeouts,omitempty"`
+}
+
+// MaxHelperCheckTimeout bounds the timeouts a client may ask the root helper
+// to run a check for.
+const MaxHelperCheckTimeout = 10 * time.Minute
+
+// CheckTimeout returns the timeout the root helper runs the check with the
+// given UUID for, which is the one in Timeouts if it is positive and within
+// MaxHelperCheckTimeout, and DefaultCheckTimeout otherwise.
+func (req HelperRequest) CheckTimeout(uuid string) time.Duration {
+	timeout := req.Timeouts[uuid]
+	if timeout <= 0 || timeout > MaxHelperCheckTimeout {
+		return DefaultCheckTimeout
+	}
+	return timeout
+}
+
+// HelperResult is the result of a single check run by the root helper.
+// Error is set instead of a result when the check could not be run at all,
+// for example because its UUID is unknown or it is not runnable. Cached is
+// set when the result is an earlier run's, which AgeSeconds old. Signature
+// is the signature of the helper over the outcome at SignedAt, a Unix time.
+// Plan is set by the plan action to the steps the fix of a failing check
+// takes.
+type HelperResult struct {
+	check.Result
+	Plan       []string `json:"plan,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Cached     bool     `json:"cached,omitempty"`
+	AgeSeconds int64    `json:"ageSeconds,omitempty"`
+	SignedAt   int64    `json:"signedAt,omitempty"`
+	Signature  string   `json:"signature,omitempty"`
+}
+
+// HelperResponse holds the results of a HelperRequest, keyed by UUID. The
+// product-uuid action sets ProductUUID instead, or Error if it cannot be
+// read.
+type HelperResponse struct {
+	Results     map[string]HelperResult `json:"results"`
+	ProductUUID string                  `json:"productUUID,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
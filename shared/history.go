@@ -0,0 +1,307 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/caarlos0/log"
+)
+
+// HistoryPath returns the JSON lines file that check results are appended
+// to, which is kept next to the state file.
+func HistoryPath() string {
+	return StatePath + ".history"
+}
+
+// MaxHistorySize bounds the history file. Once it grows past this size, the
+// oldest entries are dropped until it is half as big.
+var MaxHistorySize int64 = 2 * 1024 * 1024
+
+// A check is flapping if its outcome changed between pass and fail at least
+// flappingThreshold times in its last flappingWindow runs.
+const (
+	flappingWindow    = 10
+	flappingThreshold = 3
+)
+
+// HistoryEntry is the result of one run of a check.
+type HistoryEntry struct {
+	Time       time.Time     `json:"time"`
+	UUID       string        `json:"uuid"`
+	Name       string        `json:"name"`
+	Outcome    check.Outcome `json:"outcome"`
+	Details    string        `json:"details"`
+	DurationMs int64         `json:"durationMs"`
+}
+
+// failed returns true if the entry records a failure or an error.
+func (e HistoryEntry) failed() bool {
+	return check.Result{Outcome: e.Outcome}.Failed()
+}
+
+// state buckets the outcome into pass, fail or off, the states between
+// which transitions are reported.
+func (e HistoryEntry) state() string {
+	res := check.Result{Outcome: e.Outcome}
+	switch {
+	case res.Passed():
+		return "pass"
+	case res.Failed():
+		return "fail"
+	}
+	return "off"
+}
+
+// AppendHistory appends entries to the history file and drops the oldest
+// entries if the file grew past MaxHistorySize. The history is locked while
+// it is written, so that the entries other runs append meanwhile are not
+// lost to the trim.
+func AppendHistory(entries []HistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	unlock, err := LockPath(HistoryPath()+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	info, err := file.Stat()
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if info.Size() > MaxHistorySize {
+		return trimHistory()
+	}
+	return nil
+}
+
+// trimHistory keeps the newest entries that fit in half of MaxHistorySize.
+// The caller must hold the history lock.
+func trimHistory() error {
+	content, err := os.ReadFile(HistoryPath())
+	if err != nil {
+		return err
+	}
+	keep := content
+	for int64(len(keep)) > MaxHistorySize/2 {
+		newline := bytes.IndexByte(keep, '\n')
+		if newline < 0 {
+			keep = nil
+			break
+		}
+		keep = keep[newline+1:]
+	}
+
+	return WriteFileAtomic(HistoryPath(), keep)
+}
+
+// ReadHistory returns the entries recorded at or after since, oldest first.
+// If uuid is not empty, only the entries of that check are returned.
+// Lines that cannot be decoded are skipped.
+func ReadHistory(uuid string, since time.Time) ([]HistoryEntry, error) {
+	file, err := os.Open(HistoryPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.WithError(err).Debug("Skipping corrupt history entry")
+			continue
+		}
+		if uuid != "" && entry.UUID != uuid || entry.Time.Before(since) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, scanner.Err()
+}
+
+// Transition is a change of a check between passing, failing and being off.
+type Transition struct {
+	Time time.Time
+	UUID string
+	Name string
+	From check.Outcome
+	To   check.Outcome
+}
+
+// HistoryTransitions returns the transitions found in entries, which must
+// be sorted oldest first.
+func HistoryTransitions(entries []HistoryEntry) []Transition {
+	var transitions []Transition
+	last := map[string]HistoryEntry{}
+	for _, entry := range entries {
+		if previous, found := last[entry.UUID]; found && previous.state() != entry.state() {
+			transitions = append(transitions, Transition{
+				Time: entry.Time,
+				UUID: entry.UUID,
+				Name: entry.Name,
+				From: previous.Outcome,
+				To:   entry.Outcome,
+			})
+		}
+		last[entry.UUID] = entry
+	}
+	return transitions
+}
+
+// HistorySummary describes how a check fared over a stretch of history.
+type HistorySummary struct {
+	UUID        string
+	Name        string
+	Runs        int
+	Transitions int
+	// Failing is the time the check spent failing.
+	Failing time.Duration
+	// FailingSince is when the check started failing, zero if its last run
+	// did not fail.
+	FailingSince time.Time
+	// Flapping is set if the check keeps changing between pass and fail.
+	Flapping bool
+}
+
+// SummarizeHistory summarizes entries, which must be sorted oldest first,
+// per check and sorted by name. A check that is still failing counts as
+// failing until now.
+func SummarizeHistory(entries []HistoryEntry, now time.Time) []HistorySummary {
+	byUUID := map[string][]HistoryEntry{}
+	for _, entry := range entries {
+		byUUID[entry.UUID] = append(byUUID[entry.UUID], entry)
+	}
+
+	summaries := make([]HistorySummary, 0, len(byUUID))
+	for uuid, runs := range byUUID {
+		last := runs[len(runs)-1]
+		summary := HistorySummary{
+			UUID:        uuid,
+			Name:        last.Name,
+			Runs:        len(runs),
+			Transitions: len(HistoryTransitions(runs)),
+		}
+		for i, run := range runs {
+			if !run.failed() {
+				continue
+			}
+			until := now
+			if i+1 < len(runs) {
+				until = runs[i+1].Time
+			}
+			summary.Failing += until.Sub(run.Time)
+		}
+		if last.failed() {
+			summary.FailingSince = last.Time
+			for i := len(runs) - 2; i >= 0 && runs[i].failed(); i-- {
+				summary.FailingSince = runs[i].Time
+			}
+		}
+		summary.Flapping = isFlapping(runs)
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Name != summaries[j].Name {
+			return summaries[i].Name < summaries[j].Name
+		}
+		return summaries[i].UUID < summaries[j].UUID
+	})
+	return summaries
+}
+
+// isFlapping returns true if the last runs of a check changed between pass
+// and fail at least flappingThreshold times.
+func isFlapping(runs []HistoryEntry) bool {
+	if len(runs) > flappingWindow {
+		runs = runs[len(runs)-flappingWindow:]
+	}
+	changes := 0
+	for i := 1; i < len(runs); i++ {
+		previous, current := runs[i-1].state(), runs[i].state()
+		if previous != current && previous != "off" && current != "off" {
+			changes++
+		}
+	}
+	return changes >= flappingThreshold
+}
+
+// FailingSince returns when each currently failing check started failing,
+// according to the whole history, keyed by UUID.
+func FailingSince() map[string]time.Time {
+	entries, err := ReadHistory("", time.Time{})
+	if err != nil {
+		log.WithError(err).Warn("Failed to read check history")
+		return nil
+	}
+	since := map[string]time.Time{}
+	for _, summary := range SummarizeHistory(entries, time.Now()) {
+		if !summary.FailingSince.IsZero() {
+			since[summary.UUID] = summary.FailingSince
+		}
+	}
+	return since
+}
+
+// HistoryStates returns the state of each check as recorded by its newest
+// entry at or before at, keyed by UUID. Entries must be sorted oldest first.
+func HistoryStates(entries []HistoryEntry, at time.Time) map[string]LastState {
+	states := map[string]LastState{}
+	for _, entry := range entries {
+		if entry.Time.After(at) {
+			break
+		}
+		res := check.Result{Outcome: entry.Outcome, Reason: entry.Details}
+		states[entry.UUID] = LastState{
+			Name:      entry.Name,
+			UUID:      entry.UUID,
+			State:     res.Passed(),
+			Details:   entry.Details,
+			Outcome:   entry.Outcome,
+			CheckedAt: entry.Time,
+		}
+	}
+	return states
+}
+
+// HistoryRuns returns the distinct times at which checks were run, oldest
+// first. The checks of one run share the same time.
+func HistoryRuns(entries []HistoryEntry) []time.Time {
+	var runs []time.Time
+	for _, entry := range entries {
+		if len(runs) == 0 || !runs[len(runs)-1].Equal(entry.Time) {
+			runs = append(runs, entry.Time)
+		}
+	}
+	return runs
+}
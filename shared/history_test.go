@@ -0,0 +1,192 @@
+package shared
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func historyRun(uuid string, minutes int, outcome check.Outcome) HistoryEntry {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	return HistoryEntry{
+		Time:    start.Add(time.Duration(minutes) * time.Minute),
+		UUID:    uuid,
+		Name:    "Check " + uuid,
+		Outcome: outcome,
+	}
+}
+
+func TestAppendAndReadHistory(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+
+	entries, err := ReadHistory("", time.Time{})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.NoError(t, AppendHistory([]HistoryEntry{
+		historyRun("a", 10, check.OutcomePass),
+		historyRun("b", 0, check.OutcomeFail),
+	}))
+	// Corrupt lines are skipped
+	file, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	_, err = file.WriteString("{not json\n")
+	assert.NoError(t, err)
+	file.Close()
+	assert.NoError(t, AppendHistory([]HistoryEntry{historyRun("a", 20, check.OutcomeFail)}))
+
+	entries, err = ReadHistory("", time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "b", entries[0].UUID, "entries are sorted by time")
+
+	entries, err = ReadHistory("a", historyRun("a", 15, "").Time)
+	assert.NoError(t, err)
+	assert.Equal(t, []HistoryEntry{historyRun("a", 20, check.OutcomeFail)}, entries)
+}
+
+func TestAppendHistoryTrims(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	maxSize := MaxHistorySize
+	MaxHistorySize = 1024
+	defer func() { MaxHistorySize = maxSize }()
+
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, AppendHistory([]HistoryEntry{historyRun("a", i, check.OutcomePass)}))
+	}
+
+	info, err := os.Stat(HistoryPath())
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), MaxHistorySize)
+	entries, err := ReadHistory("", time.Time{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, entries)
+	assert.Equal(t, historyRun("a", 49, "").Time, entries[len(entries)-1].Time, "the newest entries are kept")
+}
+
+func TestAppendHistoryConcurrently(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	maxSize := MaxHistorySize
+	MaxHistorySize = 2048
+	defer func() { MaxHistorySize = maxSize }()
+
+	var wg sync.WaitGroup
+	for run := 0; run < 8; run++ {
+		wg.Add(1)
+		go func(run int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				assert.NoError(t, AppendHistory([]HistoryEntry{historyRun(strconv.Itoa(run), i, check.OutcomePass)}))
+			}
+		}(run)
+	}
+	wg.Wait()
+
+	content, err := os.ReadFile(HistoryPath())
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, int64(len(content)), MaxHistorySize)
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		var entry HistoryEntry
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry), "entries are not interleaved")
+	}
+	leftovers, err := filepath.Glob(HistoryPath() + ".*.tmp")
+	assert.NoError(t, err)
+	assert.Empty(t, leftovers)
+}
+
+func TestHistoryTransitions(t *testing.T) {
+	transitions := HistoryTransitions([]HistoryEntry{
+		historyRun("a", 0, check.OutcomePass),
+		historyRun("a", 10, check.OutcomePass),
+		historyRun("a", 20, check.OutcomeFail),
+		historyRun("a", 30, check.OutcomeError),
+		historyRun("a", 40, check.OutcomeNotApplicable),
+	})
+
+	assert.Len(t, transitions, 2)
+	assert.Equal(t, check.OutcomePass, transitions[0].From)
+	assert.Equal(t, check.OutcomeFail, transitions[0].To)
+	assert.Equal(t, check.OutcomeError, transitions[1].From)
+	assert.Equal(t, check.OutcomeNotApplicable, transitions[1].To)
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	now := historyRun("", 60, "").Time
+	summaries := SummarizeHistory([]HistoryEntry{
+		historyRun("b", 0, check.OutcomePass),
+		historyRun("a", 0, check.OutcomeFail),
+		historyRun("a", 10, check.OutcomePass),
+		historyRun("a", 20, check.OutcomeFail),
+		historyRun("a", 30, check.OutcomeFail),
+		historyRun("b", 30, check.OutcomePass),
+	}, now)
+
+	assert.Len(t, summaries, 2)
+	a, b := summaries[0], summaries[1]
+	assert.Equal(t, "a", a.UUID)
+	assert.Equal(t, 4, a.Runs)
+	assert.Equal(t, 2, a.Transitions)
+	assert.Equal(t, 50*time.Minute, a.Failing)
+	assert.Equal(t, historyRun("a", 20, "").Time, a.FailingSince)
+	assert.False(t, a.Flapping)
+
+	assert.Equal(t, "b", b.UUID)
+	assert.Zero(t, b.Failing)
+	assert.True(t, b.FailingSince.IsZero())
+}
+
+func TestSummarizeHistoryFlapping(t *testing.T) {
+	var entries []HistoryEntry
+	for i, outcome := range []check.Outcome{
+		check.OutcomePass, check.OutcomeFail, check.OutcomeSkipped, check.OutcomePass,
+		check.OutcomeFail, check.OutcomePass,
+	} {
+		entries = append(entries, historyRun("a", i, outcome))
+	}
+
+	summaries := SummarizeHistory(entries, historyRun("a", 10, "").Time)
+	assert.True(t, summaries[0].Flapping)
+
+	// Changes to and from off do not count
+	summaries = SummarizeHistory(entries[:4], historyRun("a", 10, "").Time)
+	assert.False(t, summaries[0].Flapping)
+}
+
+func TestFailingSince(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	assert.NoError(t, AppendHistory([]HistoryEntry{
+		historyRun("a", 0, check.OutcomeFail),
+		historyRun("a", 10, check.OutcomeFail),
+		historyRun("b", 0, check.OutcomeFail),
+		historyRun("b", 10, check.OutcomePass),
+	}))
+
+	assert.Equal(t, map[string]time.Time{"a": historyRun("a", 0, "").Time}, FailingSince())
+}
+
+func TestHistoryStates(t *testing.T) {
+	entries := []HistoryEntry{
+		historyRun("a", 0, check.OutcomePass),
+		historyRun("b", 0, check.OutcomeFail),
+		historyRun("a", 10, check.OutcomeFail),
+		historyRun("b", 10, check.OutcomeFail),
+		historyRun("a", 20, check.OutcomePass),
+	}
+
+	runs := HistoryRuns(entries)
+	assert.Equal(t, []time.Time{historyRun("", 0, "").Time, historyRun("", 10, "").Time, historyRun("", 20, "").Time}, runs)
+
+	states := HistoryStates(entries, runs[1])
+	assert.Len(t, states, 2)
+	assert.Equal(t, check.OutcomeFail, states["a"].Outcome)
+	assert.False(t, states["a"].State)
+	assert.Equal(t, check.OutcomePass, HistoryStates(entries, runs[2])["a"].Outcome)
+}
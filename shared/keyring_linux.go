@@ -0,0 +1,138 @@
+package shared
+
+import (
+	"os"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretService     = "org.freedesktop.secrets"
+	secretServicePath = "/org/freedesktop/secrets"
+	secretCollection  = "/org/freedesktop/secrets/aliases/default"
+)
+
+// secretAttributes identify the device key among the keyring items.
+var secretAttributes = map[string]string{"application": "paretosecurity", "type": "device-key"}
+
+// secret is a secret as passed over the Secret Service API.
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// keyringSession connects to the Secret Service and opens a session that
+// passes secrets unencrypted, which is safe on the private session bus.
+func keyringSession() (*dbus.Conn, dbus.ObjectPath, error) {
+	if testing.Testing() {
+		return nil, "", errNoKeyring
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, "", errNoKeyring
+	}
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretService, secretServicePath).
+		Call("org.freedesktop.Secret.Service.OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		conn.Close()
+		return nil, "", errNoKeyring
+	}
+	return conn, session, nil
+}
+
+// keyringItems returns the unlocked keyring items holding the device key.
+// Locked items cannot be read without prompting the user, so finding only
+// those is an error.
+func keyringItems(conn *dbus.Conn) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := conn.Object(secretService, secretServicePath).
+		Call("org.freedesktop.Secret.Service.SearchItems", 0, secretAttributes).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return nil, err
+	}
+	if len(unlocked) == 0 && len(locked) > 0 {
+		return nil, ErrDeviceKeyLocked
+	}
+	return unlocked, nil
+}
+
+// keyringStore stores the device key in the default keyring collection,
+// replacing any previous one.
+func keyringStore(key []byte) error {
+	conn, session, err := keyringSession()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	properties := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant("Pareto Security device key"),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(secretAttributes),
+	}
+	var item, prompt dbus.ObjectPath
+	err = conn.Object(secretService, secretCollection).
+		Call("org.freedesktop.Secret.Collection.CreateItem", 0, properties, secret{Session: session, Value: key, ContentType: "text/plain"}, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	if prompt != "/" {
+		// The collection is locked, unlocking it would prompt the user
+		return errNoKeyring
+	}
+	return nil
+}
+
+// keyringLoad returns the device key from the keyring, or an error wrapping
+// os.ErrNotExist if it is not there.
+func keyringLoad() ([]byte, error) {
+	conn, session, err := keyringSession()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	items, err := keyringItems(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, os.ErrNotExist
+	}
+	var value secret
+	err = conn.Object(secretService, items[0]).
+		Call("org.freedesktop.Secret.Item.GetSecret", 0, session).
+		Store(&value)
+	if err != nil {
+		return nil, err
+	}
+	return value.Value, nil
+}
+
+// keyringDelete removes the device key from the keyring.
+func keyringDelete() error {
+	conn, _, err := keyringSession()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	items, err := keyringItems(conn)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := conn.Object(secretService, item).Call("org.freedesktop.Secret.Item.Delete", 0).Store(&prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+// +build !linux
+
+package shared
+
+// keyringStore is not supported outside Linux, the key is kept in a file.
+func keyringStore(key []byte) error {
+	return errNoKeyring
+}
+
+// keyringLoad is not supported outside Linux, the key is kept in a file.
+func keyringLoad() ([]byte, error) {
+	return nil, errNoKeyring
+}
+
+// keyringDelete is not supported outside Linux, the key is kept in a file.
+func keyringDelete() error {
+	return errNoKeyring
+}
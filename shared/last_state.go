@@ -1,22 +1,80 @@
 package shared
 
 import (
-	"fmt"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/caarlos0/log"
-	"github.com/olekukonko/tablewriter"
-	"github.com/pelletier/go-toml"
 )
 
 type LastState struct {
-	Name    string `json:"name"`
-	UUID    string `json:"uuid"`
-	State   bool   `json:"state"`
-	Details string `json:"details"`
+	Name     string            `json:"name"`
+	UUID     string            `json:"uuid"`
+	State    bool              `json:"state"`
+	Details  string            `json:"details"`
+	Outcome  check.Outcome     `json:"outcome"`
+	Evidence map[string]string `json:"evidence,omitempty" toml:",omitempty"`
+	// CheckedAt is when the check was last run, zero in states written
+	// before it was recorded.
+	CheckedAt time.Time `json:"checkedAt" toml:",omitempty"`
+	// Signature is the signature of the root helper over the result of a
+	// root check, see SignHelperResult.
+	Signature string `json:"signature,omitempty" toml:",omitempty"`
+	// MAC seals the state against edits made outside of the agent.
+	MAC string `json:"-" toml:",omitempty"`
+	// Tampered is why the state cannot be trusted, found when the state
+	// file is read. Such states never count as passing.
+	Tampered string `json:"tampered,omitempty" toml:"-"`
+}
+
+// NewLastState builds the LastState for chk from the Result of its run. The
+// result of a root check carries the signature of the root helper if it ran
+// the check during this run.
+func NewLastState(chk check.Check, res check.Result) LastState {
+	state := LastState{
+		UUID:     chk.UUID(),
+		Name:     chk.Name(),
+		State:    res.Passed(),
+		Details:  res.Reason,
+		Outcome:  res.Outcome,
+		Evidence: res.Evidence,
+		// The state file keeps whole seconds
+		CheckedAt: time.Now().Truncate(time.Second),
+	}
+	if signed, found := takeHelperSignature(chk.UUID()); found && signed.Outcome == res.Outcome {
+		state.Signature = signed.Signature
+		state.CheckedAt = time.Unix(signed.SignedAt, 0)
+	}
+	return state
+}
+
+// Result returns the check.Result stored in the state. States written before
+// outcomes were recorded are mapped to a pass or fail based on State, and
+// states that were tampered with are errors.
+func (s LastState) Result() check.Result {
+	if s.Tampered != "" {
+		return check.Result{
+			Outcome:  check.OutcomeError,
+			Reason:   "Result cannot be trusted: " + s.Tampered,
+			Evidence: s.Evidence,
+		}
+	}
+	outcome := s.Outcome
+	if outcome == "" {
+		outcome = check.OutcomeFail
+		if s.State {
+			outcome = check.OutcomePass
+		}
+	}
+	return check.Result{
+		Outcome:  outcome,
+		Reason:   s.Details,
+		Evidence: s.Evidence,
+	}
 }
 
 var (
@@ -24,6 +82,10 @@ var (
 	states      = make(map[string]LastState)
 	lastModTime time.Time
 	StatePath   string
+	// stateErr is the reason the state file was last found unreadable.
+	stateErr error
+	// updated holds the UUIDs of the states updated since the last commit.
+	updated = make(map[string]bool)
 )
 
 func init() {
@@ -36,19 +98,59 @@ func init() {
 	StatePath = filepath.Join(homeDir, ".paretosecurity.state")
 }
 
-// Commit writes the current state map to the TOML file.
+// Commit writes the current state map to the TOML file. The file is
+// re-read and replaced atomically while holding the state lock, so that the
+// states another run wrote meanwhile are kept for the checks this run did
+// not update. A file written by a newer agent is not replaced.
 func CommitLastState() error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	file, err := os.Create(StatePath)
+	unlock, err := lockState(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if content, err := os.ReadFile(StatePath); err == nil {
+		written, err := decodeStates(content)
+		if errors.Is(err, errNewerState) {
+			// Writing it in this format would lose what the newer agent keeps
+			return err
+		}
+		if err == nil {
+			verifyStates(written)
+			for uuid, state := range written {
+				if !updated[uuid] {
+					states[uuid] = state
+				}
+			}
+		}
+	}
+	if err := sealStates(states); err != nil {
+		return err
+	}
+	content, err := encodeStates(states)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	lastModTime = time.Now()
-	encoder := toml.NewEncoder(file)
-	return encoder.Encode(states)
+	if err := WriteFileAtomic(StatePath, content); err != nil {
+		return err
+	}
+	updated = make(map[string]bool)
+	if info, err := os.Stat(StatePath); err == nil {
+		lastModTime = info.ModTime()
+	}
+	stateErr = nil
+	return nil
+}
+
+// StateError returns why the state file was last found corrupt and reset,
+// or nil if it was read fine.
+func StateError() error {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return stateErr
 }
 
 // AllChecksPassed returns true if all checks have passed.
@@ -57,7 +159,7 @@ func AllChecksPassed() bool {
 	defer mutex.RUnlock()
 
 	for _, state := range states {
-		if !state.State {
+		if state.Result().Failed() {
 			return false
 		}
 	}
@@ -71,58 +173,26 @@ func GetFailedChecks() []LastState {
 
 	var failedChecks []LastState
 	for _, state := range states {
-		if !state.State {
+		if state.Result().Failed() {
 			failedChecks = append(failedChecks, state)
 		}
 	}
 	return failedChecks
 }
 
-// PrintStates loads and prints all stored states with their UUIDs, state values, and details.
-func PrintStates() {
-	loadStates()
-
-	fmt.Printf("Loaded %d states from %s\n", len(states), StatePath)
-	fmt.Printf("Last modified time: %s\n\n", lastModTime.Format(time.RFC3339))
-
-	data := [][]string{}
-	for uuid, state := range states {
-		stateStr := "Pass"
-		if !state.State {
-			stateStr = "Fail"
-		}
-		data = append(data, []string{uuid, state.Name, stateStr, state.Details})
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"UUID", "Name", "State", "Details"})
-	table.SetAutoWrapText(false)
-	table.SetAutoFormatHeaders(true)
-	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.SetCenterSeparator("")
-	table.SetColumnSeparator("")
-	table.SetRowSeparator("")
-	table.SetHeaderLine(false)
-	table.SetBorder(false)
-	table.SetTablePadding("\t")
-	table.SetNoWhiteSpace(true)
-	table.AppendBulk(data)
-	table.Render()
-}
-
 // UpdateState updates the LastState struct in the in-memory map and commits to the TOML file.
 func UpdateLastState(newState LastState) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
 	states[newState.UUID] = newState
+	updated[newState.UUID] = true
 }
 
 // GetState retrieves the LastState struct by UUID.
 func GetLastState(uuid string) (LastState, bool, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	mutex.Lock()
+	defer mutex.Unlock()
 
 	loadStates()
 
@@ -131,38 +201,65 @@ func GetLastState(uuid string) (LastState, bool, error) {
 }
 
 func GetLastStates() map[string]LastState {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	mutex.Lock()
+	defer mutex.Unlock()
 	loadStates()
 
 	return states
 }
 
 func GetModifiedTime() time.Time {
-	mutex.RLock()
-	defer mutex.RUnlock()
+	mutex.Lock()
+	defer mutex.Unlock()
 	loadStates()
 
 	return lastModTime
 }
 
+// loadStates merges the state file into the in-memory states if it changed
+// since it was last read. A corrupt file is moved aside and the states are
+// reset, so that the next check run rebuilds them.
 func loadStates() {
 	fileInfo, err := os.Stat(StatePath)
-	if err != nil {
+	if err != nil || !fileInfo.ModTime().After(lastModTime) {
 		return
 	}
 
-	if fileInfo.ModTime().After(lastModTime) {
-		file, err := os.Open(StatePath)
-		if err != nil {
-			return
-		}
-		defer file.Close()
+	unlock, err := lockState(false)
+	if err != nil {
+		log.WithError(err).Debug("Reading state file without a lock")
+		unlock = func() {}
+	}
+	content, err := os.ReadFile(StatePath)
+	unlock()
+	if err != nil {
+		return
+	}
 
-		decoder := toml.NewDecoder(file)
-		if err := decoder.Decode(&states); err != nil {
-			return
+	loaded, err := decodeStates(content)
+	if errors.Is(err, errNewerState) {
+		log.WithError(err).Warn("Ignoring state file")
+		lastModTime = fileInfo.ModTime()
+		return
+	}
+	if err != nil {
+		backup := StatePath + ".corrupt"
+		log.WithError(err).WithField("backup", backup).Warn("State file is corrupt, rebuilding it")
+		if err := os.Rename(StatePath, backup); err != nil {
+			log.WithError(err).Warn("Failed to move corrupt state file aside")
 		}
+		states = make(map[string]LastState)
+		stateErr = err
 		lastModTime = fileInfo.ModTime()
+		return
+	}
+	verifyStates(loaded)
+	for uuid, state := range loaded {
+		if state.Tampered != "" {
+			log.WithField("check", state.Name).WithField("reason", state.Tampered).Warn("Result cannot be trusted")
+		}
+		states[uuid] = state
 	}
+	stateErr = nil
+	lastModTime = fileInfo.ModTime()
 }
@@ -1,10 +1,15 @@
 package shared
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/pelletier/go-toml"
 )
 
@@ -22,9 +27,11 @@ func TestCommitLastState_Success(t *testing.T) {
 
 	// Prepare a test state.
 	testState := LastState{
-		UUID:    "test-uuid",
-		State:   true,
-		Details: "all good",
+		UUID:     "test-uuid",
+		State:    true,
+		Details:  "all good",
+		Outcome:  check.OutcomePass,
+		Evidence: map[string]string{"tcp/22": "SSH"},
 	}
 
 	// Clear and set the states map for a clean test.
@@ -45,18 +52,26 @@ func TestCommitLastState_Success(t *testing.T) {
 	}
 	defer file.Close()
 
-	var decoded map[string]LastState
+	var decoded stateFile
 	decoder := toml.NewDecoder(file)
 	if err := decoder.Decode(&decoded); err != nil {
 		t.Fatalf("failed to decode TOML file: %v", err)
 	}
+	if decoded.Version != stateSchemaVersion {
+		t.Fatalf("expected schema version %d, got %d", stateSchemaVersion, decoded.Version)
+	}
 
 	// Validate that the decoded state matches the test state.
-	got, exists := decoded[testState.UUID]
+	got, exists := decoded.Checks[testState.UUID]
 	if !exists {
 		t.Fatalf("expected state with UUID %s not found", testState.UUID)
 	}
-	if got != testState {
+	// States are sealed when committed
+	if got.MAC == "" {
+		t.Fatalf("expected the state to be sealed")
+	}
+	got.MAC = ""
+	if !reflect.DeepEqual(got, testState) {
 		t.Fatalf("expected state %+v, got %+v", testState, got)
 	}
 }
@@ -81,6 +96,185 @@ func TestCommitLastState_Error(t *testing.T) {
 	}
 }
 
+func TestCommitLastState_MergesOtherRuns(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	mutex.Lock()
+	states = make(map[string]LastState)
+	updated = make(map[string]bool)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+
+	UpdateLastState(LastState{UUID: "mine", Outcome: check.OutcomeFail})
+	UpdateLastState(LastState{UUID: "shared", Outcome: check.OutcomeFail})
+	if err := CommitLastState(); err != nil {
+		t.Fatalf("CommitLastState failed: %v", err)
+	}
+
+	// Another run, which read the file before, commits its own results
+	other := map[string]LastState{
+		"mine":   {UUID: "mine", Outcome: check.OutcomeFail},
+		"shared": {UUID: "shared", Outcome: check.OutcomePass},
+		"theirs": {UUID: "theirs", Outcome: check.OutcomePass},
+	}
+	if err := sealStates(other); err != nil {
+		t.Fatalf("sealStates failed: %v", err)
+	}
+	content, err := encodeStates(other)
+	if err != nil {
+		t.Fatalf("encodeStates failed: %v", err)
+	}
+	if err := os.WriteFile(StatePath, content, 0o600); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+
+	UpdateLastState(LastState{UUID: "mine", Outcome: check.OutcomePass})
+	if err := CommitLastState(); err != nil {
+		t.Fatalf("CommitLastState failed: %v", err)
+	}
+	content, err = os.ReadFile(StatePath)
+	if err != nil {
+		t.Fatalf("failed to read state file: %v", err)
+	}
+	written, err := decodeStates(content)
+	if err != nil {
+		t.Fatalf("decodeStates failed: %v", err)
+	}
+	for uuid, outcome := range map[string]check.Outcome{
+		"mine":   check.OutcomePass,
+		"shared": check.OutcomePass,
+		"theirs": check.OutcomePass,
+	} {
+		if written[uuid].Outcome != outcome {
+			t.Fatalf("expected %s to be %s, got %+v", uuid, outcome, written[uuid])
+		}
+	}
+}
+
+func TestLoadStates_MigratesLegacyFormat(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	legacy := `[legacy-uuid]
+  Details = "all good"
+  Name = "Legacy"
+  Outcome = "pass"
+  State = true
+  UUID = "legacy-uuid"
+`
+	if err := os.WriteFile(StatePath, []byte(legacy), 0o600); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+	mutex.Lock()
+	states = make(map[string]LastState)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+
+	state, exists, _ := GetLastState("legacy-uuid")
+	if !exists || state.Name != "Legacy" || !state.State {
+		t.Fatalf("expected legacy state to be loaded, got %+v", state)
+	}
+
+	// Committing rewrites the file in the current format
+	if err := CommitLastState(); err != nil {
+		t.Fatalf("CommitLastState failed: %v", err)
+	}
+	content, err := os.ReadFile(StatePath)
+	if err != nil {
+		t.Fatalf("failed to read state file: %v", err)
+	}
+	loaded, err := decodeStates(content)
+	if err != nil || loaded["legacy-uuid"].Name != "Legacy" {
+		t.Fatalf("expected migrated state, got %+v (%v)", loaded, err)
+	}
+	if !strings.Contains(string(content), "Version = 1") {
+		t.Fatalf("expected schema version in state file, got:\n%s", content)
+	}
+}
+
+func TestLoadStates_Corrupt(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	if err := os.WriteFile(StatePath, []byte("[broken\n"), 0o600); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+	mutex.Lock()
+	states = map[string]LastState{"stale": {UUID: "stale", State: true}}
+	lastModTime = time.Time{}
+	mutex.Unlock()
+
+	if _, exists, _ := GetLastState("stale"); exists {
+		t.Fatalf("expected stale state to be dropped")
+	}
+	if StateError() == nil {
+		t.Fatalf("expected corruption to be reported")
+	}
+	if _, err := os.Stat(StatePath + ".corrupt"); err != nil {
+		t.Fatalf("expected corrupt file to be kept aside: %v", err)
+	}
+
+	UpdateLastState(LastState{UUID: "fresh", State: true})
+	if err := CommitLastState(); err != nil {
+		t.Fatalf("CommitLastState failed: %v", err)
+	}
+	if StateError() != nil {
+		t.Fatalf("expected a rebuilt state file to clear the error, got %v", StateError())
+	}
+}
+
+func TestLoadStates_NewerVersion(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	newer := "Version = 99\n\n[Checks.uuid]\n  UUID = \"uuid\"\n"
+	if err := os.WriteFile(StatePath, []byte(newer), 0o600); err != nil {
+		t.Fatalf("failed to write state file: %v", err)
+	}
+	mutex.Lock()
+	states = make(map[string]LastState)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+
+	if _, exists, _ := GetLastState("uuid"); exists {
+		t.Fatalf("expected newer state file to be ignored")
+	}
+	if _, err := os.Stat(StatePath); err != nil {
+		t.Fatalf("expected newer state file to be left alone: %v", err)
+	}
+
+	UpdateLastState(LastState{UUID: "fresh", State: true})
+	if err := CommitLastState(); !errors.Is(err, errNewerState) {
+		t.Fatalf("expected committing over a newer state file to fail, got %v", err)
+	}
+	content, err := os.ReadFile(StatePath)
+	if err != nil || string(content) != newer {
+		t.Fatalf("expected newer state file to be kept, got %q (%v)", content, err)
+	}
+}
+
+func TestLockState(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	unlock, err := lockState(true)
+	if err != nil {
+		t.Fatalf("lockState failed: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		unlockShared, err := lockState(false)
+		if err == nil {
+			unlockShared()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("expected the shared lock to wait for the exclusive lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the shared lock once the exclusive lock was released")
+	}
+}
+
 func TestAllChecksPassed(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -113,6 +307,22 @@ func TestAllChecksPassed(t *testing.T) {
 			},
 			want: false,
 		},
+		{
+			name: "not applicable checks are ignored",
+			testData: map[string]LastState{
+				"uuid1": {UUID: "uuid1", State: true, Details: "passed", Outcome: check.OutcomePass},
+				"uuid2": {UUID: "uuid2", State: false, Details: "n/a", Outcome: check.OutcomeNotApplicable},
+			},
+			want: true,
+		},
+		{
+			name: "errored check fails",
+			testData: map[string]LastState{
+				"uuid1": {UUID: "uuid1", State: true, Details: "passed", Outcome: check.OutcomePass},
+				"uuid2": {UUID: "uuid2", State: false, Details: "timeout", Outcome: check.OutcomeError},
+			},
+			want: false,
+		},
 		{
 			name:     "no checks",
 			testData: map[string]LastState{},
@@ -0,0 +1,154 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/caarlos0/log"
+)
+
+const (
+	// DefaultNotificationInterval is the minimum time between notifications
+	// about the same check unless configured in pareto.toml.
+	DefaultNotificationInterval = 24 * time.Hour
+	// DefaultSnooze is how long the Snooze action silences a check unless
+	// configured in pareto.toml.
+	DefaultSnooze = 24 * time.Hour
+)
+
+// NotificationSettings configures the notifications sent when a check that
+// used to pass starts failing.
+type NotificationSettings struct {
+	// Disabled turns the notifications off.
+	Disabled bool `toml:",omitempty"`
+	// Interval is the minimum time between notifications about the same
+	// check, such as "12h". An empty value uses DefaultNotificationInterval.
+	Interval string `toml:",omitempty"`
+	// Snooze is how long the Snooze action silences a check, such as "72h".
+	// An empty value uses DefaultSnooze.
+	Snooze string `toml:",omitempty"`
+	// QuietHours is a range of local times, such as "22:00-07:00", during
+	// which notifications are held back until the next run after it.
+	QuietHours string `toml:",omitempty"`
+}
+
+// Notifications returns the notification settings from pareto.toml.
+func Notifications() NotificationSettings {
+	if Config.Notifications == nil {
+		return NotificationSettings{}
+	}
+	return *Config.Notifications
+}
+
+// IntervalDuration returns the configured interval, or the default if it is
+// missing or invalid.
+func (s NotificationSettings) IntervalDuration() time.Duration {
+	return parseSetting("interval", s.Interval, DefaultNotificationInterval)
+}
+
+// SnoozeDuration returns the configured snooze, or the default if it is
+// missing or invalid.
+func (s NotificationSettings) SnoozeDuration() time.Duration {
+	return parseSetting("snooze", s.Snooze, DefaultSnooze)
+}
+
+func parseSetting(name, value string, fallback time.Duration) time.Duration {
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.WithField(name, value).Warn("invalid notification setting, using the default")
+		return fallback
+	}
+	return duration
+}
+
+// IsQuiet returns true if now falls within the quiet hours. A range whose
+// end is before its start spans midnight. Invalid ranges are never quiet.
+func (s NotificationSettings) IsQuiet(now time.Time) bool {
+	if s.QuietHours == "" {
+		return false
+	}
+	var startHour, startMinute, endHour, endMinute int
+	_, err := fmt.Sscanf(s.QuietHours, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute)
+	if err != nil || startHour > 23 || endHour > 23 || startMinute > 59 || endMinute > 59 {
+		log.WithField("quietHours", s.QuietHours).Warn("invalid quiet hours, use a range such as 22:00-07:00")
+		return false
+	}
+	start := startHour*60 + startMinute
+	end := endHour*60 + endMinute
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// NotificationLog records which checks were notified about, which were
+// snoozed and which regressions are held back by quiet hours. It is kept
+// next to the state file.
+type NotificationLog struct {
+	// Sent holds when each check was last notified about.
+	Sent map[string]time.Time `json:"sent"`
+	// Snoozed holds until when each snoozed check is silenced.
+	Snoozed map[string]time.Time `json:"snoozed"`
+	// Pending holds the regressions found during quiet hours.
+	Pending map[string]time.Time `json:"pending"`
+}
+
+// NotificationLogPath returns the file the NotificationLog is kept in.
+func NotificationLogPath() string {
+	return StatePath + ".notifications"
+}
+
+// LoadNotificationLog reads the NotificationLog, starting a new one if it
+// is missing or corrupt.
+func LoadNotificationLog() *NotificationLog {
+	l := &NotificationLog{}
+	content, err := os.ReadFile(NotificationLogPath())
+	if err == nil {
+		if err := json.Unmarshal(content, l); err != nil {
+			log.WithError(err).Warn("Ignoring corrupt notification log")
+			l = &NotificationLog{}
+		}
+	}
+	if l.Sent == nil {
+		l.Sent = map[string]time.Time{}
+	}
+	if l.Snoozed == nil {
+		l.Snoozed = map[string]time.Time{}
+	}
+	if l.Pending == nil {
+		l.Pending = map[string]time.Time{}
+	}
+	return l
+}
+
+// Allows returns true if the check with the given UUID is neither snoozed
+// nor was notified about within interval before now.
+func (l *NotificationLog) Allows(uuid string, now time.Time, interval time.Duration) bool {
+	if until, found := l.Snoozed[uuid]; found && now.Before(until) {
+		return false
+	}
+	if sent, found := l.Sent[uuid]; found && now.Sub(sent) < interval {
+		return false
+	}
+	return true
+}
+
+// Save writes the NotificationLog, dropping snoozes that are over.
+func (l *NotificationLog) Save(now time.Time) error {
+	for uuid, until := range l.Snoozed {
+		if !now.Before(until) {
+			delete(l.Snoozed, uuid)
+		}
+	}
+	content, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(NotificationLogPath(), content)
+}
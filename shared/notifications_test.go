@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotificationSettings(t *testing.T) {
+	assert.Equal(t, DefaultNotificationInterval, NotificationSettings{}.IntervalDuration())
+	assert.Equal(t, DefaultNotificationInterval, NotificationSettings{Interval: "soon"}.IntervalDuration())
+	assert.Equal(t, 12*time.Hour, NotificationSettings{Interval: "12h"}.IntervalDuration())
+	assert.Equal(t, DefaultSnooze, NotificationSettings{}.SnoozeDuration())
+	assert.Equal(t, time.Hour, NotificationSettings{Snooze: "1h"}.SnoozeDuration())
+}
+
+func TestNotificationSettings_IsQuiet(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		quietHours string
+		now        time.Time
+		want       bool
+	}{
+		{"", at(23, 0), false},
+		{"22:00-07:00", at(23, 0), true},
+		{"22:00-07:00", at(6, 59), true},
+		{"22:00-07:00", at(7, 0), false},
+		{"22:00-07:00", at(12, 0), false},
+		{"12:30-13:30", at(13, 0), true},
+		{"12:30-13:30", at(14, 0), false},
+		{"25:00-07:00", at(23, 0), false},
+		{"late", at(23, 0), false},
+	}
+	for _, tt := range tests {
+		got := NotificationSettings{QuietHours: tt.quietHours}.IsQuiet(tt.now)
+		assert.Equal(t, tt.want, got, "%s at %s", tt.quietHours, tt.now.Format("15:04"))
+	}
+}
+
+func TestNotificationLog(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	l := LoadNotificationLog()
+	assert.True(t, l.Allows("a", now, time.Hour))
+
+	l.Sent["a"] = now.Add(-30 * time.Minute)
+	l.Snoozed["b"] = now.Add(time.Hour)
+	l.Snoozed["c"] = now.Add(-time.Hour)
+	assert.NoError(t, l.Save(now))
+
+	l = LoadNotificationLog()
+	assert.False(t, l.Allows("a", now, time.Hour), "notified within the interval")
+	assert.True(t, l.Allows("a", now.Add(time.Hour), time.Hour))
+	assert.False(t, l.Allows("b", now, time.Hour), "snoozed")
+	assert.NotContains(t, l.Snoozed, "c", "expired snoozes are dropped")
+}
@@ -0,0 +1,15 @@
+package shared
+
+import (
+	"slices"
+
+	"github.com/ParetoSecurity/agent/check"
+)
+
+// Matches returns true if the profile selects the registered check.
+func (p Profile) Matches(reg check.Registration) bool {
+	if slices.Contains(p.Checks, reg.UUID) {
+		return true
+	}
+	return slices.ContainsFunc(p.Tags, reg.HasTag)
+}
@@ -0,0 +1,17 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile_Matches(t *testing.T) {
+	profile := Profile{Tags: []string{"server"}, Checks: []string{"uuid-extra"}}
+
+	assert.True(t, profile.Matches(check.Registration{UUID: "uuid-a", Tags: []string{"ssh", "server"}}))
+	assert.True(t, profile.Matches(check.Registration{UUID: "uuid-extra"}))
+	assert.False(t, profile.Matches(check.Registration{UUID: "uuid-b", Tags: []string{"workstation"}}))
+	assert.False(t, Profile{}.Matches(check.Registration{UUID: "uuid-a", Tags: []string{"server"}}))
+}
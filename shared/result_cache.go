@@ -0,0 +1,143 @@
+package shared
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/caarlos0/log"
+)
+
+// ResultCachePath is where the root helper keeps the results of root checks
+// between runs.
+var ResultCachePath = "/var/cache/paretosecurity/results.json"
+
+// fileStamp records the state of a watched file when a result was cached.
+type fileStamp struct {
+	ModTime int64 `json:"modTime"`
+	Size    int64 `json:"size"`
+}
+
+type cachedResult struct {
+	Result check.Result         `json:"result"`
+	At     time.Time            `json:"at"`
+	Files  map[string]fileStamp `json:"files"`
+}
+
+// ResultCache holds the results of root checks keyed by UUID. A result is
+// served until its TTL passes or one of the files it watches changes. It is
+// safe for concurrent use.
+type ResultCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]cachedResult
+}
+
+// NewResultCache returns a cache that is persisted at path, and loads the
+// results stored there if the file is owned by root. An empty path keeps
+// the cache in memory only.
+func NewResultCache(path string) *ResultCache {
+	c := &ResultCache{path: path, entries: map[string]cachedResult{}}
+	if path == "" {
+		return c
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return c
+	}
+	if err := isRootOwned(info); err != nil {
+		log.WithError(err).Warn("Ignoring result cache")
+		return c
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(content, &c.entries); err != nil {
+		log.WithError(err).Warn("Ignoring corrupt result cache")
+		c.entries = map[string]cachedResult{}
+	}
+	return c
+}
+
+// Get returns the cached result of the check with the given UUID and its
+// age, if it is younger than ttl and none of the files matching watch
+// changed since it was cached.
+func (c *ResultCache) Get(uuid string, ttl time.Duration, watch []string) (check.Result, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[uuid]
+	if !found {
+		return check.Result{}, 0, false
+	}
+	age := time.Since(entry.At)
+	if age < 0 || age > ttl || !maps.Equal(entry.Files, stampFiles(watch)) {
+		delete(c.entries, uuid)
+		return check.Result{}, 0, false
+	}
+	return entry.Result, age, true
+}
+
+// Put caches res as the result of the check with the given UUID, along with
+// the state of the files matching watch.
+func (c *ResultCache) Put(uuid string, res check.Result, watch []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[uuid] = cachedResult{Result: res, At: time.Now(), Files: stampFiles(watch)}
+	c.save()
+}
+
+// Invalidate drops the cached result of the check with the given UUID.
+func (c *ResultCache) Invalidate(uuid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, found := c.entries[uuid]; found {
+		delete(c.entries, uuid)
+		c.save()
+	}
+}
+
+// save writes the entries to the cache file, readable by root only.
+func (c *ResultCache) save() {
+	if c.path == "" {
+		return
+	}
+	content, err := json.Marshal(c.entries)
+	if err != nil {
+		log.WithError(err).Warn("Failed to encode result cache")
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		log.WithError(err).Warn("Failed to create result cache directory")
+		return
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		log.WithError(err).Warn("Failed to write result cache")
+		return
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		log.WithError(err).Warn("Failed to write result cache")
+	}
+}
+
+// stampFiles returns the state of the files matching the glob patterns.
+func stampFiles(patterns []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil {
+				stamps[match] = fileStamp{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+			}
+		}
+	}
+	return stamps
+}
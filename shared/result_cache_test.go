@@ -0,0 +1,68 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultCache(t *testing.T) {
+	dir := t.TempDir()
+	watched := filepath.Join(dir, "sshd_config")
+	assert.NoError(t, os.WriteFile(watched, []byte("PermitRootLogin yes\n"), 0o644))
+	watch := []string{watched, filepath.Join(dir, "sshd_config.d", "*")}
+	res := check.Result{Outcome: check.OutcomeFail, Reason: "Root login is enabled"}
+
+	cache := NewResultCache("")
+	_, _, ok := cache.Get("uuid", time.Minute, watch)
+	assert.False(t, ok)
+
+	cache.Put("uuid", res, watch)
+	got, age, ok := cache.Get("uuid", time.Minute, watch)
+	assert.True(t, ok)
+	assert.Equal(t, res, got)
+	assert.Less(t, age, time.Minute)
+
+	// Expired results are not served
+	_, _, ok = cache.Get("uuid", 0, watch)
+	assert.False(t, ok)
+
+	// Changing a watched file invalidates the result
+	cache.Put("uuid", res, watch)
+	assert.NoError(t, os.WriteFile(watched, []byte("PermitRootLogin no\n"), 0o644))
+	_, _, ok = cache.Get("uuid", time.Minute, watch)
+	assert.False(t, ok)
+
+	// So does a new file matching a watched pattern
+	cache.Put("uuid", res, watch)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sshd_config.d"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "sshd_config.d", "50-cloud.conf"), []byte("PasswordAuthentication yes\n"), 0o644))
+	_, _, ok = cache.Get("uuid", time.Minute, watch)
+	assert.False(t, ok)
+
+	cache.Put("uuid", res, watch)
+	cache.Invalidate("uuid")
+	_, _, ok = cache.Get("uuid", time.Minute, watch)
+	assert.False(t, ok)
+}
+
+func TestResultCache_Persisted(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("the cache file is only trusted when owned by root")
+	}
+	path := filepath.Join(t.TempDir(), "cache", "results.json")
+	res := check.Result{Outcome: check.OutcomePass, Reason: "Firewall is on"}
+
+	NewResultCache(path).Put("uuid", res, nil)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	got, _, ok := NewResultCache(path).Get("uuid", time.Minute, nil)
+	assert.True(t, ok)
+	assert.Equal(t, res, got)
+}
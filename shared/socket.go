@@ -1,9 +1,17 @@
 package shared
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/caarlos0/log"
 	"go.uber.org/ratelimit"
 )
@@ -11,39 +19,294 @@ import (
 var SocketPath = "/run/paretosecurity.sock"
 var rateLimitCall = ratelimit.New(1)
 
+// legacyHelper is set once the root helper turned out to only speak
+// version 1 of the protocol, so that later calls skip the handshake.
+var legacyHelper atomic.Bool
+
+// helperSignatures holds the signed results the root helper returned during
+// this run, until NewLastState takes them.
+var (
+	helperSignaturesMu sync.Mutex
+	helperSignatures   = map[string]HelperResult{}
+)
+
+// errLegacyHelper is returned by the handshake when the helper closed the
+// connection instead of answering it.
+var errLegacyHelper = errors.New("root helper does not support protocol version 2")
+
 func IsSocketServicePresent() bool {
 	_, err := RunCommand("systemctl", "is-enabled", "--quiet", "paretosecurity.socket")
 	return err == nil
 }
 
-func RunCheckViaHelper(uuid string) (bool, error) {
+type helperResultsKey struct{}
+
+type freshResultsKey struct{}
+
+// WithFreshResults returns a context that makes the root helper run checks
+// even if it has cached results for them.
+func WithFreshResults(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshResultsKey{}, true)
+}
+
+// wantsFreshResults returns whether ctx was made by WithFreshResults.
+func wantsFreshResults(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshResultsKey{}).(bool)
+	return fresh
+}
+
+// PrefetchViaHelper runs the checks with the given UUIDs in one round-trip to
+// the root helper and returns a context carrying their results, which
+// RunCheckViaHelper then answers from. If the helper cannot be reached, ctx
+// is returned as is and every check asks the helper on its own.
+func PrefetchViaHelper(ctx context.Context, uuids []string) context.Context {
+	if len(uuids) == 0 {
+		return ctx
+	}
+
+	// The helper runs the batch concurrently, so the slowest check bounds it
+	timeout := time.Duration(0)
+	for _, uuid := range uuids {
+		timeout = max(timeout, CheckTimeout(uuid))
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout+5*time.Second)
+	defer cancel()
 
+	results, err := RunChecksViaHelper(callCtx, uuids)
+	if err != nil {
+		log.WithError(err).Debug("Failed to prefetch results from root helper")
+		return ctx
+	}
+	return context.WithValue(ctx, helperResultsKey{}, results)
+}
+
+// RunCheckViaHelper returns the result of the check with the given UUID as
+// run by the root helper. Results prefetched into ctx by PrefetchViaHelper
+// are used if present. The request is abandoned when ctx is done.
+func RunCheckViaHelper(ctx context.Context, uuid string) (check.Result, error) {
+	results, _ := ctx.Value(helperResultsKey{}).(map[string]HelperResult)
+	res, ok := results[uuid]
+	if !ok {
+		fetched, err := RunChecksViaHelper(ctx, []string{uuid})
+		if err != nil {
+			return check.Result{}, err
+		}
+		if res, ok = fetched[uuid]; !ok {
+			return check.Result{}, fmt.Errorf("root helper did not return a result for check %s", uuid)
+		}
+	}
+	switch {
+	case res.Error != "":
+		return check.Result{}, errors.New(res.Error)
+	case res.Outcome == check.OutcomeError:
+		return check.Result{}, fmt.Errorf("check %s failed to run as root: %s", uuid, res.Reason)
+	}
+	if res.Cached {
+		log.WithField("uuid", uuid).WithField("age", time.Duration(res.AgeSeconds)*time.Second).Debug("Root helper answered from its cache")
+	}
+	return res.Result, nil
+}
+
+// RunChecksViaHelper asks the root helper to run the checks with the given
+// UUIDs and returns their results, keyed by UUID. The helper may answer with
+// cached results unless ctx was made by WithFreshResults.
+func RunChecksViaHelper(ctx context.Context, uuids []string) (map[string]HelperResult, error) {
+	log.WithField("uuids", uuids).Debug("Running checks via root helper")
+	results, err := callHelper(ctx, HelperRequest{Action: HelperActionRun, UUIDs: uuids, Fresh: wantsFreshResults(ctx), Timeouts: checkTimeouts(uuids)})
+	if err != nil {
+		return nil, err
+	}
+	helperSignaturesMu.Lock()
+	defer helperSignaturesMu.Unlock()
+	for uuid, res := range results {
+		if res.Signature != "" {
+			helperSignatures[uuid] = res
+		}
+	}
+	return results, nil
+}
+
+// checkTimeouts returns the configured timeouts of the checks with the given
+// UUIDs, for the root helper to run them with.
+func checkTimeouts(uuids []string) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for _, uuid := range uuids {
+		timeouts[uuid] = CheckTimeout(uuid)
+	}
+	return timeouts
+}
+
+// takeHelperSignature returns the signed result the root helper returned
+// for the check with the given UUID during this run, and forgets it.
+func takeHelperSignature(uuid string) (HelperResult, bool) {
+	helperSignaturesMu.Lock()
+	defer helperSignaturesMu.Unlock()
+	res, found := helperSignatures[uuid]
+	delete(helperSignatures, uuid)
+	return res, found
+}
+
+// PlanFixViaHelper asks the root helper to run the check with the given UUID
+// and returns its result and the plan of its fix. The helper plans the fix
+// itself, as only it can see what needs fixing.
+func PlanFixViaHelper(ctx context.Context, uuid string) (check.Result, []string, error) {
+	log.WithField("uuid", uuid).Debug("Planning fix via root helper")
+	response, err := callHelperV2(ctx, HelperRequest{Action: HelperActionPlan, UUIDs: []string{uuid}, Timeouts: checkTimeouts([]string{uuid})})
+	if errors.Is(err, errLegacyHelper) {
+		return check.Result{}, nil, errors.New("root helper cannot plan fixes, please update it")
+	}
+	if err != nil {
+		return check.Result{}, nil, err
+	}
+	res, ok := response.Results[uuid]
+	switch {
+	case !ok:
+		return check.Result{}, nil, errors.New("root helper did not return a plan for the fix")
+	case res.Error != "":
+		return check.Result{}, nil, errors.New(res.Error)
+	}
+	return res.Result, res.Plan, nil
+}
+
+// RunFixViaHelper asks the root helper to fix the check with the given UUID
+// as planned by PlanFixViaHelper. The helper runs the check, applies its fix
+// if it fails and still has the confirmed plan, and runs it again; the
+// returned error reports whether the check passes afterwards.
+func RunFixViaHelper(ctx context.Context, uuid string, plan []string) error {
+	log.WithField("uuid", uuid).Debug("Fixing check via root helper")
+	response, err := callHelperV2(ctx, HelperRequest{Action: HelperActionFix, UUIDs: []string{uuid}, Plan: plan, Timeouts: checkTimeouts([]string{uuid})})
+	if errors.Is(err, errLegacyHelper) {
+		return errors.New("root helper cannot apply confirmed fixes, please update it")
+	}
+	if err != nil {
+		return err
+	}
+	res, ok := response.Results[uuid]
+	switch {
+	case !ok:
+		return errors.New("root helper did not return a result for the fix")
+	case res.Error != "":
+		return errors.New(res.Error)
+	case !res.Passed():
+		return errors.New("root helper could not fix the check")
+	}
+	return nil
+}
+
+// ProductUUIDViaHelper asks the root helper for the DMI product UUID.
+func ProductUUIDViaHelper(ctx context.Context) (string, error) {
+	response, err := callHelperV2(ctx, HelperRequest{Action: HelperActionProductUUID})
+	switch {
+	case errors.Is(err, errLegacyHelper):
+		return "", errors.New("root helper cannot read the product UUID, please update it")
+	case err != nil:
+		return "", err
+	case response.Error != "":
+		return "", errors.New(response.Error)
+	case response.ProductUUID == "":
+		return "", errors.New("root helper cannot read the product UUID, please update it")
+	}
+	return response.ProductUUID, nil
+}
+
+// callHelper sends req to the root helper, using version 1 of the protocol
+// if the helper does not speak version 2.
+func callHelper(ctx context.Context, req HelperRequest) (map[string]HelperResult, error) {
+	if !legacyHelper.Load() {
+		response, err := callHelperV2(ctx, req)
+		if !errors.Is(err, errLegacyHelper) {
+			return response.Results, err
+		}
+		log.Info("Root helper only speaks protocol version 1, please update it")
+		legacyHelper.Store(true)
+	}
+
+	results := map[string]HelperResult{}
+	for _, uuid := range req.UUIDs {
+		input := map[string]string{"uuid": uuid}
+		var status map[string]bool
+		if err := exchange(ctx, nil, input, &status); err != nil {
+			return nil, err
+		}
+		passed, ok := status[uuid]
+		if !ok {
+			// Version 1 helpers leave out the checks they did not run
+			results[uuid] = HelperResult{Error: fmt.Sprintf("root helper did not run check %s", uuid)}
+			continue
+		}
+		res := check.Result{Outcome: check.OutcomeFail}
+		if passed {
+			res.Outcome = check.OutcomePass
+		}
+		results[uuid] = HelperResult{Result: res}
+	}
+	return results, nil
+}
+
+// callHelperV2 performs the handshake and sends req over one connection.
+func callHelperV2(ctx context.Context, req HelperRequest) (HelperResponse, error) {
+	handshake := func(encoder *json.Encoder, decoder *json.Decoder) error {
+		if err := encoder.Encode(HelperHello{Version: HelperProtocolVersion}); err != nil {
+			return err
+		}
+		var hello HelperHello
+		if err := decoder.Decode(&hello); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errLegacyHelper
+			}
+			return err
+		}
+		if hello.Error != "" {
+			return fmt.Errorf("root helper refused the connection: %s", hello.Error)
+		}
+		if hello.Version < 2 {
+			return errLegacyHelper
+		}
+		return nil
+	}
+
+	var response HelperResponse
+	err := exchange(ctx, handshake, req, &response)
+	return response, err
+}
+
+// exchange connects to the root helper, runs handshake if set, sends input
+// and decodes the answer into output.
+func exchange(ctx context.Context, handshake func(*json.Encoder, *json.Decoder) error, input, output any) error {
 	rateLimitCall.Take()
-	log.WithField("uuid", uuid).Debug("Running check via root helper")
 
-	conn, err := net.Dial("unix", SocketPath)
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", SocketPath)
 	if err != nil {
 		log.WithError(err).Warn("Failed to connect to root helper")
-		return false, err
+		return err
 	}
 	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
 
-	// Send UUID
-	input := map[string]string{"uuid": uuid}
 	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+	if handshake != nil {
+		if err := handshake(encoder, decoder); err != nil {
+			return err
+		}
+	}
+
 	log.WithField("input", input).Debug("Sending input to helper")
 	if err := encoder.Encode(input); err != nil {
 		log.WithError(err).Warn("Failed to encode JSON")
-		return false, err
+		return err
 	}
 
 	// Read response
-	decoder := json.NewDecoder(conn)
-	var status map[string]bool
-	if err := decoder.Decode(&status); err != nil {
+	if err := decoder.Decode(output); err != nil {
 		log.WithError(err).Warn("Failed to decode JSON")
-		return false, err
+		return err
 	}
-	log.WithField("status", status).Debug("Received status from helper")
-	return status[uuid], nil
+	log.WithField("output", output).Debug("Received output from helper")
+	return nil
 }
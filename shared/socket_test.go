@@ -1,9 +1,15 @@
 package shared
 
 import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
 	"testing"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/ratelimit"
 )
 
 func TestIsSocketServicePresent(t *testing.T) {
@@ -37,3 +43,164 @@ func TestIsSocketServicePresent(t *testing.T) {
 		})
 	}
 }
+
+// serveHelper answers each connection on a new socket with handle and
+// points SocketPath at it for the duration of the test.
+func serveHelper(t *testing.T, handle func(conn net.Conn)) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "helper.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	socketPath, limit := SocketPath, rateLimitCall
+	SocketPath, rateLimitCall = path, ratelimit.NewUnlimited()
+	legacyHelper.Store(false)
+	t.Cleanup(func() {
+		listener.Close()
+		SocketPath, rateLimitCall = socketPath, limit
+		legacyHelper.Store(false)
+	})
+}
+
+func TestRunChecksViaHelper_V2(t *testing.T) {
+	var requests []HelperRequest
+	serveHelper(t, func(conn net.Conn) {
+		decoder, encoder := json.NewDecoder(conn), json.NewEncoder(conn)
+		var hello HelperHello
+		if decoder.Decode(&hello) != nil {
+			return
+		}
+		_ = encoder.Encode(HelperHello{Version: HelperProtocolVersion})
+		var req HelperRequest
+		if decoder.Decode(&req) != nil {
+			return
+		}
+		requests = append(requests, req)
+		_ = encoder.Encode(HelperResponse{Results: map[string]HelperResult{
+			"ssh": {Result: check.Result{
+				Outcome:  check.OutcomeFail,
+				Reason:   "Root login is enabled",
+				Evidence: map[string]string{"permitrootlogin": "yes"},
+			}},
+			"unknown": {Error: "check unknown is not registered"},
+		}})
+	})
+
+	ctx := PrefetchViaHelper(context.Background(), []string{"ssh", "unknown"})
+	assert.Len(t, requests, 1)
+	assert.Equal(t, []string{"ssh", "unknown"}, requests[0].UUIDs)
+
+	// Answered from the prefetched results, without another round-trip
+	res, err := RunCheckViaHelper(ctx, "ssh")
+	assert.NoError(t, err)
+	assert.Equal(t, check.OutcomeFail, res.Outcome)
+	assert.Equal(t, "Root login is enabled", res.Reason)
+	assert.Equal(t, map[string]string{"permitrootlogin": "yes"}, res.Evidence)
+
+	_, err = RunCheckViaHelper(ctx, "unknown")
+	assert.EqualError(t, err, "check unknown is not registered")
+	assert.Len(t, requests, 1)
+}
+
+func TestRunChecksViaHelper_V1Fallback(t *testing.T) {
+	serveHelper(t, func(conn net.Conn) {
+		// Version 1 helpers only understand {"uuid": "..."}
+		var input map[string]string
+		if err := json.NewDecoder(conn).Decode(&input); err != nil {
+			return
+		}
+		if input["uuid"] == "ssh" {
+			_ = json.NewEncoder(conn).Encode(map[string]bool{"ssh": true})
+			return
+		}
+		_ = json.NewEncoder(conn).Encode(map[string]bool{})
+	})
+
+	results, err := RunChecksViaHelper(context.Background(), []string{"ssh", "unknown"})
+	assert.NoError(t, err)
+	assert.True(t, legacyHelper.Load())
+	assert.Equal(t, check.OutcomePass, results["ssh"].Outcome)
+	assert.NotEmpty(t, results["unknown"].Error)
+
+	res, err := RunCheckViaHelper(context.Background(), "ssh")
+	assert.NoError(t, err)
+	assert.True(t, res.Passed())
+}
+
+func TestProductUUIDViaHelper(t *testing.T) {
+	var response HelperResponse
+	serveHelper(t, func(conn net.Conn) {
+		decoder, encoder := json.NewDecoder(conn), json.NewEncoder(conn)
+		var hello HelperHello
+		if decoder.Decode(&hello) != nil {
+			return
+		}
+		_ = encoder.Encode(HelperHello{Version: HelperProtocolVersion})
+		var req HelperRequest
+		if decoder.Decode(&req) != nil || req.Action != HelperActionProductUUID {
+			return
+		}
+		_ = encoder.Encode(response)
+	})
+
+	response = HelperResponse{ProductUUID: "4c4c4544-0042-3510-8052-b4c04f384d32"}
+	id, err := ProductUUIDViaHelper(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "4c4c4544-0042-3510-8052-b4c04f384d32", id)
+
+	response = HelperResponse{Error: "the product UUID is not set"}
+	_, err = ProductUUIDViaHelper(context.Background())
+	assert.EqualError(t, err, "the product UUID is not set")
+
+	// Helpers that predate the action answer with no results
+	response = HelperResponse{Results: map[string]HelperResult{}}
+	_, err = ProductUUIDViaHelper(context.Background())
+	assert.Error(t, err)
+}
+
+func TestFixViaHelper(t *testing.T) {
+	var requests []HelperRequest
+	serveHelper(t, func(conn net.Conn) {
+		decoder, encoder := json.NewDecoder(conn), json.NewEncoder(conn)
+		var hello HelperHello
+		if decoder.Decode(&hello) != nil {
+			return
+		}
+		_ = encoder.Encode(HelperHello{Version: HelperProtocolVersion})
+		var req HelperRequest
+		if decoder.Decode(&req) != nil {
+			return
+		}
+		requests = append(requests, req)
+		res := HelperResult{Result: check.Result{Outcome: check.OutcomePass}}
+		if req.Action == HelperActionPlan {
+			res = HelperResult{Result: check.Result{Outcome: check.OutcomeFail}, Plan: []string{"Set PermitRootLogin no"}}
+		}
+		_ = encoder.Encode(HelperResponse{Results: map[string]HelperResult{"ssh": res}})
+	})
+
+	res, plan, err := PlanFixViaHelper(context.Background(), "ssh")
+	assert.NoError(t, err)
+	assert.Equal(t, check.OutcomeFail, res.Outcome)
+	assert.Equal(t, []string{"Set PermitRootLogin no"}, plan)
+
+	// The confirmed plan is sent along with the fix
+	assert.NoError(t, RunFixViaHelper(context.Background(), "ssh", plan))
+	assert.Len(t, requests, 2)
+	assert.Equal(t, HelperActionFix, requests[1].Action)
+	assert.Equal(t, plan, requests[1].Plan)
+}
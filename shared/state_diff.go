@@ -0,0 +1,103 @@
+package shared
+
+import (
+	"sort"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/samber/lo"
+)
+
+// ChangeKind describes how a check changed between two states.
+type ChangeKind string
+
+const (
+	ChangeNewFailure  ChangeKind = "new-failure"
+	ChangeNewPass     ChangeKind = "new-pass"
+	ChangeRunnable    ChangeKind = "runnable"
+	ChangeNotRunnable ChangeKind = "not-runnable"
+	ChangeDetails     ChangeKind = "details"
+)
+
+// changeOrder is the order in which changes are listed.
+var changeOrder = []ChangeKind{ChangeNewFailure, ChangeNewPass, ChangeRunnable, ChangeNotRunnable, ChangeDetails}
+
+// StateChange is a change of one check between two states.
+type StateChange struct {
+	Kind        ChangeKind    `json:"kind"`
+	UUID        string        `json:"uuid"`
+	Name        string        `json:"name"`
+	From        check.Outcome `json:"from,omitempty"`
+	To          check.Outcome `json:"to,omitempty"`
+	FromDetails string        `json:"fromDetails,omitempty"`
+	ToDetails   string        `json:"toDetails,omitempty"`
+}
+
+// DiffStates returns how the checks changed from before to after, sorted by
+// kind and name. A check may change in several ways at once, such as
+// becoming runnable and failing; its details are only reported as changed
+// if its outcome did not change. Checks missing from a state count as off.
+func DiffStates(before, after map[string]LastState) []StateChange {
+	var changes []StateChange
+	for _, uuid := range lo.Uniq(append(lo.Keys(before), lo.Keys(after)...)) {
+		from, hadBefore := before[uuid]
+		to, hasAfter := after[uuid]
+		fromRes, toRes := stateResult(from, hadBefore), stateResult(to, hasAfter)
+
+		change := StateChange{
+			UUID:        uuid,
+			Name:        to.Name,
+			FromDetails: from.Details,
+			ToDetails:   to.Details,
+		}
+		if hadBefore {
+			change.From = fromRes.Outcome
+		}
+		if hasAfter {
+			change.To = toRes.Outcome
+		} else {
+			change.Name = from.Name
+		}
+		var kinds []ChangeKind
+		if !fromRes.Failed() && toRes.Failed() {
+			kinds = append(kinds, ChangeNewFailure)
+		}
+		if !fromRes.Passed() && toRes.Passed() {
+			kinds = append(kinds, ChangeNewPass)
+		}
+		if hadBefore && hasAfter {
+			fromRunnable := fromRes.Outcome != check.OutcomeNotApplicable
+			toRunnable := toRes.Outcome != check.OutcomeNotApplicable
+			switch {
+			case !fromRunnable && toRunnable:
+				kinds = append(kinds, ChangeRunnable)
+			case fromRunnable && !toRunnable:
+				kinds = append(kinds, ChangeNotRunnable)
+			case fromRes.Outcome == toRes.Outcome && from.Details != to.Details:
+				kinds = append(kinds, ChangeDetails)
+			}
+		}
+		for _, kind := range kinds {
+			change.Kind = kind
+			changes = append(changes, change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return lo.IndexOf(changeOrder, changes[i].Kind) < lo.IndexOf(changeOrder, changes[j].Kind)
+		}
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].UUID < changes[j].UUID
+	})
+	return changes
+}
+
+// stateResult returns the result recorded in state, or off if there is none.
+func stateResult(state LastState, found bool) check.Result {
+	if !found {
+		return check.Result{Outcome: check.OutcomeSkipped}
+	}
+	return state.Result()
+}
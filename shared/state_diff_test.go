@@ -0,0 +1,44 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffStates(t *testing.T) {
+	before := map[string]LastState{
+		"firewall": {UUID: "firewall", Name: "Firewall", Outcome: check.OutcomePass, State: true},
+		"ssh":      {UUID: "ssh", Name: "SSH", Outcome: check.OutcomeFail, Details: "Root login is enabled"},
+		"luks":     {UUID: "luks", Name: "Disk encryption", Outcome: check.OutcomeNotApplicable},
+		"updates":  {UUID: "updates", Name: "Updates", Outcome: check.OutcomeFail, Details: "3 updates"},
+		"sharing":  {UUID: "sharing", Name: "Sharing", Outcome: check.OutcomePass, State: true},
+		"legacy":   {UUID: "legacy", Name: "Legacy", State: true},
+	}
+	after := map[string]LastState{
+		"firewall": {UUID: "firewall", Name: "Firewall", Outcome: check.OutcomeFail, Details: "Firewall is off"},
+		"ssh":      {UUID: "ssh", Name: "SSH", Outcome: check.OutcomePass, State: true},
+		"luks":     {UUID: "luks", Name: "Disk encryption", Outcome: check.OutcomeFail},
+		"updates":  {UUID: "updates", Name: "Updates", Outcome: check.OutcomeFail, Details: "5 updates"},
+		"sharing":  {UUID: "sharing", Name: "Sharing", Outcome: check.OutcomeNotApplicable},
+		"legacy":   {UUID: "legacy", Name: "Legacy", Outcome: check.OutcomePass, State: true},
+		"new":      {UUID: "new", Name: "New", Outcome: check.OutcomeFail},
+	}
+
+	var got []string
+	for _, change := range DiffStates(before, after) {
+		got = append(got, string(change.Kind)+" "+change.UUID)
+	}
+	assert.Equal(t, []string{
+		"new-failure luks",
+		"new-failure firewall",
+		"new-failure new",
+		"new-pass ssh",
+		"runnable luks",
+		"not-runnable sharing",
+		"details updates",
+	}, got)
+
+	assert.Empty(t, DiffStates(before, before))
+}
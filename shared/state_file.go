@@ -0,0 +1,132 @@
+package shared
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pelletier/go-toml"
+)
+
+// stateSchemaVersion is the version of the state file format written by
+// this agent. Version 0 is the original format, a bare table of LastState
+// keyed by UUID.
+const stateSchemaVersion = 1
+
+// errNewerState is returned when the state file was written by a newer
+// agent, which is left alone instead of being treated as corrupt or
+// overwritten.
+var errNewerState = errors.New("state file was written by a newer version")
+
+// stateFile is the layout of the state file.
+type stateFile struct {
+	Version int
+	Checks  map[string]LastState
+}
+
+// encodeStates returns the state file content for states.
+func encodeStates(states map[string]LastState) ([]byte, error) {
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).Encode(stateFile{Version: stateSchemaVersion, Checks: states})
+	return buf.Bytes(), err
+}
+
+// decodeStates parses the state file content, migrating older versions of
+// the format.
+func decodeStates(content []byte) (map[string]LastState, error) {
+	tree, err := toml.LoadBytes(content)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := tree.Get("Version").(int64)
+
+	switch {
+	case version == 0:
+		// Version 0 has no Version key, every table is a check
+		legacy := map[string]LastState{}
+		if err := tree.Unmarshal(&legacy); err != nil {
+			return nil, err
+		}
+		return legacy, nil
+	case version > stateSchemaVersion:
+		return nil, fmt.Errorf("%w: schema version %d, expected at most %d", errNewerState, version, stateSchemaVersion)
+	}
+
+	var file stateFile
+	if err := tree.Unmarshal(&file); err != nil {
+		return nil, err
+	}
+	if file.Checks == nil {
+		file.Checks = map[string]LastState{}
+	}
+	return file.Checks, nil
+}
+
+// lockState takes an advisory lock that serializes access to the state file
+// between the CLI, the timer and the tray, and returns a function that
+// releases it. Readers share the lock, a writer holds it exclusively.
+func lockState(exclusive bool) (func(), error) {
+	return LockPath(StatePath+".lock", exclusive)
+}
+
+// LockPath takes an advisory lock on the lock file at path, creating it if
+// needed, and returns a function that releases it.
+func LockPath(path string, exclusive bool) (func(), error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file, exclusive); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		_ = unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// WriteFileAtomic replaces the file at path with content, so that readers
+// see either the old or the new content even if the agent crashes midway.
+func WriteFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself, not supported on every platform
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// ReadStateFile reads the checks from a state file, such as a copy of the
+// state file exported from an earlier run.
+func ReadStateFile(path string) (map[string]LastState, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStates(content)
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package shared
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile blocks until it holds a shared or exclusive flock on file.
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(file.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,259 @@
+package shared
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+)
+
+// The states are sealed so that edits to the state file are noticed. Each
+// state carries a MAC keyed with a secret kept next to the state file, which
+// catches edits made by hand or by tools that do not know the key. As the
+// user can read that key, the results of root checks are also signed by the
+// root helper with a key only root can read, so that a root check cannot be
+// made to pass without the helper having run it. The signature names the user
+// the helper ran the check for, and is only trusted for a while, so that a
+// signed result cannot be reused by another user or pasted back later on.
+var (
+	// HelperKeyPath holds the key the root helper signs results with.
+	HelperKeyPath = "/var/lib/paretosecurity/helper.key"
+	// HelperPublicKeyPath holds the public half of the helper key, which
+	// the agent verifies the results of root checks with.
+	HelperPublicKeyPath = "/var/lib/paretosecurity/helper.pub"
+	// helperPublicKeyOwner returns an error unless the owner of the file at
+	// HelperPublicKeyPath may be trusted with it.
+	helperPublicKeyOwner = isRootOwned
+)
+
+// IsRootCheck returns whether the check with the given UUID is run by the
+// root helper. It is set by the claims package, which knows the checks.
+var IsRootCheck = func(uuid string) bool { return false }
+
+// stateKeyPath returns the file the key the states are sealed with is kept
+// in.
+func stateKeyPath() string {
+	return StatePath + ".key"
+}
+
+// loadStateKey returns the key the states are sealed with. With create set,
+// a missing key is generated.
+func loadStateKey(create bool) ([]byte, error) {
+	key, err := os.ReadFile(stateKeyPath())
+	if os.IsNotExist(err) && create {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		// WriteFileAtomic creates the file readable only by the user
+		return key, WriteFileAtomic(stateKeyPath(), key)
+	}
+	return key, err
+}
+
+// sealedContent returns the bytes the MAC of s is over.
+func (s LastState) sealedContent() []byte {
+	evidence := s.Evidence
+	if len(evidence) == 0 {
+		evidence = nil
+	}
+	// Times are compared in whole seconds, as kept by the state file
+	content, _ := json.Marshal(struct {
+		UUID      string
+		Name      string
+		State     bool
+		Details   string
+		Outcome   check.Outcome
+		Evidence  map[string]string
+		CheckedAt int64
+		Signature string
+	}{s.UUID, s.Name, s.State, s.Details, s.Outcome, evidence, s.CheckedAt.Unix(), s.Signature})
+	return content
+}
+
+// seal returns the MAC of s with key.
+func (s LastState) seal(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(s.sealedContent())
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// maxHelperSignatureAge bounds how long the signature of the root helper on a
+// passing result is trusted, twice the interval of the hourly check timer. A
+// result saved while the check passed cannot be pasted back later on.
+const maxHelperSignatureAge = 2 * time.Hour
+
+// stateVerifier verifies states with the keys they were sealed and signed
+// with, as the user with the given UID at now.
+type stateVerifier struct {
+	key       []byte
+	helperKey ed25519.PublicKey
+	// asRoot is set if the agent runs as root, and runs root checks itself.
+	asRoot bool
+	uid    int
+	now    time.Time
+}
+
+// verify returns why s cannot be trusted, or an empty string if it can. The
+// result of a root check only counts as passing if the root helper recently
+// signed it for the user, unless the agent runs as root itself.
+func (v stateVerifier) verify(s LastState) string {
+	switch {
+	case s.MAC == "":
+		return "the result is not sealed, run the checks to refresh it"
+	case v.key == nil:
+		return "the key the result was sealed with is missing"
+	case !hmac.Equal([]byte(s.MAC), []byte(s.seal(v.key))):
+		return "the result was modified outside of the agent"
+	case v.asRoot || !s.Result().Passed() || !IsRootCheck(s.UUID):
+		return ""
+	case s.Signature == "":
+		return "the result is not signed by the root helper"
+	case v.helperKey == nil:
+		return "the root helper key is not available"
+	}
+	signature, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil || !ed25519.Verify(v.helperKey, helperSignedMessage(s.UUID, s.Result().Outcome, s.CheckedAt.Unix(), v.uid), signature) {
+		return "the signature of the root helper is invalid"
+	}
+	if v.now.Sub(s.CheckedAt) > maxHelperSignatureAge {
+		return "the result of the root helper is too old, run the checks to refresh it"
+	}
+	return ""
+}
+
+// sealStates sets the MAC of the states that were not found tampered with.
+// Tampered states keep their MAC, so that they are still found tampered
+// with when read again.
+func sealStates(states map[string]LastState) error {
+	key, err := loadStateKey(true)
+	if err != nil {
+		return fmt.Errorf("failed to load the state key: %w", err)
+	}
+	for uuid, state := range states {
+		if state.Tampered == "" {
+			state.MAC = state.seal(key)
+			states[uuid] = state
+		}
+	}
+	return nil
+}
+
+// verifyStates marks the states that cannot be trusted as tampered with.
+func verifyStates(states map[string]LastState) {
+	key, err := loadStateKey(false)
+	if err != nil {
+		key = nil
+	}
+	helperKey, _ := loadHelperPublicKey()
+	verifier := stateVerifier{key: key, helperKey: helperKey, asRoot: IsRoot(), uid: os.Getuid(), now: time.Now()}
+	for uuid, state := range states {
+		state.Tampered = verifier.verify(state)
+		states[uuid] = state
+	}
+}
+
+// helperSignedMessage returns the bytes the root helper signs for the result
+// of a check it ran for the user with the given UID.
+func helperSignedMessage(uuid string, outcome check.Outcome, signedAt int64, uid int) []byte {
+	return []byte(uuid + "\n" + string(outcome) + "\n" + strconv.FormatInt(signedAt, 10) + "\n" + strconv.Itoa(uid))
+}
+
+// LoadHelperKey returns the key the root helper signs results with,
+// generating it and writing its public half to HelperPublicKeyPath if
+// needed.
+func LoadHelperKey() (ed25519.PrivateKey, error) {
+	encoded, err := os.ReadFile(HelperKeyPath)
+	if os.IsNotExist(err) {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		if err != nil {
+			return nil, err
+		}
+		publicDER, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(HelperKeyPath), 0o755); err != nil {
+			return nil, err
+		}
+		if err := WriteFileAtomic(HelperKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})); err != nil {
+			return nil, err
+		}
+		if err := WriteFileAtomic(HelperPublicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})); err != nil {
+			return nil, err
+		}
+		return private, os.Chmod(HelperPublicKeyPath, 0o644)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, errors.New("invalid helper key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("helper key is a %T, not an Ed25519 key", key)
+	}
+	return private, nil
+}
+
+// loadHelperPublicKey returns the key results of root checks are verified
+// with. It must be owned by root, as anyone able to replace it could sign
+// results of their own.
+func loadHelperPublicKey() (ed25519.PublicKey, error) {
+	info, err := os.Stat(HelperPublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := helperPublicKeyOwner(info); err != nil {
+		return nil, fmt.Errorf("refusing to use %s: %w", HelperPublicKeyPath, err)
+	}
+	encoded, err := os.ReadFile(HelperPublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, errors.New("invalid helper public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("helper public key is a %T, not an Ed25519 key", key)
+	}
+	return public, nil
+}
+
+// SignHelperResult signs res, the result of the check with the given UUID
+// run for the user with the given UID, with the helper key. The signature
+// vouches for the outcome at checkedAt.
+func SignHelperResult(key ed25519.PrivateKey, uuid string, uid int, res *HelperResult, checkedAt time.Time) {
+	res.SignedAt = checkedAt.Unix()
+	res.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, helperSignedMessage(uuid, res.Outcome, res.SignedAt, uid)))
+}
@@ -0,0 +1,188 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ParetoSecurity/agent/check"
+	"github.com/stretchr/testify/assert"
+)
+
+// sealCheck implements check.Check for testing.
+type sealCheck struct{ uuid string }
+
+func (c *sealCheck) Name() string                  { return "Check " + c.uuid }
+func (c *sealCheck) PassedMessage() string         { return "passed" }
+func (c *sealCheck) FailedMessage() string         { return "failed" }
+func (c *sealCheck) Run(ctx context.Context) error { return nil }
+func (c *sealCheck) Passed() bool                  { return true }
+func (c *sealCheck) IsRunnable() bool              { return true }
+func (c *sealCheck) UUID() string                  { return c.uuid }
+func (c *sealCheck) Status() string                { return "passed" }
+func (c *sealCheck) RequiresRoot() bool            { return true }
+
+func resetStates(t *testing.T) {
+	StatePath = filepath.Join(t.TempDir(), "test.state")
+	mutex.Lock()
+	states = make(map[string]LastState)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+}
+
+func TestStateSeal(t *testing.T) {
+	resetStates(t)
+	checkedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	UpdateLastState(LastState{UUID: "a", Name: "A", State: false, Outcome: check.OutcomeFail, Details: "bad", CheckedAt: checkedAt})
+	UpdateLastState(LastState{UUID: "b", Name: "B", State: true, Outcome: check.OutcomePass, Details: "good", CheckedAt: checkedAt})
+	assert.NoError(t, CommitLastState())
+
+	info, err := os.Stat(stateKeyPath())
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Results read back as committed are trusted
+	mutex.Lock()
+	states = make(map[string]LastState)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+	state, _, _ := GetLastState("b")
+	assert.Empty(t, state.Tampered)
+	assert.True(t, state.Result().Passed())
+
+	// Flipping a failing check to passing is noticed
+	content, err := os.ReadFile(StatePath)
+	assert.NoError(t, err)
+	edited := strings.Replace(string(content), `Outcome = "fail"`, `Outcome = "pass"`, 1)
+	edited = strings.Replace(edited, "State = false", "State = true", 1)
+	assert.NoError(t, os.WriteFile(StatePath, []byte(edited), 0o600))
+	assert.NoError(t, os.Chtimes(StatePath, time.Now().Add(time.Second), time.Now().Add(time.Second)))
+
+	state, _, _ = GetLastState("a")
+	assert.Equal(t, "the result was modified outside of the agent", state.Tampered)
+	assert.False(t, state.Result().Passed())
+	assert.Equal(t, check.OutcomeError, state.Result().Outcome)
+	assert.False(t, AllChecksPassed())
+
+	// Tampered results stay tampered when the states are committed again,
+	// until the check is run
+	assert.NoError(t, CommitLastState())
+	mutex.Lock()
+	states = make(map[string]LastState)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+	state, _, _ = GetLastState("a")
+	assert.NotEmpty(t, state.Tampered)
+	state, _, _ = GetLastState("b")
+	assert.Empty(t, state.Tampered)
+}
+
+func TestStateSealMissingKey(t *testing.T) {
+	resetStates(t)
+	UpdateLastState(LastState{UUID: "a", State: true, Outcome: check.OutcomePass})
+	assert.NoError(t, CommitLastState())
+	assert.NoError(t, os.Remove(stateKeyPath()))
+
+	mutex.Lock()
+	states = make(map[string]LastState)
+	lastModTime = time.Time{}
+	mutex.Unlock()
+	state, _, _ := GetLastState("a")
+	assert.Equal(t, "the key the result was sealed with is missing", state.Tampered)
+}
+
+func TestVerifyRootCheck(t *testing.T) {
+	dir := t.TempDir()
+	HelperKeyPath = filepath.Join(dir, "helper.key")
+	HelperPublicKeyPath = filepath.Join(dir, "helper.pub")
+	owner := helperPublicKeyOwner
+	defer func() { helperPublicKeyOwner = owner }()
+	isRootCheck := IsRootCheck
+	IsRootCheck = func(uuid string) bool { return uuid == "root" }
+	defer func() { IsRootCheck = isRootCheck }()
+
+	helperKey, err := LoadHelperKey()
+	assert.NoError(t, err)
+	info, err := os.Stat(HelperKeyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	again, err := LoadHelperKey()
+	assert.NoError(t, err)
+	assert.True(t, helperKey.Equal(again), "the key is kept")
+	// The key of a helper that does not run as root is not trusted
+	helperPublicKeyOwner = func(os.FileInfo) error { return errors.New("not owned by root") }
+	_, err = loadHelperPublicKey()
+	assert.Error(t, err)
+	helperPublicKeyOwner = func(os.FileInfo) error { return nil }
+	public, err := loadHelperPublicKey()
+	assert.NoError(t, err)
+	assert.True(t, public.Equal(helperKey.Public()))
+
+	key := []byte("state key")
+	checkedAt := time.Now().Truncate(time.Second)
+	res := HelperResult{Result: check.Result{Outcome: check.OutcomePass}}
+	SignHelperResult(helperKey, "root", 1000, &res, checkedAt)
+	signed := LastState{UUID: "root", State: true, Outcome: check.OutcomePass, CheckedAt: checkedAt, Signature: res.Signature}
+	sealed := func(s LastState) LastState {
+		s.MAC = s.seal(key)
+		return s
+	}
+	verifier := stateVerifier{key: key, helperKey: public, uid: 1000, now: checkedAt.Add(time.Minute)}
+
+	assert.Empty(t, verifier.verify(sealed(signed)))
+
+	unsigned := signed
+	unsigned.Signature = ""
+	assert.Equal(t, "the result is not signed by the root helper", verifier.verify(sealed(unsigned)))
+	asRoot := verifier
+	asRoot.asRoot = true
+	assert.Empty(t, asRoot.verify(sealed(unsigned)), "root runs root checks itself")
+	noKey := verifier
+	noKey.helperKey = nil
+	assert.Equal(t, "the root helper key is not available", noKey.verify(sealed(signed)))
+
+	// A signature for another time, check or user does not carry over
+	moved := signed
+	moved.CheckedAt = checkedAt.Add(time.Hour)
+	assert.Equal(t, "the signature of the root helper is invalid", verifier.verify(sealed(moved)))
+	other := signed
+	other.UUID = "other"
+	assert.Empty(t, verifier.verify(sealed(other)), "only root checks need a signature")
+	otherUser := verifier
+	otherUser.uid = 1001
+	assert.Equal(t, "the signature of the root helper is invalid", otherUser.verify(sealed(signed)))
+
+	// An old signature cannot be pasted back once the check fails
+	later := verifier
+	later.now = checkedAt.Add(maxHelperSignatureAge + time.Minute)
+	assert.Equal(t, "the result of the root helper is too old, run the checks to refresh it", later.verify(sealed(signed)))
+
+	// Failing results are never trusted more than they should be
+	failing := unsigned
+	failing.State = false
+	failing.Outcome = check.OutcomeFail
+	assert.Empty(t, verifier.verify(sealed(failing)))
+	assert.Empty(t, later.verify(sealed(failing)))
+}
+
+func TestNewLastStateTakesHelperSignature(t *testing.T) {
+	signedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	helperSignaturesMu.Lock()
+	helperSignatures["root"] = HelperResult{Result: check.Result{Outcome: check.OutcomePass}, SignedAt: signedAt.Unix(), Signature: "signature"}
+	helperSignatures["stale"] = HelperResult{Result: check.Result{Outcome: check.OutcomePass}, SignedAt: signedAt.Unix(), Signature: "signature"}
+	helperSignaturesMu.Unlock()
+
+	state := NewLastState(&sealCheck{uuid: "root"}, check.Result{Outcome: check.OutcomePass})
+	assert.Equal(t, "signature", state.Signature)
+	assert.True(t, signedAt.Equal(state.CheckedAt), "the result is as old as the helper's run")
+	state = NewLastState(&sealCheck{uuid: "root"}, check.Result{Outcome: check.OutcomePass})
+	assert.Empty(t, state.Signature, "signatures are used once")
+
+	// A signature for another outcome does not apply
+	state = NewLastState(&sealCheck{uuid: "stale"}, check.Result{Outcome: check.OutcomeFail})
+	assert.Empty(t, state.Signature)
+}
@@ -2,39 +2,12 @@ package shared
 
 import (
 	"fmt"
-	"net"
 	"os"
 	"testing"
 
 	"strings"
-
-	"github.com/google/uuid"
 )
 
-func SystemUUID() (string, error) {
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return "", err
-	}
-
-	for _, iface := range interfaces {
-
-		// Skip loopback interfaces
-		if iface.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-
-		if len(iface.HardwareAddr) >= 6 {
-			hwAddr := iface.HardwareAddr
-			// Create a namespace UUID from hardware address
-			nsUUID := uuid.NewSHA1(uuid.NameSpaceOID, hwAddr)
-			return nsUUID.String(), nil
-		}
-	}
-
-	return "", fmt.Errorf("no network interface found")
-}
-
 func SystemDevice() (string, error) {
 	content, err := ReadFile("/sys/devices/virtual/dmi/id/product_name")
 	if err != nil {
@@ -0,0 +1,26 @@
+package shared
+
+import (
+	"time"
+
+	"github.com/caarlos0/log"
+)
+
+// DefaultCheckTimeout is how long a check may run unless its timeout is
+// configured in pareto.toml.
+const DefaultCheckTimeout = 30 * time.Second
+
+// CheckTimeout returns the configured timeout of the check with the given
+// UUID. Missing, invalid or non-positive timeouts use DefaultCheckTimeout.
+func CheckTimeout(uuid string) time.Duration {
+	status, found := Config.Checks[uuid]
+	if !found || status.Timeout == "" {
+		return DefaultCheckTimeout
+	}
+	timeout, err := time.ParseDuration(status.Timeout)
+	if err != nil || timeout <= 0 {
+		log.WithField("uuid", uuid).WithField("timeout", status.Timeout).Warn("invalid check timeout, using the default")
+		return DefaultCheckTimeout
+	}
+	return timeout
+}
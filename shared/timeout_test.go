@@ -0,0 +1,39 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTimeout(t *testing.T) {
+	Config = ParetoConfig{
+		Checks: map[string]CheckStatus{
+			"custom":   {Timeout: "45s"},
+			"invalid":  {Timeout: "soon"},
+			"negative": {Timeout: "-5s"},
+			"exempted": {Disabled: true},
+		},
+	}
+	defer func() { Config = ParetoConfig{} }()
+
+	assert.Equal(t, 45*time.Second, CheckTimeout("custom"))
+	assert.Equal(t, DefaultCheckTimeout, CheckTimeout("invalid"))
+	assert.Equal(t, DefaultCheckTimeout, CheckTimeout("negative"))
+	assert.Equal(t, DefaultCheckTimeout, CheckTimeout("exempted"))
+	assert.Equal(t, DefaultCheckTimeout, CheckTimeout("unknown"))
+}
+
+func TestHelperRequestCheckTimeout(t *testing.T) {
+	req := HelperRequest{Timeouts: map[string]time.Duration{
+		"custom":   45 * time.Second,
+		"negative": -time.Second,
+		"too-long": time.Hour,
+	}}
+
+	assert.Equal(t, 45*time.Second, req.CheckTimeout("custom"))
+	assert.Equal(t, DefaultCheckTimeout, req.CheckTimeout("negative"))
+	assert.Equal(t, DefaultCheckTimeout, req.CheckTimeout("too-long"))
+	assert.Equal(t, DefaultCheckTimeout, req.CheckTimeout("unknown"))
+}
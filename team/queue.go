@@ -0,0 +1,173 @@
+package team
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/caarlos0/log"
+	"github.com/carlmjohnson/requests"
+
+	shared "github.com/ParetoSecurity/agent/shared"
+)
+
+const (
+	// maxQueueAge is how long a report may wait in the queue before it is
+	// dropped as too old to matter.
+	maxQueueAge = 7 * 24 * time.Hour
+	// maxQueueLength bounds the queue, dropping the oldest reports first.
+	maxQueueLength = 100
+	// backoffBase and backoffMax bound the wait between automatic retries.
+	backoffBase = time.Minute
+	backoffMax  = 6 * time.Hour
+)
+
+// jitter returns a random number in [0, 1), replaced in tests.
+var jitter = rand.Float64
+
+// queuedReport is a report waiting to be sent to the team.
+type queuedReport struct {
+	QueuedAt time.Time `json:"queuedAt"`
+	Report   Report    `json:"report"`
+}
+
+// reportQueue holds the reports that could not be sent yet, oldest first.
+type reportQueue struct {
+	Reports []queuedReport `json:"reports"`
+	// Attempts counts the failed flushes since the last one that succeeded.
+	Attempts int `json:"attempts"`
+	// NextAttempt is when the queue may be retried automatically.
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+// queuePath returns the file the queue is kept in, next to the state file.
+func queuePath() string {
+	return shared.StatePath + ".queue"
+}
+
+// withQueue loads the queue under a lock, calls update and saves the queue
+// afterwards, even if update failed.
+func withQueue(update func(q *reportQueue) error) error {
+	unlock, err := shared.LockPath(queuePath()+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	q := &reportQueue{}
+	content, err := os.ReadFile(queuePath())
+	if err == nil {
+		if err := json.Unmarshal(content, q); err != nil {
+			log.WithError(err).Warn("Dropping corrupt report queue")
+			q = &reportQueue{}
+		}
+	}
+
+	updateErr := update(q)
+	content, err = json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	if err := shared.WriteFileAtomic(queuePath(), content); err != nil {
+		return err
+	}
+	return updateErr
+}
+
+// enqueue adds report to the queue. A report with the same SignificantChange
+// as the newest queued one replaces it, and reports older than maxQueueAge
+// are dropped.
+func (q *reportQueue) enqueue(report Report, now time.Time) {
+	q.expire(now)
+	if n := len(q.Reports); n > 0 && q.Reports[n-1].Report.SignificantChange == report.SignificantChange {
+		q.Reports = q.Reports[:n-1]
+	}
+	q.Reports = append(q.Reports, queuedReport{QueuedAt: now, Report: report})
+	if len(q.Reports) > maxQueueLength {
+		q.Reports = q.Reports[len(q.Reports)-maxQueueLength:]
+	}
+}
+
+// expire drops the reports queued more than maxQueueAge before now.
+func (q *reportQueue) expire(now time.Time) {
+	kept := q.Reports[:0]
+	for _, queued := range q.Reports {
+		if now.Sub(queued.QueuedAt) <= maxQueueAge {
+			kept = append(kept, queued)
+		} else {
+			log.WithField("queuedAt", queued.QueuedAt).Warn("Dropping team report that is too old")
+		}
+	}
+	q.Reports = kept
+}
+
+// flush sends the queued reports in order and stops at the first one that
+// fails, scheduling the next automatic attempt with exponential backoff.
+// Reports the server rejects outright are dropped, as retrying them cannot
+// succeed. While the keyring holding the device key is locked, the reports
+// stay queued without counting as a failed attempt.
+func (q *reportQueue) flush(ctx context.Context, now time.Time) error {
+	q.expire(now)
+	for len(q.Reports) > 0 {
+		err := sendReport(ctx, http.MethodPatch, q.Reports[0].Report)
+		if errors.Is(err, shared.ErrDeviceKeyLocked) {
+			// Reports wait for the user to unlock the keyring, which no
+			// backoff changes, and the run does not fail meanwhile
+			log.WithField("queued", len(q.Reports)).Info("Team reports are queued until the keyring holding the device key is unlocked")
+			return nil
+		}
+		if err != nil && !isPermanent(err) {
+			q.Attempts++
+			q.NextAttempt = now.Add(backoff(q.Attempts))
+			return err
+		}
+		if err != nil {
+			log.WithError(err).Warn("Dropping team report rejected by the server")
+		}
+		q.Reports = q.Reports[1:]
+	}
+	q.Attempts = 0
+	q.NextAttempt = time.Time{}
+	return nil
+}
+
+// isPermanent returns true if err is a client error that retrying the same
+// request will not fix.
+func isPermanent(err error) bool {
+	var responseErr *requests.ResponseError
+	if !errors.As(err, &responseErr) {
+		return false
+	}
+	code := responseErr.StatusCode
+	return code >= http.StatusBadRequest && code < http.StatusInternalServerError &&
+		code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+// backoff returns the wait before the retry after the given number of
+// failed attempts: it doubles from backoffBase up to backoffMax, randomized
+// by half either way so that devices do not retry in lockstep.
+func backoff(attempts int) time.Duration {
+	wait := backoffBase
+	for i := 1; i < attempts && wait < backoffMax; i++ {
+		wait *= 2
+	}
+	wait = min(wait, backoffMax)
+	return wait/2 + time.Duration(jitter()*float64(wait))
+}
+
+// FlushQueue sends the reports queued while the team could not be reached.
+// Unless force is set, nothing is sent before the backoff after the last
+// failed attempt has passed.
+func FlushQueue(ctx context.Context, force bool) error {
+	return withQueue(func(q *reportQueue) error {
+		now := time.Now()
+		if len(q.Reports) == 0 || !force && now.Before(q.NextAttempt) {
+			return nil
+		}
+		return q.flush(ctx, now)
+	})
+}
@@ -0,0 +1,205 @@
+package team
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	shared "github.com/ParetoSecurity/agent/shared"
+	"github.com/ParetoSecurity/agent/team/teamtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTeamServer starts a local stand-in for the team dashboard and points
+// the reports at it.
+func newTeamServer(t *testing.T) *teamtest.Server {
+	server := teamtest.NewServer("testTeam", "testToken")
+	t.Cleanup(server.Close)
+
+	endpoints := shared.Config.Endpoints
+	shared.Config.Endpoints = &shared.EndpointSettings{Report: server.URL}
+	t.Cleanup(func() { shared.Config.Endpoints = endpoints })
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	shared.DeviceKeyPath = filepath.Join(t.TempDir(), "device.key")
+	shared.Config.TeamID = server.TeamID
+	shared.Config.AuthToken = server.AuthToken
+	return server
+}
+
+func loadQueue(t *testing.T) reportQueue {
+	var q reportQueue
+	assert.NoError(t, withQueue(func(loaded *reportQueue) error {
+		q = *loaded
+		return nil
+	}))
+	return q
+}
+
+func TestEnqueue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	q := &reportQueue{}
+
+	q.enqueue(Report{SignificantChange: "a"}, now.Add(-8*24*time.Hour))
+	q.enqueue(Report{SignificantChange: "b", LastCheck: "first"}, now)
+	assert.Len(t, q.Reports, 1, "reports older than maxQueueAge are dropped")
+
+	q.enqueue(Report{SignificantChange: "b", LastCheck: "second"}, now)
+	assert.Len(t, q.Reports, 1, "a report without a significant change replaces the last one")
+	assert.Equal(t, "second", q.Reports[0].Report.LastCheck)
+
+	q.enqueue(Report{SignificantChange: "c"}, now)
+	assert.Len(t, q.Reports, 2)
+
+	for i := 0; i < maxQueueLength+10; i++ {
+		q.enqueue(Report{SignificantChange: string(rune('A' + i%2))}, now)
+	}
+	assert.Len(t, q.Reports, maxQueueLength)
+}
+
+func TestBackoff(t *testing.T) {
+	defer func(j func() float64) { jitter = j }(jitter)
+
+	jitter = func() float64 { return 0.5 }
+	assert.Equal(t, backoffBase, backoff(1))
+	assert.Equal(t, 2*backoffBase, backoff(2))
+	assert.Equal(t, 8*backoffBase, backoff(4))
+	assert.Equal(t, backoffMax, backoff(100))
+
+	jitter = func() float64 { return 0 }
+	assert.Equal(t, backoffBase/2, backoff(1))
+	jitter = func() float64 { return 0.999 }
+	assert.Less(t, backoff(100), backoffMax*3/2)
+}
+
+func TestIsPermanent(t *testing.T) {
+	server := newTeamServer(t)
+	server.FailWith(http.StatusBadRequest)
+	ctx := context.Background()
+
+	assert.True(t, isPermanent(sendReport(ctx, http.MethodPatch, Report{})))
+	for _, status := range []int{http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway} {
+		server.FailWith(status)
+		assert.False(t, isPermanent(sendReport(ctx, http.MethodPatch, Report{})), status)
+	}
+	assert.False(t, isPermanent(context.Canceled))
+}
+
+func TestReportToTeamQueues(t *testing.T) {
+	defer func(j func() float64) { jitter = j }(jitter)
+	jitter = func() float64 { return 0.5 }
+	server := newTeamServer(t)
+	server.FailWith(http.StatusServiceUnavailable)
+
+	assert.Error(t, ReportToTeam(false, ""))
+	q := loadQueue(t)
+	assert.Len(t, q.Reports, 1)
+	assert.Equal(t, 1, q.Attempts)
+	assert.WithinDuration(t, time.Now().Add(backoffBase), q.NextAttempt, 5*time.Second)
+
+	// Automatic retries wait for the backoff
+	server.FailWith(0)
+	assert.NoError(t, FlushQueue(context.Background(), false))
+	assert.Empty(t, server.Reports())
+	assert.Len(t, loadQueue(t).Reports, 1)
+
+	// The next check or the network coming up sends the queue right away
+	assert.NoError(t, FlushQueue(context.Background(), true))
+	assert.Len(t, server.Reports(), 1)
+	q = loadQueue(t)
+	assert.Empty(t, q.Reports)
+	assert.Zero(t, q.Attempts)
+	assert.True(t, q.NextAttempt.IsZero())
+}
+
+func TestFlushQueueInOrder(t *testing.T) {
+	server := newTeamServer(t)
+	now := time.Now()
+	assert.NoError(t, withQueue(func(q *reportQueue) error {
+		q.enqueue(Report{SignificantChange: "a"}, now)
+		q.enqueue(Report{SignificantChange: "b"}, now)
+		q.Attempts = 3
+		q.NextAttempt = now.Add(time.Hour)
+		return nil
+	}))
+
+	assert.NoError(t, ReportToTeam(false, ""))
+	reports := server.Reports()
+	assert.Len(t, reports, 3)
+	assert.Equal(t, "a", reports[0]["significantChange"])
+	assert.Equal(t, "b", reports[1]["significantChange"])
+	assert.Empty(t, loadQueue(t).Reports)
+}
+
+func TestFlushQueueDropsRejected(t *testing.T) {
+	newTeamServer(t).FailWith(http.StatusUnprocessableEntity)
+	assert.NoError(t, ReportToTeam(false, ""))
+	q := loadQueue(t)
+	assert.Empty(t, q.Reports)
+	assert.Zero(t, q.Attempts)
+}
+
+func TestSignedReports(t *testing.T) {
+	server := newTeamServer(t)
+	server.RequireSignatures = true
+
+	assert.NoError(t, ReportToTeam(true, ""))
+	assert.NoError(t, ReportToTeam(false, ""))
+	requests := server.Requests()
+	assert.Len(t, requests, 2)
+	assert.NotEmpty(t, requests[0].Body["publicKey"], "the key is registered when linking")
+
+	// A key the team does not know is rejected, and the report dropped
+	_, err := shared.GenerateDeviceKey()
+	assert.NoError(t, err)
+	assert.NoError(t, ReportToTeam(false, ""))
+	assert.Len(t, server.Requests(), 2)
+	assert.Empty(t, loadQueue(t).Reports)
+
+	// Devices without a key send unsigned reports
+	assert.NoError(t, shared.DeleteDeviceKey())
+	assert.NoError(t, ReportToTeam(false, ""))
+	assert.Len(t, server.Requests(), 2)
+}
+
+func TestLockedKeyring(t *testing.T) {
+	server := newTeamServer(t)
+	loadDeviceKey = func() (ed25519.PrivateKey, error) { return nil, shared.ErrDeviceKeyLocked }
+	defer func() { loadDeviceKey = shared.LoadDeviceKey }()
+
+	// The run does not fail while the keyring is locked
+	assert.NoError(t, ReportToTeam(false, ""))
+	assert.NoError(t, ReportToTeam(false, ""))
+	assert.Empty(t, server.Requests())
+	q := loadQueue(t)
+	assert.Len(t, q.Reports, 1)
+	assert.Zero(t, q.Attempts)
+
+	loadDeviceKey = shared.LoadDeviceKey
+	assert.NoError(t, FlushQueue(context.Background(), false))
+	assert.Len(t, server.Reports(), 1)
+	assert.Empty(t, loadQueue(t).Reports)
+}
+
+func TestSignRequest(t *testing.T) {
+	public, key, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+
+	headers, err := signRequest(key, http.MethodPatch, "/api/v1/team/team/device", []byte(`{}`), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000", headers[headerTimestamp])
+	assert.Len(t, headers[headerNonce], 32)
+	signature, err := base64.StdEncoding.DecodeString(headers[headerSignature])
+	assert.NoError(t, err)
+	message := signedMessage(http.MethodPatch, "/api/v1/team/team/device", headers[headerTimestamp], headers[headerNonce], []byte(`{}`))
+	assert.Equal(t, "PATCH\n/api/v1/team/team/device\n1700000000\n"+headers[headerNonce]+"\n{}", string(message))
+	assert.True(t, ed25519.Verify(public, message, signature))
+
+	other, err := signRequest(key, http.MethodPatch, "/api/v1/team/team/device", []byte(`{}`), now)
+	assert.NoError(t, err)
+	assert.NotEqual(t, headers[headerNonce], other[headerNonce], "every request has its own nonce")
+}
@@ -3,7 +3,11 @@ package team
 import (
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -11,11 +15,13 @@ import (
 	"github.com/carlmjohnson/requests"
 	"github.com/davecgh/go-spew/spew"
 
+	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
 	shared "github.com/ParetoSecurity/agent/shared"
 )
 
-const reportURL = "https://dash.paretosecurity.com"
+// reportTimeout bounds a single request to the team dashboard.
+const reportTimeout = 30 * time.Second
 
 type Report struct {
 	PassedCount       int                    `json:"passedCount"`
@@ -26,32 +32,87 @@ type Report struct {
 	LastCheck         string                 `json:"lastCheck"`
 	SignificantChange string                 `json:"significantChange"`
 	State             map[string]string      `json:"state"`
+	// FailingSince holds, for each failing check, when it started failing
+	// according to the local history.
+	FailingSince map[string]string `json:"failingSince,omitempty"`
+	// Profile is the name of the profile the checks were selected by,
+	// empty if all checks were run.
+	Profile string `json:"profile,omitempty"`
+	// Tampered holds, for each check whose result failed verification, why
+	// it cannot be trusted. Such checks are reported as failing.
+	Tampered map[string]string `json:"tampered,omitempty"`
+}
+
+// lastResult returns the Result recorded for chk by the last run. Checks
+// without one have not run yet, such as those added since, and are reported
+// as skipped, as are exempted checks.
+func lastResult(chk check.Check) check.Result {
+	if exemption, ok := shared.GetExemption(chk.UUID()); ok {
+		return check.Result{Outcome: check.OutcomeSkipped, Reason: exemption.Reason()}
+	}
+	if state, found, _ := shared.GetLastState(chk.UUID()); found {
+		return state.Result()
+	}
+	return check.Result{Outcome: check.OutcomeSkipped, Reason: "Check has not run yet"}
 }
 
 // NowReport compiles and returns a Report that summarizes the results of all runnable checks.
 func NowReport(all []claims.Claim) Report {
+	report := reportOf(all, lastResult)
+	for _, claim := range all {
+		for _, chk := range claim.Checks {
+			state, found, _ := shared.GetLastState(chk.UUID())
+			if found && state.Tampered != "" && !shared.IsCheckExempted(chk.UUID()) {
+				if report.Tampered == nil {
+					report.Tampered = map[string]string{}
+				}
+				report.Tampered[chk.UUID()] = state.Tampered
+			}
+		}
+	}
+	return report
+}
+
+// StatesReport compiles the Report that states, such as those of an earlier
+// run, would have produced. Checks missing from states count as off.
+func StatesReport(all []claims.Claim, states map[string]shared.LastState) Report {
+	return reportOf(all, func(chk check.Check) check.Result {
+		if state, found := states[chk.UUID()]; found {
+			return state.Result()
+		}
+		return check.Result{Outcome: check.OutcomeSkipped}
+	})
+}
+
+// reportOf compiles the Report of the checks in all, given their results.
+func reportOf(all []claims.Claim, result func(check.Check) check.Result) Report {
 	passed := 0
 	failed := 0
 	disabled := 0
 	disabledSeed, _ := shared.SystemUUID()
 	failedSeed, _ := shared.SystemUUID()
 	checkStates := make(map[string]string)
+	failingSince := make(map[string]string)
+	history := shared.FailingSince()
 
 	for _, claim := range all {
-		for _, check := range claim.Checks {
-			if check.IsRunnable() {
-				if check.Passed() {
-					passed++
-					checkStates[check.UUID()] = "pass"
-				} else {
-					failed++
-					failedSeed += check.UUID()
-					checkStates[check.UUID()] = "fail"
+		for _, chk := range claim.Checks {
+			res := result(chk)
+			switch {
+			case res.Passed():
+				passed++
+				checkStates[chk.UUID()] = "pass"
+			case res.Failed():
+				failed++
+				failedSeed += chk.UUID()
+				checkStates[chk.UUID()] = "fail"
+				if since, found := history[chk.UUID()]; found {
+					failingSince[chk.UUID()] = since.Format(time.RFC3339)
 				}
-			} else {
+			default:
 				disabled++
-				disabledSeed += check.UUID()
-				checkStates[check.UUID()] = "off"
+				disabledSeed += chk.UUID()
+				checkStates[chk.UUID()] = "off"
 			}
 		}
 	}
@@ -66,35 +127,97 @@ func NowReport(all []claims.Claim) Report {
 		LastCheck:         time.Now().Format(time.RFC3339),
 		SignificantChange: hex.EncodeToString(significantChange[:]),
 		State:             checkStates,
+		FailingSince:      failingSince,
+	}
+}
+
+// ReportToTeam sends the device to the team when initial is true, and
+// otherwise a report of the checks of the named profile. An empty profile
+// reports all checks. Reports are queued first, so that those that cannot be
+// sent are retried on the next run instead of being lost.
+func ReportToTeam(initial bool, profile string) error {
+	if initial {
+		device := shared.CurrentReportingDevice()
+		public, err := shared.GenerateDeviceKey()
+		if err != nil {
+			return err
+		}
+		device.PublicKey = base64.StdEncoding.EncodeToString(public)
+		return sendReport(context.Background(), http.MethodPut, device)
 	}
+
+	claimsToReport, err := claims.SelectProfile(profile)
+	if err != nil {
+		return err
+	}
+	report := NowReport(claimsToReport)
+	report.Profile = profile
+	return withQueue(func(q *reportQueue) error {
+		now := time.Now()
+		q.enqueue(report, now)
+		if err := q.flush(context.Background(), now); err != nil {
+			log.WithField("queued", len(q.Reports)).Info("Team report queued, it will be sent once the team can be reached")
+			return err
+		}
+		return nil
+	})
 }
 
-// ReportAndSave generates a report and saves it to the configuration file.
-func ReportToTeam(initial bool) error {
-	var report interface{}
+// loadDeviceKey returns the key reports are signed with, see
+// shared.LoadDeviceKey.
+var loadDeviceKey = shared.LoadDeviceKey
+
+// sendReport sends report to the team with the given method, signed with
+// the device key. Devices linked before reports were signed have no key and
+// send them unsigned.
+func sendReport(ctx context.Context, method string, report any) error {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+
+	endpoints := shared.Endpoints()
+	client, err := endpoints.HTTPClient()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/team/%s/device", shared.Config.TeamID)
+	var signature map[string]string
+	key, err := loadDeviceKey()
+	switch {
+	case errors.Is(err, shared.ErrNoDeviceKey):
+		log.WithError(err).Warn("Sending an unsigned team report")
+	case err != nil:
+		return err
+	default:
+		if signature, err = signRequest(key, method, path, body, time.Now()); err != nil {
+			return err
+		}
+	}
 
 	res := ""
 	errRes := ""
-	method := http.MethodPatch
-	if initial {
-		method = http.MethodPut
-		report = shared.CurrentReportingDevice()
-	} else {
-		report = NowReport(claims.All)
-	}
 	log.Debug(spew.Sdump(report))
-	err := requests.URL(reportURL).
-		Pathf("/api/v1/team/%s/device", shared.Config.TeamID).
+	request := requests.URL(endpoints.ReportURL()).
+		Client(client).
+		Path(path).
 		Method(method).
-		Header("X-Device-Auth", "Bearer "+shared.Config.AuthToken).
-		BodyJSON(&report).
+		Header("X-Device-Auth", "Bearer "+shared.Config.AuthToken)
+	for header, value := range signature {
+		request.Header(header, value)
+	}
+	err = request.
+		BodyBytes(body).
+		ContentType("application/json").
 		ToString(&res).
 		AddValidator(
 			requests.ValidatorHandler(
 				requests.DefaultValidator,
 				requests.ToString(&errRes),
 			)).
-		Fetch(context.Background())
+		Fetch(ctx)
 	if err != nil {
 		log.WithField("response", errRes).
 			WithError(err).
@@ -1,8 +1,14 @@
 package team
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ParetoSecurity/agent/check"
 	"github.com/ParetoSecurity/agent/claims"
@@ -24,7 +30,7 @@ type dummyCheck struct {
 
 func (d *dummyCheck) IsRunnable() bool { return d.runnable }
 func (d *dummyCheck) Name() string     { return d.name }
-func (d *dummyCheck) Run() error {
+func (d *dummyCheck) Run(ctx context.Context) error {
 	atomic.StoreInt32(&d.runCalled, 1)
 	return d.runErr
 }
@@ -48,6 +54,8 @@ func TestNowReportEmpty(t *testing.T) {
 }
 
 func TestNowReportCounts(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+
 	// Prepare one claim with three checks:
 	// c1 -> runnable and passed, c2 -> runnable but failed, c3 -> disabled.
 	c1 := dummyCheck{
@@ -85,6 +93,9 @@ func TestNowReportCounts(t *testing.T) {
 			&c3,
 		}},
 	}
+	// Only the checks that ran have a recorded state
+	shared.UpdateLastState(shared.LastState{UUID: "check1", Name: "c1", Outcome: check.OutcomePass})
+	shared.UpdateLastState(shared.LastState{UUID: "check2", Name: "c2", Outcome: check.OutcomeFail})
 	report := NowReport(dummyClaims)
 
 	if report.PassedCount != 1 {
@@ -114,19 +125,148 @@ func TestNowReportCounts(t *testing.T) {
 
 }
 
+func TestNowReportUsesLastState(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+
+	// The check struct says it passed, but the last run errored out.
+	c1 := dummyCheck{
+		name:      "c1",
+		runnable:  true,
+		passedVal: true,
+		uuid:      "check-last-state",
+	}
+	shared.UpdateLastState(shared.LastState{
+		UUID:    "check-last-state",
+		Name:    "c1",
+		Details: "timed out",
+		Outcome: check.OutcomeError,
+	})
+
+	report := NowReport([]claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{&c1}},
+	})
+
+	if report.FailedCount != 1 {
+		t.Errorf("Expected FailedCount = 1, got %d", report.FailedCount)
+	}
+	if state := report.State["check-last-state"]; state != "fail" {
+		t.Errorf("Expected check-last-state state = fail, got %s", state)
+	}
+}
+
+func TestNowReportFailingSince(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	err := shared.AppendHistory([]shared.HistoryEntry{
+		{Time: started.Add(-time.Hour), UUID: "check-failing", Name: "c1", Outcome: check.OutcomePass},
+		{Time: started, UUID: "check-failing", Name: "c1", Outcome: check.OutcomeFail},
+		{Time: started.Add(time.Hour), UUID: "check-failing", Name: "c1", Outcome: check.OutcomeFail},
+	})
+	if err != nil {
+		t.Fatalf("AppendHistory failed: %v", err)
+	}
+
+	c1 := dummyCheck{
+		name:      "c1",
+		runnable:  true,
+		passedVal: false,
+		uuid:      "check-failing",
+	}
+	shared.UpdateLastState(shared.LastState{UUID: "check-failing", Name: "c1", Outcome: check.OutcomeFail})
+	report := NowReport([]claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{&c1}},
+	})
+
+	if since := report.FailingSince["check-failing"]; since != started.Format(time.RFC3339) {
+		t.Errorf("Expected check-failing to fail since %s, got %q", started.Format(time.RFC3339), since)
+	}
+}
+
+func TestStatesReport(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+
+	c1 := dummyCheck{name: "c1", runnable: true, passedVal: true, uuid: "check-states-1"}
+	c2 := dummyCheck{name: "c2", runnable: true, passedVal: true, uuid: "check-states-2"}
+	all := []claims.Claim{{Title: "Test Case", Checks: []check.Check{&c1, &c2}}}
+
+	before := StatesReport(all, map[string]shared.LastState{
+		"check-states-1": {UUID: "check-states-1", Outcome: check.OutcomePass, State: true},
+	})
+	if before.PassedCount != 1 || before.DisabledCount != 1 {
+		t.Errorf("Expected missing check to be off, got passed=%d disabled=%d", before.PassedCount, before.DisabledCount)
+	}
+
+	after := StatesReport(all, map[string]shared.LastState{
+		"check-states-1": {UUID: "check-states-1", Outcome: check.OutcomeFail},
+	})
+	if before.SignificantChange == after.SignificantChange {
+		t.Errorf("Expected a new failure to change SignificantChange")
+	}
+}
+
+func TestNowReportExempted(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	shared.Config.Checks = map[string]shared.CheckStatus{
+		"check-exempted": {Disabled: true, Justification: "kiosk"},
+	}
+	defer func() { shared.Config.Checks = nil }()
+
+	c1 := dummyCheck{
+		name:      "c1",
+		runnable:  true,
+		passedVal: false,
+		uuid:      "check-exempted",
+	}
+	report := NowReport([]claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{&c1}},
+	})
+
+	if report.DisabledCount != 1 || report.FailedCount != 0 {
+		t.Errorf("Expected exempted check to be disabled, got disabled=%d failed=%d", report.DisabledCount, report.FailedCount)
+	}
+	if state := report.State["check-exempted"]; state != "off" {
+		t.Errorf("Expected check-exempted state = off, got %s", state)
+	}
+}
+
+func TestNowReportTampered(t *testing.T) {
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+
+	// An unsealed passing result, as written by hand
+	state := "[check-tampered]\nUUID = \"check-tampered\"\nName = \"c1\"\nState = true\nOutcome = \"pass\"\n"
+	if err := os.WriteFile(shared.StatePath, []byte(state), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	c1 := dummyCheck{name: "c1", runnable: true, passedVal: true, uuid: "check-tampered"}
+	report := NowReport([]claims.Claim{
+		{Title: "Test Case", Checks: []check.Check{&c1}},
+	})
+
+	if report.PassedCount != 0 || report.FailedCount != 1 {
+		t.Errorf("Expected tampered check to fail, got passed=%d failed=%d", report.PassedCount, report.FailedCount)
+	}
+	if reason := report.Tampered["check-tampered"]; reason == "" {
+		t.Errorf("Expected check-tampered to be reported as tampered, got %v", report.Tampered)
+	}
+}
+
 func TestReportToTeam(t *testing.T) {
 	defer gock.Off()
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	shared.DeviceKeyPath = filepath.Join(t.TempDir(), "device.key")
 
 	shared.Config.TeamID = "testTeam"
 	shared.Config.AuthToken = "testToken"
 
 	// Test initial report (PUT request).
-	gock.New(reportURL).
+	gock.New(shared.DefaultReportURL).
 		Put("/api/v1/team/" + shared.Config.TeamID + "/device").
 		Reply(200).
 		BodyString(`{"status": "ok"}`)
 
-	err := ReportToTeam(true)
+	err := ReportToTeam(true, "")
 	if err != nil {
 		t.Fatalf("ReportToTeam (initial) failed: %v", err)
 	}
@@ -138,12 +278,12 @@ func TestReportToTeam(t *testing.T) {
 	gock.Clean()
 
 	// Test subsequent report (PATCH request).
-	gock.New(reportURL).
+	gock.New(shared.DefaultReportURL).
 		Patch("/api/v1/team/" + shared.Config.TeamID + "/device").
 		Reply(200).
 		BodyString(`{"status": "ok"}`)
 
-	err = ReportToTeam(false)
+	err = ReportToTeam(false, "")
 	if err != nil {
 		t.Fatalf("ReportToTeam (subsequent) failed: %v", err)
 	}
@@ -155,12 +295,12 @@ func TestReportToTeam(t *testing.T) {
 	gock.Clean()
 
 	// Test API error handling.
-	gock.New(reportURL).
+	gock.New(shared.DefaultReportURL).
 		Patch("/api/v1/team/" + shared.Config.TeamID + "/device").
 		Reply(500).
 		BodyString(`{"error": "server error"}`)
 
-	err = ReportToTeam(false)
+	err = ReportToTeam(false, "")
 	if err == nil {
 		t.Fatalf("ReportToTeam (API error) should have failed, but didn't")
 	}
@@ -171,11 +311,11 @@ func TestReportToTeam(t *testing.T) {
 	gock.Clean()
 
 	// Test request failure
-	gock.New(reportURL).
+	gock.New(shared.DefaultReportURL).
 		Patch("/api/v1/team/" + shared.Config.TeamID + "/device").
 		ReplyError(err)
 
-	err = ReportToTeam(false)
+	err = ReportToTeam(false, "")
 	if err == nil {
 		t.Fatalf("ReportToTeam (Request  error) should have failed, but didn't")
 	}
@@ -185,3 +325,34 @@ func TestReportToTeam(t *testing.T) {
 	}
 	gock.Clean()
 }
+
+func TestReportToTeamProfile(t *testing.T) {
+	defer gock.Off()
+	shared.StatePath = filepath.Join(t.TempDir(), "test.state")
+	shared.Config.TeamID = "testTeam"
+	shared.Config.AuthToken = "testToken"
+
+	var sent Report
+	gock.New(shared.DefaultReportURL).
+		Patch("/api/v1/team/" + shared.Config.TeamID + "/device").
+		AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+			return true, json.NewDecoder(req.Body).Decode(&sent)
+		}).
+		Reply(200).
+		BodyString(`{"status": "ok"}`)
+
+	if err := ReportToTeam(false, "server"); err != nil {
+		t.Fatalf("ReportToTeam (profile) failed: %v", err)
+	}
+	if sent.Profile != "server" {
+		t.Errorf("Expected profile = server, got %q", sent.Profile)
+	}
+	// Printer is only part of the workstation profile
+	if _, found := sent.State["b96524e0-150b-4bb8-abc7-517051b6c14e"]; found {
+		t.Errorf("Expected checks outside the profile not to be reported, got %v", sent.State)
+	}
+
+	if err := ReportToTeam(false, "unknown"); err == nil {
+		t.Errorf("Expected an unknown profile to fail")
+	}
+}
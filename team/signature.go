@@ -0,0 +1,49 @@
+package team
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Requests to the team dashboard are signed with the device key so that the
+// dashboard can tell they come from the device that was linked, even if the
+// team token leaked. The signature covers the method, the path, a timestamp,
+// a nonce and the body, each followed by a newline, so that a request cannot
+// be replayed or altered.
+const (
+	headerTimestamp = "X-Device-Timestamp"
+	headerNonce     = "X-Device-Nonce"
+	headerSignature = "X-Device-Signature"
+)
+
+// signedMessage returns the bytes the signature of a request is over.
+func signedMessage(method, path, timestamp, nonce string, body []byte) []byte {
+	var message bytes.Buffer
+	for _, part := range []string{method, path, timestamp, nonce} {
+		message.WriteString(part)
+		message.WriteByte('\n')
+	}
+	message.Write(body)
+	return message.Bytes()
+}
+
+// signRequest returns the headers that sign a request with key at now.
+func signRequest(key ed25519.PrivateKey, method, path string, body []byte, now time.Time) (map[string]string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	encodedNonce := hex.EncodeToString(nonce)
+	signature := ed25519.Sign(key, signedMessage(method, path, timestamp, encodedNonce, body))
+	return map[string]string{
+		headerTimestamp: timestamp,
+		headerNonce:     encodedNonce,
+		headerSignature: base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
@@ -0,0 +1,223 @@
+// Package teamtest provides a local stand-in for the team dashboard that
+// implements the device API the agent reports to, for tests and for trying
+// out self-hosted setups.
+package teamtest
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request is a request the Server accepted.
+type Request struct {
+	Method string
+	// Body is the decoded JSON body: the device for PUT and the report for
+	// PATCH.
+	Body map[string]any
+}
+
+// Server implements PUT and PATCH on /api/v1/team/{id}/device for a single
+// team. PUT links a device and PATCH reports its state. Requests for another
+// team are answered with 404, and requests without the team's token in the
+// X-Device-Auth header with 401.
+//
+// A device that registered a public key when it was linked must sign its
+// requests with it: requests with a missing or invalid signature, a
+// timestamp off by more than MaxClockSkew or a nonce seen before are
+// answered with 401. A PUT that registers a new key for a device that has
+// one must be signed with the key it replaces, so that the team token alone
+// does not let anyone take over a device; ForgetDevice lets a device link
+// again with a new key.
+type Server struct {
+	*httptest.Server
+	TeamID    string
+	AuthToken string
+	// RequireSignatures rejects requests from devices without a key.
+	RequireSignatures bool
+
+	mux      *http.ServeMux
+	mu       sync.Mutex
+	status   int
+	requests []Request
+	keys     map[string]ed25519.PublicKey
+	nonces   map[string]bool
+}
+
+// MaxClockSkew is how far the timestamp of a signed request may be from the
+// time of the Server.
+const MaxClockSkew = 5 * time.Minute
+
+// NewServer starts a Server for the team with the given ID and token. Call
+// Close when done.
+func NewServer(teamID, authToken string) *Server {
+	s := newServer(teamID, authToken)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts a Server with a self-signed certificate, see
+// httptest.NewTLSServer.
+func NewTLSServer(teamID, authToken string) *Server {
+	s := newServer(teamID, authToken)
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+func newServer(teamID, authToken string) *Server {
+	s := &Server{
+		TeamID:    teamID,
+		AuthToken: authToken,
+		mux:       http.NewServeMux(),
+		keys:      map[string]ed25519.PublicKey{},
+		nonces:    map[string]bool{},
+	}
+	s.mux.HandleFunc("PUT /api/v1/team/{id}/device", s.handleDevice)
+	s.mux.HandleFunc("PATCH /api/v1/team/{id}/device", s.handleDevice)
+	return s
+}
+
+// FailWith makes the Server answer every request with status, as an
+// unavailable dashboard would. A status of 0 restores normal operation.
+func (s *Server) FailWith(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// ForgetDevice removes the key of the device with the given machine UUID,
+// as removing the device from the dashboard would.
+func (s *Server) ForgetDevice(machine string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, machine)
+}
+
+// Requests returns the requests the Server accepted, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reports returns the bodies of the accepted PATCH requests, oldest first.
+func (s *Server) Reports() []map[string]any {
+	var reports []map[string]any
+	for _, req := range s.Requests() {
+		if req.Method == http.MethodPatch {
+			reports = append(reports, req.Body)
+		}
+	}
+	return reports
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != 0 {
+		http.Error(w, http.StatusText(s.status), s.status)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// verify checks the signature of r over body with key, the key of the
+// device that sent it.
+func (s *Server) verify(r *http.Request, key ed25519.PublicKey, body []byte) error {
+	if key == nil {
+		if s.RequireSignatures {
+			return errors.New("device has no key")
+		}
+		return nil
+	}
+	timestamp := r.Header.Get("X-Device-Timestamp")
+	nonce := r.Header.Get("X-Device-Nonce")
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get("X-Device-Signature"))
+	if err != nil || timestamp == "" || nonce == "" {
+		return errors.New("request is not signed")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if skew := time.Since(time.Unix(seconds, 0)); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return errors.New("timestamp is too far off")
+	}
+	if s.nonces[nonce] {
+		return errors.New("request was replayed")
+	}
+	message := strings.Join([]string{r.Method, r.URL.Path, timestamp, nonce, string(body)}, "\n")
+	if !ed25519.Verify(key, []byte(message), signature) {
+		return errors.New("invalid signature")
+	}
+	s.nonces[nonce] = true
+	return nil
+}
+
+func (s *Server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("id") != s.TeamID {
+		http.Error(w, `{"error": "team not found"}`, http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("X-Device-Auth") != "Bearer "+s.AuthToken {
+		http.Error(w, `{"error": "unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, `{"error": "invalid body"}`, http.StatusBadRequest)
+		return
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		http.Error(w, `{"error": "invalid JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	// The device is the body of a PUT and part of the report of a PATCH
+	device := body
+	if r.Method == http.MethodPatch {
+		device, _ = body["device"].(map[string]any)
+	}
+	machine, _ := device["machineUUID"].(string)
+	key := s.keys[machine]
+	signer := key
+	if r.Method == http.MethodPut {
+		key = nil
+		if encoded, _ := device["publicKey"].(string); encoded != "" {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(decoded) != ed25519.PublicKeySize {
+				http.Error(w, `{"error": "invalid public key"}`, http.StatusBadRequest)
+				return
+			}
+			key = decoded
+		}
+		// A new device proves it holds its key, a known one that it held
+		// the key being replaced
+		if signer == nil {
+			signer = key
+		}
+	}
+	if err := s.verify(r, signer, raw); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error": %q}`, err.Error()), http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodPut {
+		s.keys[machine] = key
+	}
+
+	s.requests = append(s.requests, Request{Method: r.Method, Body: body})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"status": "ok"}`))
+}
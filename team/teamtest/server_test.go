@@ -0,0 +1,75 @@
+package teamtest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func send(t *testing.T, s *Server, method, body string, key ed25519.PrivateKey, timestamp time.Time, nonce string) int {
+	path := "/api/v1/team/" + s.TeamID + "/device"
+	req, err := http.NewRequest(method, s.URL+path, bytes.NewBufferString(body))
+	assert.NoError(t, err)
+	req.Header.Set("X-Device-Auth", "Bearer "+s.AuthToken)
+	if key != nil {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		message := method + "\n" + path + "\n" + ts + "\n" + nonce + "\n" + body
+		req.Header.Set("X-Device-Timestamp", ts)
+		req.Header.Set("X-Device-Nonce", nonce)
+		req.Header.Set("X-Device-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(message))))
+	}
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer("team", "token")
+	defer s.Close()
+	public, key, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	now := time.Now()
+	device := `{"machineUUID": "device", "publicKey": "` + base64.StdEncoding.EncodeToString(public) + `"}`
+	report := `{"device": {"machineUUID": "device"}}`
+
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPut, device, nil, now, ""), "the key must be proven")
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPut, device, key, now, "1"))
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPatch, report, key, now, "2"))
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPatch, report, key, now, "2"), "replays are rejected")
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPatch, report, key, now.Add(-time.Hour), "3"), "stale requests are rejected")
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPatch, report, nil, now, ""))
+
+	_, other, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPatch, report, other, now, "4"), "forged reports are rejected")
+	assert.Len(t, s.Reports(), 1)
+
+	// The key of a known device is only replaced by its holder
+	newPublic, newKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	rotated := `{"machineUUID": "device", "publicKey": "` + base64.StdEncoding.EncodeToString(newPublic) + `"}`
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPut, rotated, newKey, now, "5"), "the team token does not take over a device")
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPut, `{"machineUUID": "device"}`, nil, now, ""), "the key is not dropped")
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPatch, report, key, now, "6"))
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPut, rotated, key, now, "7"))
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPatch, report, newKey, now, "8"))
+	s.ForgetDevice("device")
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPut, device, key, now, "9"), "forgotten devices link again")
+	assert.Len(t, s.Reports(), 3)
+
+	// Devices linked without a key are accepted unless signatures are required
+	legacy := `{"device": {"machineUUID": "legacy"}}`
+	assert.Equal(t, http.StatusOK, send(t, s, http.MethodPatch, legacy, nil, now, ""))
+	s.RequireSignatures = true
+	assert.Equal(t, http.StatusUnauthorized, send(t, s, http.MethodPatch, legacy, nil, now, ""))
+
+	s.FailWith(http.StatusServiceUnavailable)
+	assert.Equal(t, http.StatusServiceUnavailable, send(t, s, http.MethodPatch, report, key, now, "10"))
+}
@@ -59,8 +59,10 @@ in {
     wideopen.fail("curl --fail --connect-timeout 2 http://walled")
     walled.succeed("curl --fail --connect-timeout 2 http://wideopen")
 
-    # Test 1: check fails with iptables disabled
-    out = wideopen.fail("paretosecurity check --only 2e46c89a-5461-4865-a92e-3b799c12034a")
+    # Test 1: check fails with iptables disabled, exiting with the code of a
+    # critical failure
+    status, out = wideopen.execute("paretosecurity check --only 2e46c89a-5461-4865-a92e-3b799c12034a")
+    assert status == 5, f"Expected exit code 5, got {status}"
     expected = (
         "  • Starting checks...\n"
         "  • Firewall & Sharing: Firewall is on > [FAIL] Neither ufw, firewalld nor iptables are present, check cannot run\n"
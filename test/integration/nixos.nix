@@ -25,15 +25,13 @@ pkgs.testers.runNixOSTest {
       after = ["paretosecurity.socket"];
       wantedBy = ["multi-user.target"];
       serviceConfig = {
-        ExecStart = ["${flakePackage}/bin/paretosecurity" "helper" "--verbose" "--socket" "/run/paretosecurity.sock"];
+        ExecStart = ["${flakePackage}/bin/paretosecurity" "helper" "--verbose"];
         User = "root";
         Group = "root";
         StandardInput = "socket";
-        Type = "oneshot";
-        RemainAfterExit = "no";
-        StartLimitInterval = "1s";
-        StartLimitBurst = 100;
+        Type = "simple";
         ProtectSystem = "full";
+        ReadWritePaths = ["-/etc/ssh/sshd_config.d" "-/etc/ufw" "-/etc/sddm.conf" "-/etc/sddm.conf.d" "-/etc/gdm" "-/etc/gdm3"];
         ProtectHome = true;
         StandardOutput = "journal";
         StandardError = "journal";
@@ -44,7 +42,7 @@ pkgs.testers.runNixOSTest {
       wantedBy = ["timers.target"];
       serviceConfig = {
         Type = "oneshot";
-        ExecStart = "${flakePackage}/bin/paretosecurity check";
+        ExecStart = "${flakePackage}/bin/paretosecurity check --notify";
         StandardInput = "null";
       };
     };
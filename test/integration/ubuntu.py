@@ -9,4 +9,17 @@ dial_error_count = res.count("Failed to connect to helper")
 assert (
     dial_error_count == 0
 ), f"Helper could not start, found : {dial_error_count} calls to dial error"
-assert fail_count > 3, f"Found {fail_count} failed checks"
\ No newline at end of file
+assert fail_count > 3, f"Found {fail_count} failed checks"
+
+# Root fixes are applied by the helper under the shipped unit, which keeps /etc
+# read-only apart from the files the fixes write
+vm.succeed("useradd --create-home tester")
+vm.succeed("mkdir -p /etc/paretosecurity")
+vm.succeed("printf 'Admins = [\"tester\"]\\n' > /etc/paretosecurity/helper.toml")
+vm.succeed("ufw --force disable")
+res = vm.succeed(
+    "sudo -u tester -H paretosecurity fix --only 2e46c89a-5461-4865-a92e-3b799c12034a --yes 2>&1"
+)
+print(res)
+assert "Failed to apply fix" not in res, f"Helper could not apply the fix: {res}"
+assert "Status: active" in vm.succeed("ufw status"), "Firewall was not turned on"